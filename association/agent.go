@@ -0,0 +1,163 @@
+package association
+
+import (
+	"errors"
+	"jtso/logger"
+	"jtso/maker"
+	"jtso/sqlite"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	agentTableRegex = regexp.MustCompile(`^\s*\[agent\]\s*(#.*)?$`)
+	tableRegex      = regexp.MustCompile(`^\s*\[`)
+	agentKeyRegex   = regexp.MustCompile(`^\s*([A-Za-z_]+)\s*=`)
+)
+
+// Keys of the [agent] section rendered by maker
+var managedAgentKeys = map[string]bool{
+	"metric_batch_size":   true,
+	"metric_buffer_limit": true,
+	"collection_jitter":   true,
+	"flush_interval":      true,
+	"flush_jitter":        true,
+	"debug":               true,
+	"logtarget":           true,
+	"logfile":             true,
+}
+
+// Agent of a family - the overrides of the family take precedence over the
+// collector parameters
+func agentConfig(p sqlite.CollectorParameters, t sqlite.AgentTuning, debug int) maker.AgentConfig {
+	pick := func(override string, value string) string {
+		if override != "" {
+			return override
+		}
+		return value
+	}
+	agent := maker.AgentConfig{
+		MetricBatchSize:   pick(t.MetricBatchSize, p.MetricBatchSize),
+		MetricBufferLimit: pick(t.MetricBufferLimit, p.MetricBufferLimit),
+		FlushInterval:     pick(t.FlushInterval, p.FlushInterval),
+		FlushJitter:       pick(t.FlushJitter, p.FlushJitter),
+		CollectionJitter:  pick(t.CollectionJitter, "0s"),
+		Debug:             debug == 1,
+	}
+	// an empty logfile means stderr
+	if t.LogTarget != "stderr" {
+		agent.LogFile = t.LogTarget
+	}
+	return agent
+}
+
+// Replace the [agent] section of the telegraf.conf of a family. The lines of
+// the section not managed by JTSO are kept.
+func writeTelegrafAgent(family string, p sqlite.CollectorParameters, t sqlite.AgentTuning, debug int) error {
+	filePath := TELEGRAF_ROOT_PATH + family + "/telegraf.conf"
+
+	content, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Log.Errorf("Unable to open the file %s: %v", filePath, err)
+		return err
+	}
+	lines := make([]string, 0)
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	}
+
+	// locate the section - it ends before the next table and its leading comments
+	start, end := -1, len(lines)
+	for i, l := range lines {
+		if start < 0 && agentTableRegex.MatchString(l) {
+			start = i
+		} else if start >= 0 && tableRegex.MatchString(l) {
+			end = i
+			break
+		}
+	}
+	if start < 0 {
+		start = len(lines)
+	} else {
+		for end > start+1 {
+			l := strings.TrimSpace(lines[end-1])
+			if l != "" && !strings.HasPrefix(l, "#") {
+				break
+			}
+			end--
+		}
+	}
+
+	agent := agentConfig(p, t, debug)
+	for i := start + 1; i < end; i++ {
+		if m := agentKeyRegex.FindStringSubmatch(lines[i]); m != nil && managedAgentKeys[m[1]] {
+			continue
+		}
+		agent.Extra = append(agent.Extra, lines[i])
+	}
+	payload, err := maker.RenderAgent(agent)
+	if err != nil {
+		return err
+	}
+
+	updated := make([]string, 0, len(lines)+10)
+	updated = append(updated, lines[:start]...)
+	updated = append(updated, strings.TrimRight(*payload, "\n"))
+	updated = append(updated, lines[end:]...)
+	if err := os.WriteFile(filePath, []byte(strings.Join(updated, "\n")+"\n"), 0644); err != nil {
+		logger.Log.Errorf("Error while saving the file %s: %v", filePath, err)
+		return err
+	}
+	return nil
+}
+
+// True if at least one router of the family is collected
+func familyActive(family string) bool {
+	for _, r := range sqlite.RtrList {
+		if r.Family == family && r.Profile == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the agent overrides of a family - only the Telegraf instance of the
+// family restarts
+func ChangeAgentTuning(t sqlite.AgentTuning) error {
+	t.Family = strings.ToLower(t.Family)
+	if err := t.Check(); err != nil {
+		logger.Log.Errorf("Invalid agent tuning: %v", err)
+		return err
+	}
+	debug, err := sqlite.DebugMode(t.Family)
+	if err != nil {
+		logger.Log.Errorf("Unsupported instance %s", t.Family)
+		return errors.New("ChangeAgentTuning error: unsupported instance")
+	}
+	previous := sqlite.ActiveAgentTuning[t.Family]
+
+	if err := writeTelegrafAgent(t.Family, sqlite.ActiveCollectorParameters, t, debug); err != nil {
+		logger.Log.Errorf("Error while changing the agent of the instance %s", t.Family)
+		return err
+	}
+
+	if familyActive(t.Family) {
+		if err := restartTelegraf(t.Family); err != nil {
+			logger.Log.Errorf("Unable to restart containter telegraf_%s: %v", t.Family, err)
+			// revert back to previous state
+			writeTelegrafAgent(t.Family, sqlite.ActiveCollectorParameters, previous, debug)
+			return err
+		}
+	}
+
+	if err := sqlite.UpdateAgentTuning(t); err != nil {
+		logger.Log.Errorf("Unable to save the agent tuning in DB for telegraf_%s: %v", t.Family, err)
+		// revert back to previous state
+		writeTelegrafAgent(t.Family, sqlite.ActiveCollectorParameters, previous, debug)
+		return err
+	}
+
+	logger.Log.Infof("Agent tuning has been successfully updated for the telegraf instance %s", t.Family)
+	return nil
+}
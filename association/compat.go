@@ -0,0 +1,168 @@
+package association
+
+import (
+	"fmt"
+	"jtso/sqlite"
+	"sort"
+	"strings"
+)
+
+// Display name of the router families
+var familyLabels = map[string]string{
+	"mx": "MX", "ptx": "PTX", "acx": "ACX", "ex": "EX", "qfx": "QFX", "srx": "SRX",
+	"crpd": "CRPD", "cptx": "CPTX", "vmx": "VMX", "vsrx": "VSRX",
+	"vjunos": "VJunos Router", "vevo": "VJunos Evolved",
+}
+
+// Compatibility of a router with a profile
+type CompatEntry struct {
+	Router     string `json:"router"`
+	Hostname   string `json:"hostname"`
+	Family     string `json:"family"`
+	Version    string `json:"version"`
+	Group      string `json:"group"`
+	Profile    string `json:"profile"`
+	Compatible bool   `json:"compatible"`
+	// Telegraf configuration variant selected and the version rule which matched
+	Config string `json:"config"`
+	Rule   string `json:"rule"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Telegraf configurations of a family - false if the family is unknown
+func (t *Telegraf) Family(family string) ([]Config, bool) {
+	switch family {
+	case "mx":
+		return t.MxCfg, true
+	case "ptx":
+		return t.PtxCfg, true
+	case "acx":
+		return t.AcxCfg, true
+	case "ex":
+		return t.ExCfg, true
+	case "qfx":
+		return t.QfxCfg, true
+	case "srx":
+		return t.SrxCfg, true
+	case "crpd":
+		return t.CrpdCfg, true
+	case "cptx":
+		return t.CptxCfg, true
+	case "vmx":
+		return t.VmxCfg, true
+	case "vsrx":
+		return t.VsrxCfg, true
+	case "vjunos":
+		return t.VjunosCfg, true
+	case "vevo":
+		return t.VevoCfg, true
+	}
+	return nil, false
+}
+
+// Select the Telegraf configuration of a router version: the first matching
+// version rule, or the "all" configuration as a fallback. Return the file and
+// the rule - empty if nothing matches.
+func SelectConfig(cfgs []Config, version string) (string, string) {
+	conf, rule := "", ""
+	for _, c := range cfgs {
+		if c.Version == "all" && rule == "" {
+			conf, rule = c.Config, "all"
+		} else if CheckVersion(c.Version, version) && (rule == "" || rule == "all") {
+			conf, rule = c.Config, c.Version
+		}
+	}
+	return conf, rule
+}
+
+// Compatibility of a router with an active profile - ProfileLock must be held.
+// A composed profile is compatible if one of its components is.
+func Compatibility(rtr *sqlite.RtrEntry, profile string) CompatEntry {
+	e := CompatEntry{
+		Router:   rtr.Shortname,
+		Hostname: rtr.Hostname,
+		Family:   rtr.Family,
+		Version:  rtr.Version,
+		Group:    sqlite.RouterGroups[rtr.Shortname],
+		Profile:  profile,
+	}
+	entry, ok := ActiveProfiles[profile]
+	if !ok || entry.Definition == nil {
+		e.Reason = "unknown profile"
+		return e
+	}
+	label, ok := familyLabels[rtr.Family]
+	if !ok {
+		e.Reason = "unknown platform " + rtr.Family
+		return e
+	}
+
+	components := entry.Components
+	if len(components) == 0 {
+		components = []string{profile}
+	}
+	configs := make([]string, 0)
+	rules := make([]string, 0)
+	reasons := make([]string, 0)
+	for _, p := range components {
+		comp, ok := ActiveProfiles[p]
+		if !ok || comp.Definition == nil {
+			continue
+		}
+		prefix, why := "", ""
+		if len(components) > 1 {
+			prefix, why = p+"/", p+": "
+		}
+		cfgs, _ := comp.Definition.TelCfg.Family(rtr.Family)
+		if len(cfgs) == 0 {
+			reasons = append(reasons, fmt.Sprintf("%sno Telegraf config for the %s platform", why, label))
+			continue
+		}
+		conf, rule := SelectConfig(cfgs, rtr.Version)
+		if rule == "" {
+			available := make([]string, len(cfgs))
+			for i, c := range cfgs {
+				available[i] = c.Version
+			}
+			reasons = append(reasons, fmt.Sprintf("%sno Telegraf config for %s version %s (rules: %s)", why, label, rtr.Version, strings.Join(available, ", ")))
+			continue
+		}
+		configs = append(configs, prefix+conf)
+		rules = append(rules, rule)
+	}
+	e.Compatible = len(configs) > 0
+	e.Config = strings.Join(configs, " + ")
+	e.Rule = strings.Join(rules, " + ")
+	e.Reason = strings.Join(reasons, " - ")
+	return e
+}
+
+// Compatibility of every router with every active profile. Empty family or
+// group means no filter.
+func CompatibilityMatrix(family string, group string) []CompatEntry {
+	ProfileLock.Lock()
+	defer ProfileLock.Unlock()
+
+	profiles := make([]string, 0, len(ActiveProfiles))
+	for p := range ActiveProfiles {
+		profiles = append(profiles, p)
+	}
+	sort.Strings(profiles)
+
+	matrix := make([]CompatEntry, 0)
+	for _, rtr := range sqlite.RtrList {
+		if family != "" && rtr.Family != family {
+			continue
+		}
+		if group != "" && sqlite.RouterGroups[rtr.Shortname] != group {
+			continue
+		}
+		for _, p := range profiles {
+			matrix = append(matrix, Compatibility(rtr, p))
+		}
+	}
+	sort.SliceStable(matrix, func(i, j int) bool {
+		return matrix[i].Router < matrix[j].Router
+	})
+	return matrix
+}
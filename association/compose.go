@@ -0,0 +1,113 @@
+package association
+
+import (
+	"fmt"
+	"jtso/logger"
+	"path/filepath"
+	"strings"
+)
+
+// Profiles a definition extends or includes, in resolution order
+func (d *DefProfile) bases() []string {
+	bases := make([]string, 0, len(d.Includes)+1)
+	if d.Extends != "" {
+		bases = append(bases, d.Extends)
+	}
+	return append(bases, d.Includes...)
+}
+
+// Check the names of the extended and included profiles of a definition
+func (d *DefProfile) checkBases(self string) error {
+	for _, b := range d.bases() {
+		if b == "" || filepath.Base(b) != b || strings.HasSuffix(b, ".tgz") {
+			return fmt.Errorf("invalid profile name %q in extends/includes", b)
+		}
+		if b == self {
+			return fmt.Errorf("the profile cannot extend or include itself")
+		}
+	}
+	return nil
+}
+
+// Resolve a profile and the profiles it extends or includes, recursively.
+// The profile comes first, then its bases in declaration order.
+func compose(name string, path []string, done map[string][]string) ([]string, error) {
+	if comps, ok := done[name]; ok {
+		return comps, nil
+	}
+	for _, p := range path {
+		if p == name {
+			return nil, fmt.Errorf("cycle detected: %s -> %s", strings.Join(path, " -> "), name)
+		}
+	}
+	entry, ok := ActiveProfiles[name]
+	if !ok || entry.Definition == nil {
+		return nil, fmt.Errorf("unknown profile %s", name)
+	}
+	path = append(path, name)
+
+	comps := []string{name}
+	seen := map[string]bool{name: true}
+	for _, b := range entry.Definition.bases() {
+		sub, err := compose(b, path, done)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sub {
+			if !seen[s] {
+				seen[s] = true
+				comps = append(comps, s)
+			}
+		}
+	}
+	done[name] = comps
+	return comps, nil
+}
+
+// Resolve the composition of all the active profiles - ProfileLock must be
+// held. A profile which can not be resolved is used alone. Return the
+// known profiles whose composition changed.
+func resolveCompositions() []string {
+	changed := make([]string, 0)
+	done := make(map[string][]string)
+	for name, entry := range ActiveProfiles {
+		comps, err := compose(name, nil, done)
+		composeErr := ""
+		if err != nil {
+			logger.Log.Errorf("Unable to resolve extends/includes of profile %s - the profile is used alone: %v", name, err)
+			comps = []string{name}
+			composeErr = err.Error()
+		}
+		// new profiles are not reported - they are not used by any collection yet
+		if entry.Components != nil && strings.Join(comps, ",") != strings.Join(entry.Components, ",") {
+			changed = append(changed, name)
+			if len(comps) > 1 {
+				logger.Log.Infof("Profile %s resolved as %s", name, strings.Join(comps, ", "))
+			}
+		}
+		entry.Components = comps
+		entry.ComposeError = composeErr
+		ActiveProfiles[name] = entry
+	}
+	return changed
+}
+
+// Expand a list of associated profiles with the profiles they extend or
+// include - each profile appears once
+func expandProfiles(names []string) []string {
+	out := make([]string, 0, len(names))
+	seen := make(map[string]bool)
+	for _, n := range names {
+		comps := []string{n}
+		if entry, ok := ActiveProfiles[n]; ok && len(entry.Components) > 0 {
+			comps = entry.Components
+		}
+		for _, c := range comps {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package association
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveCompositions(t *testing.T) {
+	quietLogger()
+	saved := ActiveProfiles
+	t.Cleanup(func() { ActiveProfiles = saved })
+
+	profile := func(extends string, includes ...string) FileTgz {
+		return FileTgz{Definition: &DefProfile{Extends: extends, Includes: includes}}
+	}
+	ActiveProfiles = map[string]FileTgz{
+		"base":    profile(""),
+		"common":  profile(""),
+		"mx":      profile("base", "common"),
+		"mx-edge": profile("mx", "base"),
+		"loop-a":  profile("loop-b"),
+		"loop-b":  profile("loop-c"),
+		"loop-c":  profile("loop-a"),
+		"self":    profile("", "self"),
+		"broken":  profile("missing"),
+		"on-loop": profile("", "common", "loop-a"),
+	}
+	resolveCompositions()
+
+	tests := []struct {
+		name  string
+		comps []string
+		err   string
+	}{
+		{"base", []string{"base"}, ""},
+		{"mx", []string{"mx", "base", "common"}, ""},
+		{"mx-edge", []string{"mx-edge", "mx", "base", "common"}, ""},
+		{"loop-a", []string{"loop-a"}, "cycle detected: loop-a -> loop-b -> loop-c -> loop-a"},
+		{"loop-c", []string{"loop-c"}, "cycle detected"},
+		{"self", []string{"self"}, "cycle detected: self -> self"},
+		{"broken", []string{"broken"}, "unknown profile missing"},
+		{"on-loop", []string{"on-loop"}, "cycle detected"},
+	}
+	for _, tt := range tests {
+		entry := ActiveProfiles[tt.name]
+		if !reflect.DeepEqual(entry.Components, tt.comps) {
+			t.Errorf("%s components = %v, want %v", tt.name, entry.Components, tt.comps)
+		}
+		if tt.err == "" && entry.ComposeError != "" || !strings.Contains(entry.ComposeError, tt.err) {
+			t.Errorf("%s error = %q, want %q", tt.name, entry.ComposeError, tt.err)
+		}
+	}
+}
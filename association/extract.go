@@ -0,0 +1,146 @@
+package association
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Limits of a profile package
+const (
+	MAX_PACKAGE_SIZE    int64 = 200 * 1024 * 1024
+	MAX_PACKAGE_FILE    int64 = 50 * 1024 * 1024
+	MAX_PACKAGE_ENTRIES int   = 2000
+)
+
+// Extract a profile package in dest/name. Every entry must be a regular file or
+// a directory under the top directory name - links, absolute paths and ../ are
+// rejected. The archive is extracted in a temporary directory then renamed, so
+// a rejected package never replaces the current one.
+func ExtractPackage(tgzPath string, dest string, name string) error {
+	f, err := os.Open(tgzPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("invalid gzip archive: %v", err)
+	}
+	defer gz.Close()
+
+	tmp, err := os.MkdirTemp(dest, ".extract-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	tr := tar.NewReader(gz)
+	entries := 0
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %v", err)
+		}
+
+		entries++
+		if entries > MAX_PACKAGE_ENTRIES {
+			return fmt.Errorf("too many entries - limit is %d", MAX_PACKAGE_ENTRIES)
+		}
+		// pax global headers (git archive) carry metadata only
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		// archives built with "tar czf x.tgz ./dir" prefix the entries with ./
+		entry := strings.TrimPrefix(hdr.Name, "./")
+		if entry == "" || entry == "." {
+			continue
+		}
+		entry, err = checkEntryName(entry, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(tmp, filepath.FromSlash(entry))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if hdr.Size > MAX_PACKAGE_FILE {
+				return fmt.Errorf("entry %s is too large (%d bytes) - limit is %d", hdr.Name, hdr.Size, MAX_PACKAGE_FILE)
+			}
+			total += hdr.Size
+			if total > MAX_PACKAGE_SIZE {
+				return fmt.Errorf("extracted size exceeds %d bytes", MAX_PACKAGE_SIZE)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeEntry(tr, target, hdr.Size); err != nil {
+				return fmt.Errorf("unable to extract %s: %v", hdr.Name, err)
+			}
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("entry %s is a link - links are not allowed", hdr.Name)
+		default:
+			return fmt.Errorf("entry %s has an unsupported type %q", hdr.Name, hdr.Typeflag)
+		}
+	}
+
+	if st, err := os.Stat(filepath.Join(tmp, name)); err != nil || !st.IsDir() {
+		return fmt.Errorf("the package must contain a top directory named %s", name)
+	}
+
+	final := filepath.Join(dest, name)
+	if err := os.RemoveAll(final); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(tmp, name), final)
+}
+
+// Check an archive entry is a relative path under the top directory
+func checkEntryName(entry string, top string) (string, error) {
+	if entry == "" || strings.HasPrefix(entry, "/") || strings.Contains(entry, "\\") {
+		return "", fmt.Errorf("entry %q has an invalid or absolute path", entry)
+	}
+	for _, e := range strings.Split(entry, "/") {
+		if e == ".." {
+			return "", fmt.Errorf("entry %q contains ../", entry)
+		}
+	}
+	clean := path.Clean(entry)
+	if clean != top && !strings.HasPrefix(clean, top+"/") {
+		return "", fmt.Errorf("entry %q is outside of the top directory %s", entry, top)
+	}
+	return clean, nil
+}
+
+// Copy exactly size bytes of the current entry in a file
+func writeEntry(r io.Reader, target string, size int64) error {
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(r, size))
+	if err != nil {
+		out.Close()
+		return err
+	}
+	if n != size {
+		out.Close()
+		return fmt.Errorf("truncated entry")
+	}
+	return out.Close()
+}
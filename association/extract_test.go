@@ -0,0 +1,142 @@
+package association
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// One entry of a crafted archive - size overrides the length of the body in
+// the header
+type tarEntry struct {
+	name     string
+	typeflag byte
+	body     string
+	link     string
+	size     int64
+}
+
+// Write a gzip tar archive. An entry with an overridden size ends the archive
+// right after its header.
+func writeArchive(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "p.tgz")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.link, Mode: 0644, Size: int64(len(e.body))}
+		if e.typeflag == tar.TypeXGlobalHeader {
+			hdr = &tar.Header{Name: "pax_global_header", Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{"comment": e.body}}
+		}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if e.size > 0 {
+			hdr.Size = e.size
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.size > 0 {
+			return p
+		}
+		if hdr.Size > 0 {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestExtractPackage(t *testing.T) {
+	def := tarEntry{name: "prof/definition.json", typeflag: tar.TypeReg, body: "{}"}
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		err     string
+	}{
+		{
+			name:    "valid",
+			entries: []tarEntry{{name: "prof/", typeflag: tar.TypeDir}, def},
+		},
+		{
+			name:    "dot slash prefix",
+			entries: []tarEntry{{name: "./", typeflag: tar.TypeDir}, {name: "./prof/", typeflag: tar.TypeDir}, {name: "./prof/definition.json", typeflag: tar.TypeReg, body: "{}"}},
+		},
+		{
+			name:    "pax global header skipped",
+			entries: []tarEntry{{typeflag: tar.TypeXGlobalHeader, body: "0123456789abcdef"}, def},
+		},
+		{
+			name:    "parent directory",
+			entries: []tarEntry{def, {name: "prof/../../evil", typeflag: tar.TypeReg, body: "x"}},
+			err:     "contains ../",
+		},
+		{
+			name:    "absolute path",
+			entries: []tarEntry{def, {name: "/etc/evil", typeflag: tar.TypeReg, body: "x"}},
+			err:     "absolute path",
+		},
+		{
+			name:    "outside of the top directory",
+			entries: []tarEntry{def, {name: "other/file", typeflag: tar.TypeReg, body: "x"}},
+			err:     "outside of the top directory",
+		},
+		{
+			name:    "symlink",
+			entries: []tarEntry{def, {name: "prof/link", typeflag: tar.TypeSymlink, link: "/etc/passwd"}},
+			err:     "links are not allowed",
+		},
+		{
+			name:    "hardlink",
+			entries: []tarEntry{def, {name: "prof/link", typeflag: tar.TypeLink, link: "prof/definition.json"}},
+			err:     "links are not allowed",
+		},
+		{
+			name:    "oversize entry",
+			entries: []tarEntry{def, {name: "prof/big", typeflag: tar.TypeReg, size: MAX_PACKAGE_FILE + 1}},
+			err:     "too large",
+		},
+		{
+			name:    "no top directory",
+			entries: []tarEntry{{name: "definition.json", typeflag: tar.TypeReg, body: "{}"}},
+			err:     "outside of the top directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tgz := writeArchive(t, tt.entries)
+			dest := t.TempDir()
+			err := ExtractPackage(tgz, dest, "prof")
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("ExtractPackage: %v", err)
+				}
+				if _, err := os.Stat(filepath.Join(dest, "prof", "definition.json")); err != nil {
+					t.Errorf("definition.json not extracted: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("ExtractPackage error = %v, want %q", err, tt.err)
+			}
+			// a rejected package leaves nothing behind
+			if left, _ := os.ReadDir(dest); len(left) != 0 {
+				t.Errorf("rejected package left %d entries in the destination", len(left))
+			}
+		})
+	}
+}
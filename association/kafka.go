@@ -0,0 +1,160 @@
+package association
+
+import (
+	"jtso/maker"
+	"jtso/sqlite"
+	"sort"
+	"strings"
+)
+
+// Kafka outputs of a rendered config. With routing rules, one output is
+// rendered per topic with the measurements of the topic, plus the default
+// output for the other measurements. The namedrop measurements are never
+// exported.
+func kafkaOutputs(fieldpass []string, namedrop []string) []maker.KafkaOutput {
+	kc, ko := sqlite.ActiveKafkaConfig, sqlite.ActiveKafkaOptions
+
+	// split list of brokers thanks to comma separator and trim spaces
+	brokers := make([]string, 0)
+	for _, b := range strings.Split(kc.Brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	base := maker.KafkaOutput{
+		Brokers:          brokers,
+		Topic:            kc.Topic,
+		Format:           kc.Format,
+		Version:          kc.Version,
+		MessageSize:      kc.MessageSize,
+		CompressionCodec: kc.Compression,
+		Fieldpass:        fieldpass,
+		Namedrop:         namedrop,
+		SaslMechanism:    ko.SaslMechanism,
+		SaslUser:         ko.SaslUser,
+		Tls:              ko.Tls == "yes",
+		CA:               ko.CA,
+		SkipVerify:       ko.SkipVerify == "yes",
+	}
+
+	switch ko.Routing {
+	case sqlite.KAFKA_ROUTING_SUFFIX:
+		base.TopicSuffix = true
+	case sqlite.KAFKA_ROUTING_TAG:
+		base.TopicTag = ko.TopicTag
+	case sqlite.KAFKA_ROUTING_RULES:
+		routes := ko.RouteMap()
+		if len(routes) == 0 {
+			break
+		}
+		byTopic := make(map[string][]string)
+		routed := make([]string, 0, len(routes))
+		for m, t := range routes {
+			byTopic[t] = append(byTopic[t], m)
+			routed = append(routed, m)
+		}
+		topics := make([]string, 0, len(byTopic))
+		for t := range byTopic {
+			topics = append(topics, t)
+		}
+		sort.Strings(topics)
+		sort.Strings(routed)
+
+		outputs := make([]maker.KafkaOutput, 0, len(topics)+1)
+		for _, t := range topics {
+			o := base
+			o.Topic = t
+			o.Namepass = byTopic[t]
+			sort.Strings(o.Namepass)
+			outputs = append(outputs, o)
+		}
+		base.Namedrop = append(routed, namedrop...)
+		return append(outputs, base)
+	}
+	return []maker.KafkaOutput{base}
+}
+
+// Union of the fieldpass of the outputs of the configs of the selected
+// profiles - profiles[i] is the profile of cfgs[i]. A selected config without
+// output does not declare its fields: nothing is filtered then. The boolean
+// is false if no profile is selected.
+func outputsFieldpass(cfgs []*maker.TelegrafConfig, profiles []string, selected func(string) bool) ([]string, bool) {
+	fieldpass := make([]string, 0)
+	seen := make(map[string]bool)
+	found, unknown := false, false
+	for i, c := range cfgs {
+		if !selected(profiles[i]) {
+			continue
+		}
+		found = true
+		if len(c.InfluxList) == 0 && len(c.KafkaList) == 0 {
+			unknown = true
+		}
+		lists := make([][]string, 0)
+		for _, o := range c.InfluxList {
+			lists = append(lists, o.Fieldpass)
+		}
+		for _, o := range c.KafkaList {
+			lists = append(lists, o.Fieldpass)
+		}
+		for _, l := range lists {
+			for _, f := range l {
+				if !seen[f] {
+					seen[f] = true
+					fieldpass = append(fieldpass, f)
+				}
+			}
+		}
+	}
+	if unknown {
+		return []string{}, found
+	}
+	return fieldpass, found
+}
+
+// Measurements emitted by a config - the subscriptions of its inputs and the
+// clones of its processors
+func configMeasurements(c *maker.TelegrafConfig) []string {
+	names := make([]string, 0)
+	for _, g := range c.GnmiList {
+		for _, s := range g.Subs {
+			names = append(names, s.Name)
+		}
+	}
+	for _, n := range c.NetconfList {
+		for _, s := range n.Subs {
+			names = append(names, s.Name)
+		}
+	}
+	for _, p := range c.CloneList {
+		names = append(names, p.Override)
+	}
+	return names
+}
+
+// Measurements of the profiles not selected - those also emitted by a
+// selected profile are kept. profiles[i] is the profile of cfgs[i].
+func excludedMeasurements(cfgs []*maker.TelegrafConfig, profiles []string, selected func(string) bool) []string {
+	kept := make(map[string]bool)
+	for i, c := range cfgs {
+		if selected(profiles[i]) {
+			for _, m := range configMeasurements(c) {
+				kept[m] = true
+			}
+		}
+	}
+	excluded := make([]string, 0)
+	for i, c := range cfgs {
+		if selected(profiles[i]) {
+			continue
+		}
+		for _, m := range configMeasurements(c) {
+			if m != "" && !kept[m] {
+				kept[m] = true
+				excluded = append(excluded, m)
+			}
+		}
+	}
+	sort.Strings(excluded)
+	return excluded
+}
@@ -0,0 +1,279 @@
+package association
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"jtso/maker"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Severity of a lint issue
+const (
+	LINT_ERROR   string = "error"
+	LINT_WARNING string = "warning"
+)
+
+type LintIssue struct {
+	Level string `json:"level"`
+	File  string `json:"file"`
+	Msg   string `json:"msg"`
+}
+
+// Result of the validation of a profile package
+type LintReport struct {
+	Profile string      `json:"profile"`
+	Valid   bool        `json:"valid"`
+	Version int         `json:"version"`
+	Signer  string      `json:"signer,omitempty"`
+	Issues  []LintIssue `json:"issues"`
+}
+
+func (r *LintReport) add(level string, file string, format string, a ...interface{}) {
+	r.Issues = append(r.Issues, LintIssue{Level: level, File: file, Msg: fmt.Sprintf(format, a...)})
+	if level == LINT_ERROR {
+		r.Valid = false
+	}
+}
+
+// Errors of the report as a single string
+func (r *LintReport) Error() string {
+	msgs := make([]string, 0)
+	for _, i := range r.Issues {
+		if i.Level == LINT_ERROR {
+			msgs = append(msgs, i.File+": "+i.Msg)
+		}
+	}
+	return strings.Join(msgs, " - ")
+}
+
+// Check a version expression of the definition file: all, a version or an
+// operator (==, >>, <<, >=, <=) followed by a version
+func ValidVersion(v string) error {
+	if v == "all" {
+		return nil
+	}
+	if len(v) <= 2 {
+		return fmt.Errorf("version expression %q is too short", v)
+	}
+	if unicode.IsDigit(rune(v[0])) && unicode.IsDigit(rune(v[1])) {
+		return nil
+	}
+	switch v[0:2] {
+	case "==", ">>", "<<", ">=", "<=":
+	default:
+		return fmt.Errorf("unknown operator %q in version expression %q", v[0:2], v)
+	}
+	if !unicode.IsDigit(rune(v[2])) {
+		return fmt.Errorf("version expression %q must start with a digit after the operator", v)
+	}
+	return nil
+}
+
+// Validate a profile package. The archive is extracted in a temporary
+// directory - nothing is written in the profile directories.
+func LintPackage(tgzPath string) *LintReport {
+	name := strings.TrimSuffix(filepath.Base(tgzPath), ".tgz")
+	r := &LintReport{Profile: name, Valid: true, Issues: make([]LintIssue, 0)}
+
+	if !strings.HasSuffix(tgzPath, ".tgz") {
+		r.add(LINT_ERROR, filepath.Base(tgzPath), "package must be a .tgz file")
+		return r
+	}
+
+	tmp, err := os.MkdirTemp("", "jtso-lint-")
+	if err != nil {
+		r.add(LINT_ERROR, "", "unable to create a temporary directory: %v", err)
+		return r
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := ExtractPackage(tgzPath, tmp, name); err != nil {
+		r.add(LINT_ERROR, filepath.Base(tgzPath), "unsafe or invalid archive: %v", err)
+		return r
+	}
+
+	lintDir(r, filepath.Join(tmp, name))
+	return r
+}
+
+// Validate an extracted profile directory
+func lintDir(r *LintReport, dir string) {
+	if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+		r.add(LINT_ERROR, r.Profile+"/", "the package must contain a top directory named %s", r.Profile)
+		return
+	}
+
+	// definition file and its schema
+	content, err := os.ReadFile(filepath.Join(dir, "definition.json"))
+	if err != nil {
+		r.add(LINT_ERROR, "definition.json", "missing definition file")
+		return
+	}
+	def := new(DefProfile)
+	if err := json.Unmarshal(content, def); err != nil {
+		r.add(LINT_ERROR, "definition.json", "invalid JSON: %v", err)
+		return
+	}
+	strict := json.NewDecoder(bytes.NewReader(content))
+	strict.DisallowUnknownFields()
+	if err := strict.Decode(new(DefProfile)); err != nil {
+		r.add(LINT_WARNING, "definition.json", "%v - it will be ignored", err)
+	}
+	r.Version = def.Version
+	if def.Version <= 0 {
+		r.add(LINT_ERROR, "definition.json", "version must be a positive integer")
+	}
+	if strings.TrimSpace(def.Description) == "" {
+		r.add(LINT_WARNING, "definition.json", "empty description")
+	}
+
+	// parameters and their defaults
+	declared := make(map[string]bool)
+	for i := range def.Parameters {
+		p := &def.Parameters[i]
+		if !paramNameRegex.MatchString(p.Name) || p.Name == PARAM_DEVICES {
+			r.add(LINT_ERROR, "definition.json", "invalid parameter name %q", p.Name)
+			continue
+		}
+		if declared[p.Name] {
+			r.add(LINT_ERROR, "definition.json", "parameter %s is declared twice", p.Name)
+			continue
+		}
+		declared[p.Name] = true
+		if _, err := p.defaultValue(); err != nil {
+			r.add(LINT_ERROR, "definition.json", "parameter %v", err)
+		}
+	}
+	defaults := def.paramDefaults()
+
+	// extended and included profiles are resolved once installed
+	if err := def.checkBases(r.Profile); err != nil {
+		r.add(LINT_ERROR, "definition.json", "%v", err)
+	} else if len(def.bases()) > 0 {
+		r.add(LINT_WARNING, "definition.json", "requires the profile(s) %s", strings.Join(def.bases(), ", "))
+	}
+
+	// telegraf configurations of each family
+	families := []struct {
+		name string
+		cfgs []Config
+	}{
+		{"mx", def.TelCfg.MxCfg}, {"ptx", def.TelCfg.PtxCfg}, {"acx", def.TelCfg.AcxCfg},
+		{"ex", def.TelCfg.ExCfg}, {"qfx", def.TelCfg.QfxCfg}, {"srx", def.TelCfg.SrxCfg},
+		{"crpd", def.TelCfg.CrpdCfg}, {"cptx", def.TelCfg.CptxCfg}, {"vmx", def.TelCfg.VmxCfg},
+		{"vsrx", def.TelCfg.VsrxCfg}, {"vjunos", def.TelCfg.VjunosCfg}, {"vevo", def.TelCfg.VevoCfg},
+	}
+	total := 0
+	checked := make(map[string]bool)
+	for _, f := range families {
+		family := f.name
+		for _, c := range f.cfgs {
+			total++
+			if err := ValidVersion(c.Version); err != nil {
+				r.add(LINT_ERROR, "definition.json", "%s: %v", family, err)
+			}
+			if c.Config == "" {
+				r.add(LINT_ERROR, "definition.json", "%s: empty conf for version %s", family, c.Version)
+				continue
+			}
+			if checked[c.Config] {
+				continue
+			}
+			checked[c.Config] = true
+			lintTelegraf(r, dir, c.Config, defaults)
+		}
+	}
+	if total == 0 {
+		r.add(LINT_WARNING, "definition.json", "no telegraf configuration for any family")
+	}
+
+	// grafana dashboards
+	for _, d := range def.GrafaCfg {
+		content, err := os.ReadFile(filepath.Join(dir, d))
+		if err != nil {
+			r.add(LINT_ERROR, d, "missing dashboard file")
+			continue
+		}
+		content, err = ApplyParams(content, defaults)
+		if err != nil {
+			r.add(LINT_ERROR, d, "%v", err)
+			continue
+		}
+		if !json.Valid(content) {
+			r.add(LINT_ERROR, d, "dashboard is not a valid JSON file")
+		}
+	}
+
+	// kapacitor tick scripts
+	for _, k := range def.KapaCfg {
+		content, err := os.ReadFile(filepath.Join(dir, k))
+		if err != nil {
+			r.add(LINT_ERROR, k, "missing tick script")
+			continue
+		}
+		if len(bytes.TrimSpace(content)) == 0 {
+			r.add(LINT_ERROR, k, "empty tick script")
+		}
+		if _, err := ApplyParams(content, defaults); err != nil {
+			r.add(LINT_ERROR, k, "%v", err)
+		}
+		if !strings.HasSuffix(k, ".tick") {
+			r.add(LINT_WARNING, k, "tick script without the .tick extension")
+		}
+	}
+}
+
+// Check a telegraf JSON file can be loaded by the maker once rendered with
+// the default values of the parameters
+func lintTelegraf(r *LintReport, dir string, file string, defaults map[string]string) {
+	content, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		r.add(LINT_ERROR, file, "missing telegraf configuration")
+		return
+	}
+	content, err = ApplyParams(content, defaults)
+	if err != nil {
+		r.add(LINT_ERROR, file, "%v", err)
+		return
+	}
+	var cfg maker.TelegrafConfig
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		r.add(LINT_ERROR, file, "invalid telegraf configuration: %v", err)
+		return
+	}
+	if len(cfg.GnmiList) == 0 && len(cfg.NetconfList) == 0 {
+		r.add(LINT_WARNING, file, "no gNMI or NETCONF input defined")
+	}
+	for _, g := range cfg.GnmiList {
+		switch g.Encoding {
+		case "", "proto", "json", "json_ietf", "bytes":
+		default:
+			r.add(LINT_WARNING, file, "unknown gNMI encoding %q", g.Encoding)
+		}
+		if _, err := time.ParseDuration(g.Redial); g.Redial != "" && err != nil {
+			r.add(LINT_ERROR, file, "invalid redial %q of gNMI input %q", g.Redial, g.Group)
+		}
+		for _, sub := range g.Subs {
+			switch sub.Mode {
+			case "", "sample", "on_change", "target_defined":
+			default:
+				r.add(LINT_ERROR, file, "unknown mode %q of gNMI subscription %s", sub.Mode, sub.Name)
+			}
+		}
+		for _, tag := range g.TagSubs {
+			if len(tag.Elements) == 0 {
+				r.add(LINT_WARNING, file, "tag subscription %s has no elements to match", tag.Name)
+			}
+		}
+	}
+	for _, n := range cfg.NetconfList {
+		if _, err := time.ParseDuration(n.Redial); n.Redial != "" && err != nil {
+			r.add(LINT_ERROR, file, "invalid redial %q of NETCONF input %q", n.Redial, n.Group)
+		}
+	}
+}
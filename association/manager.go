@@ -1,9 +1,11 @@
 package association
 
 import (
-	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"jtso/config"
 	"jtso/logger"
@@ -13,8 +15,6 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
-
-	"github.com/walle/targz"
 )
 
 type Config struct {
@@ -40,26 +40,254 @@ type Telegraf struct {
 }
 
 type DefProfile struct {
-	Version     int      `json:"version"`
-	Description string   `json:"description"`
-	TelCfg      Telegraf `json:"telegraf"`
-	KapaCfg     []string `json:"kapacitor"`
-	GrafaCfg    []string `json:"grafana"`
+	Version     int         `json:"version"`
+	Description string      `json:"description"`
+	TelCfg      Telegraf    `json:"telegraf"`
+	KapaCfg     []string    `json:"kapacitor"`
+	GrafaCfg    []string    `json:"grafana"`
+	Parameters  []Parameter `json:"parameters,omitempty"`
+	// Profiles merged into this one
+	Extends  string   `json:"extends,omitempty"`
+	Includes []string `json:"includes,omitempty"`
 }
 
 type FileTgz struct {
-	Filename   string
-	Present    bool
-	Hash       string
+	Filename string
+	Present  bool
+	// SHA-256 of the package and of its detached signature
+	Hash    string
+	SigHash string
+	// Identity of the trusted key which signed the package
+	Signer     string
 	Definition *DefProfile
+	// The profile followed by the profiles it extends or includes
+	Components   []string
+	ComposeError string
 }
 
 var ActiveProfiles map[string]FileTgz
 var ProfileLock *sync.Mutex
 
+// Serialize the profile scans
+var scanMu sync.Mutex
+
+// Hash of the rejected packages - avoid checking them again
+var rejected map[string]string
+
+// Source, size and modification time of a package and of its signature -
+// packages with an unchanged stamp are not hashed again
+type stamp struct {
+	src     string
+	size    int64
+	mod     int64
+	sigSize int64
+	sigMod  int64
+}
+
+var stamps map[string]stamp
+
+// Stamps of the latest packages of the pinned profiles already archived
+var latestStamps map[string]stamp
+
 func init() {
 	ActiveProfiles = make(map[string]FileTgz)
 	ProfileLock = new(sync.Mutex)
+	rejected = make(map[string]string)
+	stamps = make(map[string]stamp)
+	latestStamps = make(map[string]stamp)
+}
+
+func packageStamp(src string) stamp {
+	st := stamp{src: src}
+	if fi, err := os.Stat(src); err == nil {
+		st.size, st.mod = fi.Size(), fi.ModTime().UnixNano()
+	}
+	if fi, err := os.Stat(src + SIG_EXT); err == nil {
+		st.sigSize, st.sigMod = fi.Size(), fi.ModTime().UnixNano()
+	}
+	return st
+}
+
+// Private copy of a package and of its signature. The copy is hashed, verified,
+// linted and extracted so that a package replaced during the scan is never
+// deployed with the checks of another one.
+type pkgSnapshot struct {
+	dir     string
+	path    string
+	hash    string
+	sigHash string
+}
+
+// Copy a file and return its SHA-256 - empty string if the file does not exist
+func copyHashed(src string, dst string) (string, error) {
+	in, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), in); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Take a private copy of a package and of its signature
+func snapshotPackage(filename string, src string) (*pkgSnapshot, error) {
+	dir, err := os.MkdirTemp("", "jtso-pkg-")
+	if err != nil {
+		return nil, err
+	}
+	snap := &pkgSnapshot{dir: dir, path: filepath.Join(dir, filename+".tgz")}
+	if snap.hash, err = copyHashed(src, snap.path); err == nil && snap.hash == "" {
+		err = os.ErrNotExist
+	}
+	if err == nil {
+		snap.sigHash, err = copyHashed(src+SIG_EXT, snap.path+SIG_EXT)
+	}
+	if err != nil {
+		snap.remove()
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (s *pkgSnapshot) remove() {
+	os.RemoveAll(s.dir)
+}
+
+// Profile version extracted aside, waiting to replace the active one
+type stagedProfile struct {
+	entry FileTgz
+	dir   string
+}
+
+// Check, extract aside and archive the copy of a package then load its definition
+func stagePackage(filename string, src string, snap *pkgSnapshot) (*stagedProfile, error) {
+	report, ok := acceptPackage(filename, src, snap.path, snap.hash+snap.sigHash)
+	if !ok {
+		return nil, fmt.Errorf("profile %s rejected", filename)
+	}
+	dir, err := os.MkdirTemp(ACTIVE_PROFILES, ".stage-")
+	if err != nil {
+		logger.Log.Errorf("Unable to create the staging directory of profile %s: %v", filename, err)
+		return nil, err
+	}
+	sp := &stagedProfile{dir: dir}
+	if err := ExtractPackage(snap.path, dir, filename); err != nil {
+		logger.Log.Errorf("Profile package %s rejected - unable to extract it: %v", filename, err)
+		rejected[src] = snap.hash + snap.sigHash
+		sp.remove()
+		return nil, err
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, filename, "definition.json"))
+	if err != nil {
+		logger.Log.Errorf("Unable to read defintion.json for profile %s: %v", filename, err)
+		sp.remove()
+		return nil, err
+	}
+	def := new(DefProfile)
+	if err := json.Unmarshal(content, def); err != nil {
+		logger.Log.Errorf("Unable to parse defintion.json for profile %s: %v", filename, err)
+		sp.remove()
+		return nil, err
+	}
+
+	// Legacy code - will be removed further.
+	//
+	// Copy cheatsheet image in the right assets directories
+	// source, err := os.Open(ACTIVE_PROFILES + filename + "/" + entry.Definition.Cheatsheet) //open the source file
+	// if err != nil {
+	// 	logger.Log.Errorf("Unable to open the Cheatsheet file %s - err: %v", entry.Definition.Cheatsheet, err)
+	// 	continue
+	// }
+	// defer source.Close()
+	// destination, err := os.Create("html/assets/img/" + entry.Definition.Cheatsheet) //create the destination file
+	// if err != nil {
+	// 	logger.Log.Errorf("Unable to open the destination Cheatsheet %s - err: %v", entry.Definition.Cheatsheet, err)
+	// 	continue
+	// }
+	// defer destination.Close()
+	// _, err = io.Copy(destination, source) //copy the contents of source to destination file
+	// if err != nil {
+	// 	logger.Log.Errorf("Unable to update the Cheatsheet %s - err: %v", entry.Definition.Cheatsheet, err)
+	// 	continue
+	// }
+
+	archivePackage(filename, snap.path, snap.hash, report)
+	sp.entry = FileTgz{
+		Filename:   filename,
+		Present:    true,
+		Hash:       snap.hash,
+		SigHash:    snap.sigHash,
+		Signer:     report.Signer,
+		Definition: def,
+	}
+	return sp, nil
+}
+
+// Replace the active version of the profile - ProfileLock must be held
+func (sp *stagedProfile) install() error {
+	defer sp.remove()
+	name := sp.entry.Filename
+	final := ACTIVE_PROFILES + name
+	old := filepath.Join(sp.dir, name+".old")
+	if err := os.Rename(final, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(filepath.Join(sp.dir, name), final); err != nil {
+		os.Rename(old, final)
+		return err
+	}
+	return nil
+}
+
+func (sp *stagedProfile) remove() {
+	os.RemoveAll(sp.dir)
+}
+
+// Check the signature and lint the copy of a package before its extraction.
+// Return the lint report and false if the package must be rejected.
+func acceptPackage(filename string, src string, copyPath string, hash string) (*LintReport, bool) {
+	if rejected[src] == hash {
+		return nil, false
+	}
+	signer, err := CheckSignature(copyPath)
+	if err != nil {
+		logger.Log.Errorf("Profile %s rejected by the signature policy: %v", filename, err)
+		// checked again on the next scan if the failure is transient
+		var te *transientError
+		if !errors.As(err, &te) {
+			rejected[src] = hash
+		}
+		return nil, false
+	}
+	report := LintPackage(copyPath)
+	report.Signer = signer
+	for _, i := range report.Issues {
+		if i.Level == LINT_WARNING {
+			logger.Log.Warnf("Profile %s - %s: %s", filename, i.File, i.Msg)
+		}
+	}
+	if !report.Valid {
+		logger.Log.Errorf("Profile %s rejected: %s", filename, report.Error())
+		rejected[src] = hash
+		return nil, false
+	}
+	delete(rejected, src)
+	return report, true
 }
 
 func CleanActiveDirectory() error {
@@ -83,16 +311,14 @@ func CleanActiveDirectory() error {
 
 }
 func PeriodicCheck(cfg *config.ConfigContainer) {
+	// one scan at a time - the scan owns the stamps and the rejected packages
+	scanMu.Lock()
+	defer scanMu.Unlock()
+
 	logger.Log.Debug("Start periodic update of the profile db - scanning is starting")
 
 	needRestart := make([]string, 0)
-
-	// reset current flag for Active profile
-	ProfileLock.Lock()
-	for k, v := range ActiveProfiles {
-		v.Present = false
-		ActiveProfiles[k] = v
-	}
+	updated := make(map[string]bool)
 
 	// retrieve all tgz
 	dir, err := os.Open(PROFILES)
@@ -101,193 +327,100 @@ func PeriodicCheck(cfg *config.ConfigContainer) {
 		return
 	}
 	files, err := dir.ReadDir(0)
+	dir.Close()
 	if err != nil {
 		logger.Log.Errorf("Unable to read %s directory: %v", PROFILES, err)
 		return
 	}
 
+	pins, err := sqlite.GetProfilePins()
+	if err != nil {
+		pins = make(map[string]string)
+	}
+
+	// versions currently deployed
+	ProfileLock.Lock()
+	current := make(map[string]FileTgz, len(ActiveProfiles))
+	for k, v := range ActiveProfiles {
+		current[k] = v
+	}
+	ProfileLock.Unlock()
+
+	// hash, lint and extract the changed packages without holding the lock
+	seen := make(map[string]bool)
+	staged := make(map[string]*stagedProfile)
 	for _, file := range files {
-		if strings.Contains(file.Name(), "tgz") {
-			filename := strings.Replace(file.Name(), ".tgz", "", -1)
-
-			entry, ok := ActiveProfiles[filename]
-
-			if ok {
-				// existing profile - check if update
-				// compute the hash of the file
-				tmpFile, err := os.Open(PROFILES + filename + ".tgz")
-				if err != nil {
-					logger.Log.Errorf("Unable to open file %s: %v", filename, err)
-					continue
-				}
-				hash := md5.New()
-				if _, err := io.Copy(hash, tmpFile); err != nil {
-					logger.Log.Errorf("Unable to compute hash for file %s: %v", filename, err)
-					tmpFile.Close()
-					continue
-				}
-				tmpFile.Close()
-				hashInBytes := hash.Sum(nil)[:16]
-				MD5String := hex.EncodeToString(hashInBytes)
-
-				if ActiveProfiles[filename].Hash != MD5String {
-					// Update profile
-					err := os.RemoveAll(ACTIVE_PROFILES + filename + "/")
-					if err != nil {
-						logger.Log.Errorf("Unable to remove profile %s: %v", filename, err)
-						continue
-					}
-					err = targz.Extract(PROFILES+filename+".tgz", ACTIVE_PROFILES)
-					if err != nil {
-						logger.Log.Errorf("Unable to extract new profile %s: %v", filename, err)
-						continue
-					}
-
-					// update definition JSON file
-					jsonFile, err := os.Open(ACTIVE_PROFILES + filename + "/definition.json")
-					if err != nil {
-						logger.Log.Errorf("Unable to open defintion.json for profile %s: %v", filename, err)
-						continue
-					}
-
-					byteValue, err := io.ReadAll(jsonFile)
-					jsonFile.Close()
-					if err != nil {
-						logger.Log.Errorf("Unable to read defintion.json for profile %s: %v", filename, err)
-						continue
-					}
-					entry.Definition = new(DefProfile)
-					// push json into definition structure
-					if err := json.Unmarshal(byteValue, entry.Definition); err != nil {
-						logger.Log.Errorf("Unable to parse defintion.json for profile %s: %v", filename, err)
-						continue
-					}
-					entry.Hash = MD5String
-
-					// Legacy code - will be removed further.
-					//
-					// Copy cheatsheet image in the right assets directories
-					// source, err := os.Open(ACTIVE_PROFILES + filename + "/" + entry.Definition.Cheatsheet) //open the source file
-					// if err != nil {
-					// 	logger.Log.Errorf("Unable to open the Cheatsheet file %s - err: %v", entry.Definition.Cheatsheet, err)
-					// 	continue
-					// }
-					// defer source.Close()
-					// destination, err := os.Create("html/assets/img/" + entry.Definition.Cheatsheet) //create the destination file
-					// if err != nil {
-					// 	logger.Log.Errorf("Unable to open the destination Cheatsheet %s - err: %v", entry.Definition.Cheatsheet, err)
-					// 	continue
-					// }
-					// defer destination.Close()
-					// _, err = io.Copy(destination, source) //copy the contents of source to destination file
-					// if err != nil {
-					// 	logger.Log.Errorf("Unable to update the Cheatsheet %s - err: %v", entry.Definition.Cheatsheet, err)
-					// 	continue
-					// }
-
-					logger.Log.Infof("Profile %s has been updated", filename)
-					for _, rtr := range sqlite.RtrList {
-						if rtr.Profile == 0 {
-							continue
-						}
-						for _, asso := range sqlite.AssoList {
-							if asso.Shortname != rtr.Shortname {
-								continue
-							}
-							for _, p := range asso.Assos {
-								if p == filename {
-									needRestart = append(needRestart, rtr.Family)
-									goto NextRtr
-								}
-							}
-						}
-					NextRtr:
-					}
-				}
+		if !strings.HasSuffix(file.Name(), ".tgz") {
+			continue
+		}
+		filename := strings.TrimSuffix(file.Name(), ".tgz")
+
+		// deploy the pinned version if any - the latest one is still archived
+		src := packageSource(filename, pins[filename])
+		if src != PROFILES+file.Name() {
+			archiveLatest(filename, packageStamp(PROFILES+file.Name()))
+		} else {
+			delete(latestStamps, filename)
+		}
 
-				entry.Present = true
-				ActiveProfiles[filename] = entry
-
-			} else {
-				// new profile detected
-				entry := FileTgz{}
-				entry.Filename = filename
-				entry.Present = true
-				entry.Definition = new(DefProfile)
-
-				// compute the hash of the file
-				tmpFile, err := os.Open(PROFILES + filename + ".tgz")
-				if err != nil {
-					logger.Log.Errorf("Unable to open file %s: %v", filename, err)
-					continue
-				}
-				hash := md5.New()
-				if _, err := io.Copy(hash, tmpFile); err != nil {
-					logger.Log.Errorf("Unable to compute hash for file %s: %v", filename, err)
-					tmpFile.Close()
-					continue
-				}
-				tmpFile.Close()
-				hashInBytes := hash.Sum(nil)[:16]
-				MD5String := hex.EncodeToString(hashInBytes)
-				entry.Hash = MD5String
-
-				err = targz.Extract(PROFILES+filename+".tgz", ACTIVE_PROFILES)
-				if err != nil {
-					logger.Log.Errorf("Unable to extract new profile %s: %v", filename, err)
-					continue
-				}
+		entry, ok := current[filename]
 
-				// open definition JSON file
-				jsonFile, err := os.Open(ACTIVE_PROFILES + filename + "/definition.json")
-				if err != nil {
-					logger.Log.Errorf("Unable to open defintion.json for profile %s: %v", filename, err)
-					continue
-				}
+		// skip the packages untouched since the last scan
+		seen[filename] = true
+		st := packageStamp(src)
+		if stamps[filename] == st {
+			continue
+		}
 
-				byteValue, err := io.ReadAll(jsonFile)
-				jsonFile.Close()
-				if err != nil {
-					logger.Log.Errorf("Unable to read defintion.json for profile %s: %v", filename, err)
-					continue
-				}
+		snap, err := snapshotPackage(filename, src)
+		if err != nil {
+			logger.Log.Errorf("Unable to copy the package of profile %s: %v", filename, err)
+			continue
+		}
+		stamps[filename] = st
+		if ok && entry.Hash == snap.hash && entry.SigHash == snap.sigHash {
+			snap.remove()
+			continue
+		}
 
-				// push json into definition structure
-				if err := json.Unmarshal(byteValue, entry.Definition); err != nil {
-					logger.Log.Errorf("Unable to parse defintion.json for profile %s: %v", filename, err)
-					continue
-				}
+		// Keep the current version if the new package is invalid
+		sp, err := stagePackage(filename, src, snap)
+		snap.remove()
+		if err != nil {
+			continue
+		}
+		staged[filename] = sp
+	}
 
-				// Legacy code - will be removed further.
-				//
-				// Copy cheatsheet image in the right assets directories
-				// source, err := os.Open(ACTIVE_PROFILES + filename + "/" + entry.Definition.Cheatsheet) //open the source file
-				// if err != nil {
-				// 	logger.Log.Errorf("Unable to open the Cheatsheet file %s - err: %v", entry.Definition.Cheatsheet, err)
-				// 	continue
-				// }
-				// defer source.Close()
-				// destination, err := os.Create("html/assets/img/" + entry.Definition.Cheatsheet) //create the destination file
-				// if err != nil {
-				// 	logger.Log.Errorf("Unable to open the destination Cheatsheet %s - err: %v", entry.Definition.Cheatsheet, err)
-				// 	continue
-				// }
-				// defer destination.Close()
-				// _, err = io.Copy(destination, source) //copy the contents of source to destination file
-				// if err != nil {
-				// 	logger.Log.Errorf("Unable to update the Cheatsheet %s - err: %v", entry.Definition.Cheatsheet, err)
-				// 	continue
-				// }
-
-				ActiveProfiles[filename] = entry
-				logger.Log.Infof("New profile %s detected and added to active profiles", filename)
-			}
+	for k := range stamps {
+		if !seen[k] {
+			delete(stamps, k)
+			delete(latestStamps, k)
+		}
+	}
+
+	ProfileLock.Lock()
+	// swap the extracted versions
+	for filename, sp := range staged {
+		entry, ok := ActiveProfiles[filename]
+		if err := sp.install(); err != nil {
+			logger.Log.Errorf("Unable to install profile %s: %v", filename, err)
+			delete(stamps, filename)
+			continue
+		}
+		sp.entry.Components = entry.Components
+		ActiveProfiles[filename] = sp.entry
+		if ok {
+			logger.Log.Infof("Profile %s has been updated", filename)
+			updated[filename] = true
+		} else {
+			logger.Log.Infof("New profile %s detected and added to active profiles", filename)
 		}
 	}
 
 	// check now old profiles - where is no more tgz - clean them
 	for k, v := range ActiveProfiles {
-		if !v.Present {
+		if !seen[k] {
 			// Update profile
 			err := os.RemoveAll(ACTIVE_PROFILES + v.Filename)
 			if err != nil {
@@ -295,7 +428,30 @@ func PeriodicCheck(cfg *config.ConfigContainer) {
 			}
 			logger.Log.Infof("Legacy profile %s remove it", v.Filename)
 			delete(ActiveProfiles, k)
+			delete(rejected, PROFILES+k+".tgz")
+		}
+	}
 
+	// resolve extends/includes - profiles using an updated profile must be reconfigured
+	for _, p := range resolveCompositions() {
+		updated[p] = true
+	}
+	families := make(map[string]bool)
+	for _, rtr := range sqlite.RtrList {
+		if rtr.Profile == 0 || families[rtr.Family] {
+			continue
+		}
+		for _, asso := range sqlite.AssoList {
+			if asso.Shortname != rtr.Shortname {
+				continue
+			}
+			for _, p := range expandProfiles(asso.Assos) {
+				if updated[p] {
+					families[rtr.Family] = true
+					needRestart = append(needRestart, rtr.Family)
+					break
+				}
+			}
 		}
 	}
 	ProfileLock.Unlock()
@@ -0,0 +1,369 @@
+package association
+
+import (
+	"bytes"
+	"fmt"
+	"jtso/logger"
+	"jtso/sqlite"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Types of the profile parameters
+const (
+	PARAM_INT      string = "int"
+	PARAM_FLOAT    string = "float"
+	PARAM_BOOL     string = "bool"
+	PARAM_STRING   string = "string"
+	PARAM_DURATION string = "duration"
+)
+
+// Built-in parameter - regex matching the hostnames of the routers rendered
+// with a given set of values. Useful to scope TICKscripts.
+const PARAM_DEVICES string = "devices"
+
+// Parameter declared in definition.json and referenced as {{param.<name>}} in
+// the Telegraf JSON files, the TICKscripts and the dashboards
+type Parameter struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description,omitempty"`
+}
+
+// Serialize the rendering of the TICKscript variants and their cleanup - the
+// families are reconfigured concurrently
+var ticksMu sync.Mutex
+
+var paramNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+var placeholderRegex = regexp.MustCompile(`\{\{\s*param\.([A-Za-z0-9_]+)\s*\}\}`)
+
+// Check a value against the type of the parameter and return its canonical form
+func (p *Parameter) Check(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch p.Type {
+	case PARAM_INT:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s expects an integer", p.Name)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case PARAM_FLOAT:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s expects a number", p.Name)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case PARAM_BOOL:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s expects true or false", p.Name)
+		}
+		return strconv.FormatBool(b), nil
+	case PARAM_DURATION:
+		if _, err := time.ParseDuration(value); err != nil {
+			return "", fmt.Errorf("%s expects a duration like 10s or 5m", p.Name)
+		}
+		return value, nil
+	case PARAM_STRING:
+		// values are rendered as is in JSON and TICKscript strings
+		for _, c := range value {
+			if c == '"' || c == '\'' || c == '\\' || unicode.IsControl(c) {
+				return "", fmt.Errorf("%s must not contain quotes, backslashes or control characters", p.Name)
+			}
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("%s has an unknown type %q", p.Name, p.Type)
+	}
+}
+
+// Default value of the parameter in its canonical form
+func (p *Parameter) defaultValue() (string, error) {
+	switch d := p.Default.(type) {
+	case string:
+		return p.Check(d)
+	case float64:
+		return p.Check(strconv.FormatFloat(d, 'f', -1, 64))
+	case bool:
+		return p.Check(strconv.FormatBool(d))
+	case nil:
+		return "", fmt.Errorf("%s has no default value", p.Name)
+	default:
+		return "", fmt.Errorf("%s has an unsupported default value", p.Name)
+	}
+}
+
+// Declared parameter of a profile
+func (d *DefProfile) param(name string) *Parameter {
+	for i := range d.Parameters {
+		if d.Parameters[i].Name == name {
+			return &d.Parameters[i]
+		}
+	}
+	return nil
+}
+
+// Default values of the parameters of a profile - the invalid ones are ignored
+func (d *DefProfile) paramDefaults() map[string]string {
+	values := make(map[string]string)
+	for i := range d.Parameters {
+		if v, err := d.Parameters[i].defaultValue(); err == nil {
+			values[d.Parameters[i].Name] = v
+		}
+	}
+	values[PARAM_DEVICES] = ".*"
+	return values
+}
+
+// Replace the placeholders of a file - an unknown parameter is an error
+func ApplyParams(content []byte, values map[string]string) ([]byte, error) {
+	var missing []string
+	out := placeholderRegex.ReplaceAllFunc(content, func(m []byte) []byte {
+		name := string(placeholderRegex.FindSubmatch(m)[1])
+		v, ok := values[name]
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		return []byte(v)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unknown parameter(s) %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// Values of the parameters of a profile for a router: the defaults of the
+// profile, overridden by the values of the router group then of the router
+func ResolveParams(profile string, rtr *sqlite.RtrEntry) map[string]string {
+	p, ok := ActiveProfiles[profile]
+	if !ok || p.Definition == nil {
+		return map[string]string{}
+	}
+	values := p.Definition.paramDefaults()
+	group := sqlite.RouterGroups[rtr.Shortname]
+	for _, scope := range []string{sqlite.PARAM_SCOPE_GROUP, sqlite.PARAM_SCOPE_ROUTER} {
+		for _, v := range sqlite.ActiveParams {
+			if v.Profile != profile || v.Scope != scope {
+				continue
+			}
+			if (scope == sqlite.PARAM_SCOPE_GROUP && (group == "" || v.Target != group)) ||
+				(scope == sqlite.PARAM_SCOPE_ROUTER && v.Target != rtr.Shortname) {
+				continue
+			}
+			decl := p.Definition.param(v.Param)
+			if decl == nil {
+				continue
+			}
+			// the type may have changed with a new version of the profile
+			if value, err := decl.Check(v.Value); err == nil {
+				values[v.Param] = value
+			}
+		}
+	}
+	return values
+}
+
+// Stable signature of a set of values - the built-in parameters are excluded
+func paramsSignature(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if k != PARAM_DEVICES {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + values[k]
+	}
+	return strings.Join(parts, ",")
+}
+
+// Regex matching a list of hostnames
+func devicesRegex(hostnames []string) string {
+	quoted := make([]string, len(hostnames))
+	for i, h := range hostnames {
+		quoted[i] = regexp.QuoteMeta(h)
+	}
+	sort.Strings(quoted)
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// Values used to render the files of a collection - adds the built-in ones
+func renderValues(values map[string]string, routers []*sqlite.RtrEntry) map[string]string {
+	out := make(map[string]string, len(values)+1)
+	for k, v := range values {
+		out[k] = v
+	}
+	hostnames := make([]string, len(routers))
+	for i, r := range routers {
+		hostnames[i] = r.Hostname
+	}
+	out[PARAM_DEVICES] = devicesRegex(hostnames)
+	return out
+}
+
+// Routers sharing the same values of the parameters of a profile
+type paramVariant struct {
+	values  map[string]string
+	routers []*sqlite.RtrEntry
+}
+
+// Group the routers of the collections by profile and set of values
+func paramVariants() map[string]map[string]*paramVariant {
+	variants := make(map[string]map[string]*paramVariant)
+	for _, familyCollections := range Collections {
+		for _, c := range familyCollections {
+			for i, p := range c.ProfilesName {
+				if p == "" || i >= len(c.ProfilesParams) || c.ProfilesParams[i] == nil {
+					continue
+				}
+				if def := ActiveProfiles[p].Definition; def == nil || len(def.Parameters) == 0 {
+					continue
+				}
+				if _, ok := variants[p]; !ok {
+					variants[p] = make(map[string]*paramVariant)
+				}
+				sig := paramsSignature(c.ProfilesParams[i])
+				v, ok := variants[p][sig]
+				if !ok {
+					v = &paramVariant{values: c.ProfilesParams[i]}
+					variants[p][sig] = v
+				}
+				v.routers = append(v.routers, c.Routers...)
+			}
+		}
+	}
+	return variants
+}
+
+// Paths of the TICKscripts of a profile. Scripts of a profile with parameters
+// are rendered once per set of values in use - the path of a rendered script
+// changes with its values so that Kapacitor tasks are replaced. ticksMu must
+// be held.
+func profileTicks(p string, variants map[string]*paramVariant) []string {
+	def := ActiveProfiles[p].Definition
+	if len(def.Parameters) == 0 {
+		ticks := make([]string, 0, len(def.KapaCfg))
+		for _, d := range def.KapaCfg {
+			ticks = append(ticks, ACTIVE_PROFILES+p+"/"+d)
+		}
+		return ticks
+	}
+
+	sigs := make([]string, 0, len(variants))
+	for sig := range variants {
+		sigs = append(sigs, sig)
+	}
+	sort.Strings(sigs)
+
+	ticks := make([]string, 0)
+	for _, d := range def.KapaCfg {
+		content, err := os.ReadFile(ACTIVE_PROFILES + p + "/" + d)
+		if err != nil {
+			logger.Log.Errorf("Unable to open the tick script %s of profile %s: %v", d, p, err)
+			continue
+		}
+		for _, sig := range sigs {
+			values := renderValues(variants[sig].values, variants[sig].routers)
+			rendered, err := ApplyParams(content, values)
+			if err != nil {
+				logger.Log.Errorf("Unable to render the tick script %s of profile %s: %v", d, p, err)
+				continue
+			}
+			dst := fmt.Sprintf("%s%s/.params/%08x/%s", ACTIVE_PROFILES, p, hashStringFNV(sig+values[PARAM_DEVICES]), d)
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				logger.Log.Errorf("Unable to create the directory of %s: %v", dst, err)
+				continue
+			}
+			// a variant already rendered is kept as is
+			if current, err := os.ReadFile(dst); err == nil && bytes.Equal(current, rendered) {
+				ticks = append(ticks, dst)
+				continue
+			}
+			if err := os.WriteFile(dst, rendered, 0644); err != nil {
+				logger.Log.Errorf("Unable to write the tick script %s: %v", dst, err)
+				continue
+			}
+			ticks = append(ticks, dst)
+		}
+	}
+	return ticks
+}
+
+// Remove the TICKscript variants no collection uses anymore - ticksMu must be
+// held
+func cleanTicks(inUse []string) {
+	keep := make(map[string]bool)
+	for _, t := range inUse {
+		keep[filepath.Dir(t)] = true
+	}
+	for p := range ActiveProfiles {
+		root := ACTIVE_PROFILES + p + "/.params"
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			dir := filepath.Join(root, e.Name())
+			if keep[dir] {
+				continue
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				logger.Log.Errorf("Unable to remove the tick scripts %s: %v", dir, err)
+			}
+		}
+	}
+}
+
+// Values used to render the dashboards of a profile. A dashboard is shared by
+// all the routers: it gets the values of the routers when they all share the
+// same values, the default values otherwise.
+func dashboardValues(p string, def *DefProfile, variants map[string]*paramVariant) map[string]string {
+	if len(variants) == 1 {
+		for _, v := range variants {
+			return renderValues(v.values, v.routers)
+		}
+	}
+	if len(variants) > 1 {
+		logger.Log.Warnf("Routers of profile %s use %d sets of parameter values - its dashboards are rendered with the default values", p, len(variants))
+	}
+	return def.paramDefaults()
+}
+
+// Validate and save the value of a parameter of an active profile
+func SetParam(v *sqlite.ParamValue) error {
+	if v.Scope != sqlite.PARAM_SCOPE_GROUP && v.Scope != sqlite.PARAM_SCOPE_ROUTER {
+		return fmt.Errorf("unknown scope %s - expected group or router", v.Scope)
+	}
+	if strings.TrimSpace(v.Target) == "" {
+		return fmt.Errorf("the %s is missing", v.Scope)
+	}
+	ProfileLock.Lock()
+	p, ok := ActiveProfiles[v.Profile]
+	ProfileLock.Unlock()
+	if !ok || p.Definition == nil {
+		return fmt.Errorf("unknown profile %s", v.Profile)
+	}
+	decl := p.Definition.param(v.Param)
+	if decl == nil {
+		return fmt.Errorf("profile %s has no parameter %s", v.Profile, v.Param)
+	}
+	if v.Value != "" {
+		value, err := decl.Check(v.Value)
+		if err != nil {
+			return err
+		}
+		v.Value = value
+	}
+	return sqlite.SetParamValue(v)
+}
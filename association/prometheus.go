@@ -0,0 +1,55 @@
+package association
+
+import (
+	"jtso/logger"
+	"jtso/maker"
+	"jtso/sqlite"
+	"os"
+)
+
+// File holding the scrape endpoint of a family in client mode
+const PROMETHEUS_FILE string = "prometheus.conf"
+
+// Order of the families - the scrape endpoint of a family listens on the
+// configured port plus the index of the family
+var prometheusFamilies = []string{"mx", "ptx", "acx", "ex", "qfx", "srx", "crpd", "cptx", "vmx", "vsrx", "vjunos", "vevo", "ondemand"}
+
+// Listen port of the scrape endpoint of a family
+func PrometheusPort(family string) int {
+	for i, f := range prometheusFamilies {
+		if f == family {
+			return sqlite.ActivePrometheusConfig.Port + i
+		}
+	}
+	return sqlite.ActivePrometheusConfig.Port
+}
+
+// Remote write mode - each rendered config pushes its own metrics
+func prometheusRemoteWrite() bool {
+	return sqlite.ActivePrometheusConfig.Enabled == 1 && sqlite.ActivePrometheusConfig.Mode == "remotewrite"
+}
+
+// Client mode - one scrape endpoint per family
+func prometheusClient() bool {
+	return sqlite.ActivePrometheusConfig.Enabled == 1 && sqlite.ActivePrometheusConfig.Mode != "remotewrite"
+}
+
+// Write the scrape endpoint shared by all the collections of a family. All
+// the metrics of the Telegraf instance reach it - fieldpass is the union of
+// the fieldpass of the collections.
+func writePrometheusClient(family string, path string, fieldpass []string) {
+	prom := maker.PrometheusOutput{
+		Port:      PrometheusPort(family),
+		Fieldpass: fieldpass,
+	}
+	routePrometheus(&prom)
+	payload, err := maker.RenderPrometheus([]maker.PrometheusOutput{prom})
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(path+PROMETHEUS_FILE, []byte(*payload), 0644); err != nil {
+		logger.Log.Errorf("Error writing to file %s: %v", path+PROMETHEUS_FILE, err)
+		return
+	}
+	logger.Log.Infof("Prometheus scrape endpoint of the family %s listens on port %d", family, PrometheusPort(family))
+}
@@ -0,0 +1,70 @@
+package association
+
+import (
+	"jtso/maker"
+	"jtso/sqlite"
+	"path"
+)
+
+// Routing policy of an output - the fieldpass of the policy replaces the one
+// declared by the profiles
+func outputRoute(output string, fieldpass []string) ([]string, []string, map[string][]string) {
+	r := sqlite.ActiveRoutes[output]
+	if f := r.FieldpassList(); len(f) > 0 {
+		fieldpass = f
+	}
+	tags := r.TagpassMap()
+	if len(tags) == 0 {
+		tags = nil
+	}
+	return r.NamepassList(), fieldpass, tags
+}
+
+// Names matching at least one of the globs
+func matchNames(names []string, globs []string) []string {
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		for _, g := range globs {
+			if ok, _ := path.Match(g, n); ok {
+				out = append(out, n)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func routePrometheus(o *maker.PrometheusOutput) {
+	o.Namepass, o.Fieldpass, o.Tagpass = outputRoute("prometheus", o.Fieldpass)
+}
+
+// Apply the routing policies to the outputs of a rendered config
+func applyRoutes(cfg *maker.TelegrafConfig) {
+	for i := range cfg.InfluxList {
+		o := &cfg.InfluxList[i]
+		o.Namepass, o.Fieldpass, o.Tagpass = outputRoute("influx", o.Fieldpass)
+	}
+	kafka := make([]maker.KafkaOutput, 0, len(cfg.KafkaList))
+	for _, o := range cfg.KafkaList {
+		namepass, fieldpass, tagpass := outputRoute("kafka", o.Fieldpass)
+		// the outputs of the topic routing rules keep their measurements
+		// accepted by the policy - dropped if none is left
+		if len(o.Namepass) == 0 {
+			o.Namepass = namepass
+		} else if len(namepass) > 0 {
+			if o.Namepass = matchNames(o.Namepass, namepass); len(o.Namepass) == 0 {
+				continue
+			}
+		}
+		o.Fieldpass, o.Tagpass = fieldpass, tagpass
+		kafka = append(kafka, o)
+	}
+	cfg.KafkaList = kafka
+	for i := range cfg.PrometheusList {
+		routePrometheus(&cfg.PrometheusList[i])
+	}
+	for i := range cfg.FileList {
+		o := &cfg.FileList[i]
+		o.Namepass, o.Fieldpass, o.Tagpass = outputRoute("file", o.Fieldpass)
+	}
+}
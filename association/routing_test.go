@@ -0,0 +1,33 @@
+package association
+
+import (
+	"jtso/maker"
+	"jtso/sqlite"
+	"reflect"
+	"testing"
+)
+
+func TestApplyRoutesKafkaRules(t *testing.T) {
+	saved := sqlite.ActiveRoutes
+	t.Cleanup(func() { sqlite.ActiveRoutes = saved })
+	sqlite.ActiveRoutes = map[string]sqlite.OutputRoute{"kafka": {Output: "kafka", Namepass: "if*, COMMIT"}}
+
+	cfg := maker.TelegrafConfig{KafkaList: []maker.KafkaOutput{
+		{Topic: "interfaces", Namepass: []string{"ifcounters", "lldp"}},
+		{Topic: "bgp", Namepass: []string{"bgp"}},
+		{Topic: "jtso", Namedrop: []string{"bgp", "ifcounters", "lldp"}},
+	}}
+	applyRoutes(&cfg)
+
+	topics := make(map[string][]string)
+	for _, o := range cfg.KafkaList {
+		topics[o.Topic] = o.Namepass
+	}
+	want := map[string][]string{
+		"interfaces": {"ifcounters"},
+		"jtso":       {"if*", "COMMIT"},
+	}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("kafka outputs = %v, want %v", topics, want)
+	}
+}
@@ -0,0 +1,26 @@
+package association
+
+import (
+	"jtso/container"
+	"jtso/maker"
+	"jtso/sqlite"
+)
+
+// Credentials of the Telegraf containers - the rendered files only reference
+// these variables
+func telegrafEnv() map[string]string {
+	return map[string]string{
+		maker.ENV_GNMI_USERNAME:       sqlite.ActiveCred.GnmiUser,
+		maker.ENV_GNMI_PASSWORD:       sqlite.ActiveCred.GnmiPwd,
+		maker.ENV_NETCONF_USERNAME:    sqlite.ActiveCred.NetconfUser,
+		maker.ENV_NETCONF_PASSWORD:    sqlite.ActiveCred.NetconfPwd,
+		maker.ENV_INFLUX_PASSWORD:     sqlite.ActiveInfluxConfig.Password,
+		maker.ENV_INFLUX_TOKEN:        sqlite.ActiveInfluxConfig.Token,
+		maker.ENV_KAFKA_SASL_PASSWORD: sqlite.ActiveKafkaOptions.SaslPwd,
+	}
+}
+
+// Restart the Telegraf container of a family with the current credentials
+func restartTelegraf(family string) error {
+	return container.RestartWithEnv("telegraf_"+family, telegrafEnv())
+}
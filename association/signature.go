@@ -0,0 +1,159 @@
+package association
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"jtso/logger"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Signature policies of the profile packages
+const (
+	SIGN_OFF     string = "off"
+	SIGN_WARN    string = "warn"
+	SIGN_ENFORCE string = "enforce"
+)
+
+// Extension of the detached signature stored next to the package
+const SIG_EXT string = ".sig"
+
+var signPolicy string = SIGN_OFF
+var trustedKeysDir string
+
+// Set the signature policy and the directory of the trusted public keys
+func SetSignPolicy(policy string, keysDir string) error {
+	switch policy {
+	case SIGN_OFF, SIGN_WARN, SIGN_ENFORCE:
+	default:
+		return fmt.Errorf("unknown signature policy %s - expected off, warn or enforce", policy)
+	}
+	signPolicy = policy
+	trustedKeysDir = keysDir
+	logger.Log.Infof("Profile signature policy: %s - trusted keys in %s", policy, keysDir)
+	return nil
+}
+
+// Load the trusted Ed25519 keys. Each file of the directory holds one key -
+// PEM (PKIX), OpenSSH authorized key or raw base64 - and the file name
+// without extension is the signer identity.
+func LoadTrustedKeys(dir string) (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			logger.Log.Errorf("Unable to read the trusted key %s: %v", e.Name(), err)
+			continue
+		}
+		key, err := parsePublicKey(content)
+		if err != nil {
+			logger.Log.Errorf("Invalid trusted key %s: %v", e.Name(), err)
+			continue
+		}
+		keys[strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))] = key
+	}
+	return keys, nil
+}
+
+func parsePublicKey(content []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(content); block != nil {
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := k.(ed25519.PublicKey); ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("not an Ed25519 key")
+	}
+	if bytes.HasPrefix(content, []byte("ssh-ed25519 ")) {
+		k, _, _, _, err := ssh.ParseAuthorizedKey(content)
+		if err != nil {
+			return nil, err
+		}
+		if ck, ok := k.(ssh.CryptoPublicKey); ok {
+			if key, ok := ck.CryptoPublicKey().(ed25519.PublicKey); ok {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("not an Ed25519 key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("unsupported key format")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Signature check failure that may vanish on the next scan - e.g. the trusted
+// keys are not deployed yet
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// Verify the detached signature (raw or base64) of a package against the
+// trusted keys and return the signer identity
+func VerifyPackage(tgzPath string, keysDir string) (string, error) {
+	sig, err := os.ReadFile(tgzPath + SIG_EXT)
+	if err != nil {
+		return "", fmt.Errorf("missing signature %s", filepath.Base(tgzPath)+SIG_EXT)
+	}
+	if len(sig) != ed25519.SignatureSize {
+		sig, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			return "", fmt.Errorf("malformed signature %s", filepath.Base(tgzPath)+SIG_EXT)
+		}
+	}
+	content, err := os.ReadFile(tgzPath)
+	if err != nil {
+		return "", &transientError{err: err}
+	}
+	keys, err := LoadTrustedKeys(keysDir)
+	if err != nil {
+		return "", &transientError{err: fmt.Errorf("unable to load the trusted keys: %v", err)}
+	}
+	if len(keys) == 0 {
+		return "", &transientError{err: fmt.Errorf("no trusted key in %s", keysDir)}
+	}
+	for signer, key := range keys {
+		if ed25519.Verify(key, content, sig) {
+			return signer, nil
+		}
+	}
+	return "", fmt.Errorf("signature does not match any trusted key")
+}
+
+// Apply the signature policy to a package. Return the signer identity -
+// an error means the package must be rejected.
+func CheckSignature(tgzPath string) (string, error) {
+	if signPolicy == SIGN_OFF {
+		return "", nil
+	}
+	signer, err := VerifyPackage(tgzPath, trustedKeysDir)
+	if err != nil {
+		if signPolicy == SIGN_ENFORCE {
+			return "", err
+		}
+		logger.Log.Warnf("Profile package %s: %v - accepted by the warn policy", filepath.Base(tgzPath), err)
+		return "", nil
+	}
+	logger.Log.Infof("Profile package %s signed by %s", filepath.Base(tgzPath), signer)
+	return signer, nil
+}
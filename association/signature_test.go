@@ -0,0 +1,61 @@
+package association
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"io"
+	"jtso/logger"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func quietLogger() {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+}
+
+func TestAcceptPackageSignatureRejection(t *testing.T) {
+	quietLogger()
+	t.Cleanup(func() { SetSignPolicy(SIGN_OFF, "") })
+
+	dir := t.TempDir()
+	pkg := filepath.Join(dir, "p.tgz")
+	if err := os.WriteFile(pkg, []byte("package"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pkg+SIG_EXT, make([]byte, ed25519.SignatureSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	keys := filepath.Join(dir, "keys")
+	if err := os.Mkdir(keys, 0755); err != nil {
+		t.Fatal(err)
+	}
+	SetSignPolicy(SIGN_ENFORCE, keys)
+
+	// no trusted key deployed yet - checked again on the next scan
+	if _, ok := acceptPackage("p", pkg, pkg, "h1"); ok {
+		t.Fatal("package accepted without trusted key")
+	}
+	if _, ok := rejected[pkg]; ok {
+		t.Errorf("a transient failure must not reject the package")
+	}
+
+	// a key that does not match - rejected until the package changes
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(keys, "ops.pub"), []byte(base64.StdEncoding.EncodeToString(pub)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := acceptPackage("p", pkg, pkg, "h1"); ok {
+		t.Fatal("package accepted with a wrong signature")
+	}
+	if rejected[pkg] != "h1" {
+		t.Errorf("rejected = %q, want h1", rejected[pkg])
+	}
+	delete(rejected, pkg)
+}
@@ -1,20 +1,18 @@
 package association
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"hash/fnv"
-	"io"
 	"jtso/config"
 	"jtso/container"
+	"jtso/influx"
 	"jtso/kapacitor"
 	"jtso/logger"
 	"jtso/maker"
 	"jtso/ondemand"
 	"jtso/sqlite"
 	"os"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -53,56 +51,29 @@ func hashStringFNV(input string) uint32 {
 	return hasher.Sum32()
 }
 
+// Set the InfluxDB connection of an influx output
+func fillInfluxConnection(o *maker.InfluxOutput) {
+	c := sqlite.ActiveInfluxConfig
+	o.V2 = c.Version != influx.V1
+	o.Url = c.Url
+	o.Database = c.Database
+	o.Org = c.Org
+	o.Username = c.Username
+	o.CA = c.CA
+	o.SkipVerify = c.SkipVerify == "yes"
+}
+
+// Apply the collector parameters to the agent of every family - the
+// overrides of a family take precedence
 func ChangeTelegrafTuning(batchSize string, bufferLimit string, flushInterval string, flushJitter string) error {
-	instances := []string{"mx", "ptx", "acx", "ex", "qfx", "srx", "crpd", "cptx", "vmx", "vsrx", "vjunos", "vevo", "ondemand"}
 	logger.Log.Infof("Changing telegraf tuning with batch size %s, buffer limit %s, flush interval %s and flush jitter %s", batchSize, bufferLimit, flushInterval, flushJitter)
 
-	batchRegex := regexp.MustCompile(`^\s*metric_batch_size\s*=\s*\d*\s*$`)
-	bufferRegex := regexp.MustCompile(`^\s*metric_buffer_limit\s*=\s*\d*\s*$`)
-	flushIntervalRegex := regexp.MustCompile(`^\s*flush_interval\s*=\s*"[^"]*"\s*$`)
-	flushJitterRegex := regexp.MustCompile(`^\s*flush_jitter\s*=\s*"[^"]*"\s*$`)
-
-	for _, instance := range instances {
-		filePath := TELEGRAF_ROOT_PATH + instance + "/telegraf.conf"
-
-		// Read the file
-		file, err := os.Open(filePath)
-		if err != nil {
-			logger.Log.Errorf("Unable to open the file %s: %v", filePath, err)
-			return err
-		}
-
-		var updatedLines []string
-		scanner := bufio.NewScanner(file)
-
-		for scanner.Scan() {
-			line := scanner.Text()
-			if batchRegex.MatchString(line) {
-				line = fmt.Sprintf("  metric_batch_size = %s", batchSize)
-			} else if bufferRegex.MatchString(line) {
-				line = fmt.Sprintf("  metric_buffer_limit = %s", bufferLimit)
-			} else if flushIntervalRegex.MatchString(line) {
-				line = fmt.Sprintf("  flush_interval = \"%s\"", flushInterval)
-			} else if flushJitterRegex.MatchString(line) {
-				line = fmt.Sprintf("  flush_jitter = \"%s\"", flushJitter)
-			}
-			updatedLines = append(updatedLines, line)
-		}
-
-		if err := scanner.Err(); err != nil {
-			file.Close()
-			logger.Log.Errorf("Error while reading the file %s: %v", filePath, err)
-			return err
-		}
-		file.Close()
-
-		// Write the updated content back to the file
-		err = os.WriteFile(filePath, []byte(strings.Join(updatedLines, "\n")), 0644)
-		if err != nil {
-			logger.Log.Errorf("Error while saving the file %s: %v", filePath, err)
+	p := sqlite.CollectorParameters{MetricBatchSize: batchSize, MetricBufferLimit: bufferLimit, FlushInterval: flushInterval, FlushJitter: flushJitter}
+	for _, instance := range sqlite.AgentFamilies {
+		debug, _ := sqlite.DebugMode(instance)
+		if err := writeTelegrafAgent(instance, p, sqlite.ActiveAgentTuning[instance], debug); err != nil {
 			return err
 		}
-
 	}
 
 	logger.Log.Infof("Telegraf tuning updated for all instances")
@@ -110,82 +81,15 @@ func ChangeTelegrafTuning(batchSize string, bufferLimit string, flushInterval st
 }
 
 func changeTelegrafDebug(instance string, debug int) error {
-	// for enable debug we need to change telegraf.conf and set debug = false
-	filePath := TELEGRAF_ROOT_PATH + instance + "/telegraf.conf"
-
-	// Read the file
-	file, err := os.Open(filePath)
-	if err != nil {
-		logger.Log.Errorf("Unable to open the file %s: %v", filePath, err)
-		return err
-	}
-	defer file.Close()
-
-	debugRegex := regexp.MustCompile(`^\s*debug\s*=\s*(true|false)\s*$`)
-	var updatedLines []string
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if debugRegex.MatchString(line) {
-			if debug == 1 {
-				line = "  debug = true"
-			} else {
-				line = "  debug = false"
-			}
-		}
-		updatedLines = append(updatedLines, line)
-	}
-
-	if err := scanner.Err(); err != nil {
-		logger.Log.Errorf("Error while reading the file %s: %v", filePath, err)
-		return err
-	}
-
-	// Write the updated content back to the file
-	err = os.WriteFile(filePath, []byte(strings.Join(updatedLines, "\n")), 0644)
-	if err != nil {
-		logger.Log.Errorf("Error while saving the file %s: %v", filePath, err)
-		return err
-	}
-
-	return nil
+	return writeTelegrafAgent(instance, sqlite.ActiveCollectorParameters, sqlite.ActiveAgentTuning[instance], debug)
 }
 
 func ManageDebug(instance string) error {
 
 	// First retrieve the current debug state of the Instance
-	var currentState int
 	instance = strings.ToLower(instance)
-
-	switch instance {
-	case "mx":
-		currentState = sqlite.ActiveAdmin.MXDebug
-	case "ptx":
-		currentState = sqlite.ActiveAdmin.PTXDebug
-	case "acx":
-		currentState = sqlite.ActiveAdmin.ACXDebug
-	case "ex":
-		currentState = sqlite.ActiveAdmin.EXDebug
-	case "qfx":
-		currentState = sqlite.ActiveAdmin.QFXDebug
-	case "srx":
-		currentState = sqlite.ActiveAdmin.SRXDebug
-	case "crpd":
-		currentState = sqlite.ActiveAdmin.CRPDDebug
-	case "cptx":
-		currentState = sqlite.ActiveAdmin.CPTXDebug
-	case "vmx":
-		currentState = sqlite.ActiveAdmin.VMXDebug
-	case "vsrx":
-		currentState = sqlite.ActiveAdmin.VSRXDebug
-	case "vjunos":
-		currentState = sqlite.ActiveAdmin.VJUNOSDebug
-	case "vevo":
-		currentState = sqlite.ActiveAdmin.VEVODebug
-	case "ondemand":
-		currentState = sqlite.ActiveAdmin.ONDEMANDDebug
-	default:
+	currentState, err := sqlite.DebugMode(instance)
+	if err != nil {
 		logger.Log.Errorf("Unsupported instance %s", instance)
 		return errors.New("ManageDebug error: unsupported instance")
 	}
@@ -196,21 +100,9 @@ func ManageDebug(instance string) error {
 		return err
 	}
 
-	// Check if there is at least one router attached to the given instance
-	atLeastOne := false
-	// Retrive number of active routers per Telegraf instance
-	for _, r := range sqlite.RtrList {
-		if r.Family == instance {
-			if r.Profile == 1 {
-				atLeastOne = true
-				break
-			}
-		}
-	}
-
-	if atLeastOne {
+	if familyActive(instance) {
 		// Now restart container only if there are active routers.
-		if err := container.RestartContainer("telegraf_" + instance); err != nil {
+		if err := restartTelegraf(instance); err != nil {
 			logger.Log.Errorf("Unable to restart containter telegraf_%s: %v", instance, err)
 			// revert back to previous state
 			changeTelegrafDebug(instance, currentState)
@@ -414,8 +306,6 @@ func ConfigureOndemand(cfg *config.ConfigContainer, profile ondemand.RunningProf
 	}
 
 	gnmi.Rtrs = rendRtrs
-	gnmi.Username = sqlite.ActiveCred.GnmiUser
-	gnmi.Password = sqlite.ActiveCred.GnmiPwd
 	gnmi.UseTls = tls
 	gnmi.UseTlsClient = clienttls
 	gnmi.SkipVerify = skip
@@ -624,30 +514,31 @@ func ConfigureOndemand(cfg *config.ConfigContainer, profile ondemand.RunningProf
 	if rate.Order != 0 {
 		telegrafOnDemand.RateList = append(telegrafOnDemand.RateList, *rate)
 	}
+	fillInfluxConnection(influx)
 	telegrafOnDemand.InfluxList = append(telegrafOnDemand.InfluxList, *influx)
 
 	// Add Kafka output if needed
 	if sqlite.ActiveKafkaConfig.Enabled == 1 {
-		// split list of brokers thanks to comma separator and trim spaces
-		brokers := strings.Split(sqlite.ActiveKafkaConfig.Brokers, ",")
-		s := make([]string, 0)
-		for _, b := range brokers {
-			s = append(s, strings.TrimSpace(b))
-		}
-		telegrafOnDemand.KafkaList = append(telegrafOnDemand.KafkaList, maker.KafkaOutput{
-			Brokers:          brokers,
-			Topic:            sqlite.ActiveKafkaConfig.Topic,
-			Format:           sqlite.ActiveKafkaConfig.Format,
-			Version:          sqlite.ActiveKafkaConfig.Version,
-			MessageSize:      sqlite.ActiveKafkaConfig.MessageSize,
-			CompressionCodec: sqlite.ActiveKafkaConfig.Compression,
-			// inherit some fields from influx output if not specified in kafka config
-			Fieldpass: telegrafOnDemand.InfluxList[0].Fieldpass,
-		})
-
+		telegrafOnDemand.KafkaList = append(telegrafOnDemand.KafkaList, kafkaOutputs(influx.Fieldpass, nil)...)
 		logger.Log.Info("Kafka output added to the telegraf Ondemand config")
 	}
 
+	// Add Prometheus output if needed - the ondemand instance has a single config
+	if sqlite.ActivePrometheusConfig.Enabled == 1 {
+		prom := maker.PrometheusOutput{
+			// inherit some fields from influx output
+			Fieldpass: influx.Fieldpass,
+		}
+		if prometheusRemoteWrite() {
+			prom.Url = sqlite.ActivePrometheusConfig.Endpoint
+		} else {
+			prom.Port = PrometheusPort("ondemand")
+		}
+		telegrafOnDemand.PrometheusList = append(telegrafOnDemand.PrometheusList, prom)
+		logger.Log.Info("Prometheus output added to the telegraf Ondemand config")
+	}
+
+	applyRoutes(&telegrafOnDemand)
 	// render telegraf file
 	payload, err := maker.RenderConf(&telegrafOnDemand)
 	if err != nil {
@@ -693,7 +584,9 @@ func ConfigureOndemand(cfg *config.ConfigContainer, profile ondemand.RunningProf
 	container.RestartContainer("grafana")
 
 	// Restart telegraf ondemand instance
-	container.RestartContainer("telegraf_ondemand")
+	if err := restartTelegraf("ondemand"); err != nil {
+		logger.Log.Errorf("Unable to restart container telegraf_ondemand: %v", err)
+	}
 
 	logger.Log.Info("All JTS components reconfigured for ondemand profile")
 	return nil
@@ -709,6 +602,7 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 	profileSetToRouters := make(map[string][]*sqlite.RtrEntry)
 	profileSetToProfilesFilename := make(map[string][]string)
 	profileSetToProfilesName := make(map[string][]string)
+	profileSetToProfilesParams := make(map[string][]map[string]string)
 	profileSetIndex := make(map[string]uint32)
 
 	// Map to store collections (family → collection → Collection struct)
@@ -744,11 +638,8 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 	// -----------------------------------------------------------------------------------------------------
 	routerProfiles := make(map[string][]string) // key: Shortname → value: Profile List
 	for _, asso := range sqlite.AssoList {
-		// Create a new slice with the same length as asso.Assos
-		assosCopy := make([]string, len(asso.Assos))
-		// Copy the contents of asso.Assos into the new slice
-		copy(assosCopy, asso.Assos)
-		routerProfiles[asso.Shortname] = assosCopy
+		// Copy asso.Assos with the profiles they extend or include
+		routerProfiles[asso.Shortname] = expandProfiles(asso.Assos)
 	}
 
 	// -----------------------------------------------------------------------------------------------------
@@ -770,6 +661,7 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 		// check if version is assigned to a profile and save file name
 		profilesFilename := make([]string, len(profileKeys))
 		profilesName := make([]string, len(profileKeys))
+		profilesParams := make([]map[string]string, len(profileKeys))
 		for i, p := range profileKeys {
 
 			// bypass unknown profile
@@ -779,55 +671,19 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 				continue
 			}
 			profilesName[i] = p
-
-			var filenameList []Config
-			switch rtr.Family {
-			case "mx":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.MxCfg
-			case "ptx":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.PtxCfg
-			case "acx":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.AcxCfg
-			case "ex":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.ExCfg
-			case "qfx":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.QfxCfg
-			case "srx":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.SrxCfg
-			case "crpd":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.CrpdCfg
-			case "cptx":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.CptxCfg
-			case "vmx":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.VmxCfg
-			case "vsrx":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.VsrxCfg
-			case "vjunos":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.VjunosCfg
-			case "vevo":
-				filenameList = ActiveProfiles[p].Definition.TelCfg.VevoCfg
-			}
+			profilesParams[i] = ResolveParams(p, rtr)
 
 			// Check if a profile has as specific version for the given rtr
-			savedVersion := ""
-			profilesFilename[i] = ""
-
-			for _, c := range filenameList {
-				// Save all config if present as a fallback solution if specific version not found
-				if c.Version == "all" && savedVersion == "" {
-					profilesFilename[i] = c.Config
-					savedVersion = "all"
-				} else {
-					result := CheckVersion(c.Version, rtr.Version)
-					if result && (savedVersion == "" || savedVersion == "all") {
-						profilesFilename[i] = c.Config
-						savedVersion = c.Version
-					}
-				}
-			}
+			filenameList, _ := ActiveProfiles[p].Definition.TelCfg.Family(rtr.Family)
+			var savedVersion string
+			profilesFilename[i], savedVersion = SelectConfig(filenameList, rtr.Version)
 
 			if savedVersion != "" {
 				profileKeys[i] = p + "_" + savedVersion
+				// routers with different parameter values need their own collection
+				if len(ActiveProfiles[p].Definition.Parameters) > 0 {
+					profileKeys[i] += "{" + paramsSignature(profilesParams[i]) + "}"
+				}
 			} else {
 				// Reset entry if there is no filename found
 				profileKeys[i] = ""
@@ -845,6 +701,7 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 			profileSetIndex[profileKey] = hashStringFNV(profileKey)
 			profileSetToProfilesFilename[profileKey] = profilesFilename
 			profileSetToProfilesName[profileKey] = profilesName
+			profileSetToProfilesParams[profileKey] = profilesParams
 		}
 
 		// Store the router in the corresponding profile set
@@ -870,9 +727,10 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 
 		// Assign to the collections map
 		Collections[family][collectionID] = sqlite.Collection{
-			ProfilesName: profilesName,
-			ProfilesConf: profilesFilename,
-			Routers:      routers,
+			ProfilesName:   profilesName,
+			ProfilesConf:   profilesFilename,
+			ProfilesParams: profileSetToProfilesParams[profileKey],
+			Routers:        routers,
 		}
 
 	}
@@ -927,13 +785,30 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 			}
 		}
 
+		// fieldpass of the scrape endpoint of the family
+		promFieldpass := make([]string, 0)
+		promSeen := make(map[string]bool)
+
 		// For each collection
 		for id, collection := range Collections[f] {
 			// create a new collection of config before optimisation
 			telegrafCfgList = make([]*maker.TelegrafConfig, 0)
+			// profile of each config
+			cfgProfiles := make([]string, 0)
 			for index, file := range collection.ProfilesConf {
 				fullPath := ACTIVE_PROFILES + collection.ProfilesName[index] + "/" + file
-				newCfg, err := maker.LoadConfig(fullPath)
+				content, err := os.ReadFile(fullPath)
+				if err != nil {
+					logger.Log.Errorf("Error opening file %s: %v", fullPath, err)
+					continue
+				}
+				// render the parameters of the profile with the values of the collection
+				content, err = ApplyParams(content, renderValues(collection.ProfilesParams[index], collection.Routers))
+				if err != nil {
+					logger.Log.Errorf("Unable to render the parameters of %s: %v", fullPath, err)
+					continue
+				}
+				newCfg, err := maker.ParseConfig(content, fullPath)
 				if err != nil {
 					continue
 				}
@@ -954,6 +829,7 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 				}
 
 				telegrafCfgList = append(telegrafCfgList, newCfg)
+				cfgProfiles = append(cfgProfiles, collection.ProfilesName[index])
 			}
 
 			// Create one unique config based on the list of configs
@@ -985,9 +861,11 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 			if len(mergedCfg.GnmiList) > 0 {
 				for i := range mergedCfg.GnmiList {
 					mergedCfg.GnmiList[i].Rtrs = rendRtrs
-					mergedCfg.GnmiList[i].Username = sqlite.ActiveCred.GnmiUser
-					mergedCfg.GnmiList[i].Password = sqlite.ActiveCred.GnmiPwd
 					mergedCfg.GnmiList[i].UseTls = tls
+					// an input may force TLS on or off
+					if mergedCfg.GnmiList[i].Tls != nil {
+						mergedCfg.GnmiList[i].UseTls = *mergedCfg.GnmiList[i].Tls
+					}
 					mergedCfg.GnmiList[i].UseTlsClient = clienttls
 					mergedCfg.GnmiList[i].SkipVerify = skip
 				}
@@ -995,32 +873,39 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 			if len(mergedCfg.NetconfList) > 0 {
 				for i := range mergedCfg.NetconfList {
 					mergedCfg.NetconfList[i].Rtrs = rendRtrsNet
-					mergedCfg.NetconfList[i].Username = sqlite.ActiveCred.NetconfUser
-					mergedCfg.NetconfList[i].Password = sqlite.ActiveCred.NetconfPwd
 				}
 			}
-			// Add Kafka output if needed
+			for i := range mergedCfg.InfluxList {
+				fillInfluxConnection(&mergedCfg.InfluxList[i])
+			}
+			// Add Kafka output if needed - for the profiles exported to Kafka, the
+			// measurements of the other profiles are dropped
 			if sqlite.ActiveKafkaConfig.Enabled == 1 {
-				// split list of brokers thanks to comma separator and trim spaces
-				brokers := strings.Split(sqlite.ActiveKafkaConfig.Brokers, ",")
-				s := make([]string, 0)
-				for _, b := range brokers {
-					s = append(s, strings.TrimSpace(b))
+				fieldpass, found := outputsFieldpass(telegrafCfgList, cfgProfiles, sqlite.ActiveKafkaOptions.Exported)
+				if found {
+					namedrop := excludedMeasurements(telegrafCfgList, cfgProfiles, sqlite.ActiveKafkaOptions.Exported)
+					mergedCfg.KafkaList = append(mergedCfg.KafkaList, kafkaOutputs(fieldpass, namedrop)...)
+					logger.Log.Infof("Kafka output added to the telegraf config of the collection %s", id)
 				}
-
-				mergedCfg.KafkaList = append(mergedCfg.KafkaList, maker.KafkaOutput{
-					Brokers:          brokers,
-					Topic:            sqlite.ActiveKafkaConfig.Topic,
-					Format:           sqlite.ActiveKafkaConfig.Format,
-					Version:          sqlite.ActiveKafkaConfig.Version,
-					MessageSize:      sqlite.ActiveKafkaConfig.MessageSize,
-					CompressionCodec: sqlite.ActiveKafkaConfig.Compression,
-					// inherit some fields from influx output if not specified in kafka config
-					Fieldpass: mergedCfg.InfluxList[0].Fieldpass,
+			}
+			allFieldpass, _ := outputsFieldpass(telegrafCfgList, cfgProfiles, func(string) bool { return true })
+			// Add Prometheus output if needed
+			if prometheusRemoteWrite() {
+				mergedCfg.PrometheusList = append(mergedCfg.PrometheusList, maker.PrometheusOutput{
+					Url: sqlite.ActivePrometheusConfig.Endpoint,
+					// inherit some fields from influx output
+					Fieldpass: allFieldpass,
 				})
-
-				logger.Log.Infof("Kafka output added to the telegraf config of the collection %s", id)
+				logger.Log.Infof("Prometheus output added to the telegraf config of the collection %s", id)
+			} else if prometheusClient() {
+				for _, field := range allFieldpass {
+					if !promSeen[field] {
+						promSeen[field] = true
+						promFieldpass = append(promFieldpass, field)
+					}
+				}
 			}
+			applyRoutes(mergedCfg)
 			// render file
 			payload, err := maker.RenderConf(mergedCfg)
 			if err != nil {
@@ -1044,6 +929,10 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 
 		}
 
+		// one scrape endpoint per Telegraf instance
+		if prometheusClient() && len(Collections[f]) > 0 {
+			writePrometheusClient(f, path, promFieldpass)
+		}
 	}
 
 	// -----------------------------------------------------------------------------------------------------
@@ -1053,6 +942,9 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 	excludeDash = make([]string, 0)
 	excludeDash = append(excludeDash, "home.json")
 	excludeDash = append(excludeDash, "ondemand.json")
+	ticksMu.Lock()
+	variants := paramVariants()
+	dashValues := make(map[string]map[string]string)
 	for _, v := range Collections {
 		for _, c := range v {
 			for _, p := range c.ProfilesName {
@@ -1062,22 +954,25 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 					logger.Log.Errorf("Grafana update - Unknown profile detected: %s - skip it", p)
 					continue
 				}
+				// dashboards are shared by all the routers of the profile
+				values, done := dashValues[p]
+				if !done {
+					values = dashboardValues(p, ActiveProfiles[p].Definition, variants[p])
+					dashValues[p] = values
+				}
 				for _, d := range ActiveProfiles[p].Definition.GrafaCfg {
 					excludeDash = append(excludeDash, d)
-					source, err := os.Open(ACTIVE_PROFILES + p + "/" + d) //open the source file
+					source, err := os.ReadFile(ACTIVE_PROFILES + p + "/" + d) //open the source file
 					if err != nil {
 						logger.Log.Errorf("Unable to open the source dashboard %s - err: %v", d, err)
 						continue
 					}
-					destination, err := os.Create(PATH_GRAFANA + d) //create the destination file
+					rendered, err := ApplyParams(source, values)
 					if err != nil {
-						logger.Log.Errorf("Unable to open the destination dashboard %s - err: %v", d, err)
-						source.Close()
+						logger.Log.Errorf("Unable to render the dashboard %s - err: %v", d, err)
 						continue
 					}
-					_, err = io.Copy(destination, source) //copy the contents of source to destination file
-					source.Close()
-					destination.Close()
+					err = os.WriteFile(PATH_GRAFANA+d, rendered, 0644) //copy the contents of source to destination file
 					if err != nil {
 						logger.Log.Errorf("Unable to update the dashboard %s - err: %v", d, err)
 						continue
@@ -1121,6 +1016,7 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 	kapaStart = make([]string, 0)
 	kapaStop = make([]string, 0)
 	kapaAll = make([]string, 0)
+	profileKapa := make(map[string][]string)
 	for _, v := range Collections {
 		for _, c := range v {
 			for _, p := range c.ProfilesName {
@@ -1130,8 +1026,11 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 					logger.Log.Errorf("Kapacitor update - Unknown profile detected: %s - skip it", p)
 					continue
 				}
-				for _, d := range ActiveProfiles[p].Definition.KapaCfg {
-					fileKapa := ACTIVE_PROFILES + p + "/" + d
+				// scripts of a profile with parameters are rendered once per set of values
+				if _, done := profileKapa[p]; !done {
+					profileKapa[p] = profileTicks(p, variants[p])
+				}
+				for _, fileKapa := range profileKapa[p] {
 					to_add := true
 					for _, a := range kapaAll {
 						if a == fileKapa {
@@ -1182,6 +1081,10 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 		kapacitor.StartTick(kapaStart)
 	}
 
+	// drop the scripts rendered with values no more in use
+	cleanTicks(kapaAll)
+	ticksMu.Unlock()
+
 	// Restart grafana
 	container.RestartContainer("grafana")
 
@@ -1197,8 +1100,8 @@ func ConfigueStack(cfg *config.ConfigContainer, family string) error {
 		// if cntr == 0 prefer shutdown the telegraf container
 		if cntr == 0 {
 			container.StopContainer("telegraf_" + f)
-		} else {
-			container.RestartContainer("telegraf_" + f)
+		} else if err := restartTelegraf(f); err != nil {
+			logger.Log.Errorf("Unable to restart container telegraf_%s: %v", f, err)
 		}
 	}
 
@@ -0,0 +1,148 @@
+package association
+
+import (
+	"fmt"
+	"jtso/config"
+	"jtso/logger"
+	"jtso/sqlite"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Archive of the deployed versions: PROFILES_HISTORY/<profile>/<sha256>/<profile>.tgz
+const PROFILES_HISTORY string = "/var/profiles_history/"
+
+var reHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Number of versions kept per profile
+var historyKeep int = 5
+
+func SetHistoryKeep(keep int) {
+	historyKeep = keep
+}
+
+func archivePath(filename string, hash string) string {
+	return PROFILES_HISTORY + filename + "/" + hash + "/" + filename + ".tgz"
+}
+
+// Return the package to deploy: the pinned version if it is still archived,
+// the latest package otherwise
+func packageSource(filename string, pin string) string {
+	latest := PROFILES + filename + ".tgz"
+	if pin == "" {
+		return latest
+	}
+	src := archivePath(filename, pin)
+	if _, err := os.Stat(src); err != nil {
+		logger.Log.Errorf("Pinned version %s of profile %s is no more archived - use the latest package", pin, filename)
+		return latest
+	}
+	return src
+}
+
+// Copy a file if the destination does not exist
+func copyIfMissing(src string, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, 0644)
+}
+
+// Keep a copy of an accepted package and record the version. The oldest
+// versions beyond the history size are removed.
+func archivePackage(filename string, src string, hash string, report *LintReport) {
+	dst := archivePath(filename, hash)
+	if src != dst {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			logger.Log.Errorf("Unable to create the archive directory of profile %s: %v", filename, err)
+			return
+		}
+		if err := copyIfMissing(src, dst); err != nil {
+			logger.Log.Errorf("Unable to archive profile %s: %v", filename, err)
+			return
+		}
+		if _, err := os.Stat(src + SIG_EXT); err == nil {
+			if err := copyIfMissing(src+SIG_EXT, dst+SIG_EXT); err != nil {
+				logger.Log.Errorf("Unable to archive the signature of profile %s: %v", filename, err)
+			}
+		}
+	}
+
+	v := &sqlite.ProfileVersion{Profile: filename, Hash: hash, Version: report.Version, Signer: report.Signer, Timestamp: time.Now().Unix()}
+	purged, err := sqlite.AddProfileVersion(v, historyKeep)
+	if err != nil {
+		return
+	}
+	for _, h := range purged {
+		if err := os.RemoveAll(PROFILES_HISTORY + filename + "/" + h); err != nil {
+			logger.Log.Errorf("Unable to remove the version %s of profile %s: %v", h, filename, err)
+		}
+	}
+}
+
+// Archive the latest package of a pinned profile so that it can be selected
+// later - once archived or rejected, the package is checked again only when
+// its stamp changes
+func archiveLatest(filename string, st stamp) {
+	if latestStamps[filename] == st {
+		return
+	}
+	latest := PROFILES + filename + ".tgz"
+	snap, err := snapshotPackage(filename, latest)
+	if err != nil {
+		logger.Log.Errorf("Unable to copy the package of profile %s: %v", filename, err)
+		return
+	}
+	defer snap.remove()
+	if _, err := os.Stat(archivePath(filename, snap.hash)); err == nil {
+		latestStamps[filename] = st
+		return
+	}
+	report, ok := acceptPackage(filename, latest, snap.path, snap.hash+snap.sigHash)
+	if !ok {
+		// a definite rejection holds until the package changes
+		if rejected[latest] == snap.hash+snap.sigHash {
+			latestStamps[filename] = st
+		}
+		return
+	}
+	archivePackage(filename, snap.path, snap.hash, report)
+	if _, err := os.Stat(archivePath(filename, snap.hash)); err != nil {
+		return
+	}
+	latestStamps[filename] = st
+	logger.Log.Infof("New version of the pinned profile %s archived", filename)
+}
+
+// Pin a profile to an archived version - an empty hash follows the latest
+// package again. The profile scan then redeploys the profile and restarts
+// the families using it.
+func PinProfile(cfg *config.ConfigContainer, filename string, hash string) error {
+	if filename == "" || filepath.Base(filename) != filename {
+		return fmt.Errorf("invalid profile name %q", filename)
+	}
+	if hash != "" && !reHash.MatchString(hash) {
+		return fmt.Errorf("invalid version %q", hash)
+	}
+	if hash != "" {
+		if _, err := os.Stat(archivePath(filename, hash)); err != nil {
+			return fmt.Errorf("version %s of profile %s is not archived", hash, filename)
+		}
+	}
+	if err := sqlite.SetProfilePin(filename, hash); err != nil {
+		return err
+	}
+	if hash == "" {
+		logger.Log.Infof("Profile %s follows the latest package", filename)
+	} else {
+		logger.Log.Infof("Profile %s pinned to version %s", filename, hash)
+	}
+	go PeriodicCheck(cfg)
+	return nil
+}
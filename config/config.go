@@ -37,16 +37,49 @@ type ChronografConfig struct {
 type NetconfConfig struct {
 	Port       int
 	RpcTimeout int
+	// live, record or replay
+	Mode       string
+	CaptureDir string
 }
 
 type GnmiConfig struct {
 	Port int
 }
 
+// Interface selection rules of the enricher for a given family.
+// All entries are regular expressions matched against the interface name.
+// Port rules must have one capture group returning the FPC/PIC/PORT[:CHANNEL] part.
+type InterfaceRules struct {
+	Include []string `mapstructure:"include"`
+	Exclude []string `mapstructure:"exclude"`
+	Port    []string `mapstructure:"port"`
+}
+
 type EnricherConfig struct {
-	Folder   string
-	Interval int
-	Workers  int
+	Folder     string
+	Interval   int
+	Workers    int
+	Interfaces map[string]*InterfaceRules
+	// Metadata history retention in days and InfluxDB annotations of the changes
+	HistoryDays int
+	Annotations bool
+	// Number of run reports kept per router - 0 disables the reports
+	Reports int
+	// Deadline in seconds of one router collection and number of retries on dial failure
+	TaskTimeout int
+	Retries     int
+}
+
+type ProfilesConfig struct {
+	// Signature policy of the packages: off, warn or enforce
+	Signature   string
+	TrustedKeys string
+	// Number of versions kept per profile for rollback
+	History int
+	// Safety net rescan of the watched directories (minutes) and quiet
+	// period before a change is processed (seconds)
+	Rescan   int
+	Debounce int
 }
 
 type ConfigContainer struct {
@@ -57,6 +90,7 @@ type ConfigContainer struct {
 	Portal     *PortalConfig
 	Netconf    *NetconfConfig
 	Gnmi       *GnmiConfig
+	Profiles   *ProfilesConfig
 }
 
 func NewConfigContainer(f string) *ConfigContainer {
@@ -93,10 +127,31 @@ func NewConfigContainer(f string) *ConfigContainer {
 	viper.SetDefault("modules.enricher.folder", "/var/metadata/")
 	viper.SetDefault("modules.enricher.interval", 240)
 	viper.SetDefault("modules.enricher.workers", 4)
+	viper.SetDefault("modules.enricher.history_days", 90)
+	viper.SetDefault("modules.enricher.annotations", false)
+	viper.SetDefault("modules.enricher.reports", 10)
+	viper.SetDefault("modules.enricher.task_timeout", 600)
+	viper.SetDefault("modules.enricher.retries", 2)
+
+	// Set default value for profile packages
+	viper.SetDefault("modules.profiles.signature", "off")
+	viper.SetDefault("modules.profiles.trusted_keys", "/var/trusted_keys/")
+	viper.SetDefault("modules.profiles.history", 5)
+	viper.SetDefault("modules.profiles.rescan", 10)
+	viper.SetDefault("modules.profiles.debounce", 2)
+
+	// Per family interface rules of the enricher - "default" applies to families without specific rules
+	interfaces := make(map[string]*InterfaceRules)
+	if err := viper.UnmarshalKey("modules.enricher.interfaces", &interfaces); err != nil {
+		logger.Log.Errorf("Unable to parse the enricher interface rules, use default ones: %v", err)
+		interfaces = make(map[string]*InterfaceRules)
+	}
 
 	// Set default value for Netconf
 	viper.SetDefault("protocols.netconf.port", 830)
 	viper.SetDefault("protocols.netconf.rpc_timeout", 60)
+	viper.SetDefault("protocols.netconf.mode", "live")
+	viper.SetDefault("protocols.netconf.capture_dir", "/var/capture/")
 
 	// Set default value for gnmi
 	viper.SetDefault("protocols.gnmi.port", 9339)
@@ -121,16 +176,31 @@ func NewConfigContainer(f string) *ConfigContainer {
 			HideOrigin:     viper.GetBool("modules.portal.hide_origin"),
 		},
 		Enricher: &EnricherConfig{
-			Folder:   viper.GetString("modules.enricher.folder"),
-			Interval: viper.GetInt("modules.enricher.interval"),
-			Workers:  viper.GetInt("modules.enricher.workers"),
+			Folder:      viper.GetString("modules.enricher.folder"),
+			Interval:    viper.GetInt("modules.enricher.interval"),
+			Workers:     viper.GetInt("modules.enricher.workers"),
+			Interfaces:  interfaces,
+			HistoryDays: viper.GetInt("modules.enricher.history_days"),
+			Annotations: viper.GetBool("modules.enricher.annotations"),
+			Reports:     viper.GetInt("modules.enricher.reports"),
+			TaskTimeout: viper.GetInt("modules.enricher.task_timeout"),
+			Retries:     viper.GetInt("modules.enricher.retries"),
 		},
 		Netconf: &NetconfConfig{
 			Port:       viper.GetInt("protocols.netconf.port"),
 			RpcTimeout: viper.GetInt("protocols.netconf.rpc_timeout"),
+			Mode:       viper.GetString("protocols.netconf.mode"),
+			CaptureDir: viper.GetString("protocols.netconf.capture_dir"),
 		},
 		Gnmi: &GnmiConfig{
 			Port: viper.GetInt("protocols.gnmi.port"),
 		},
+		Profiles: &ProfilesConfig{
+			Signature:   viper.GetString("modules.profiles.signature"),
+			TrustedKeys: viper.GetString("modules.profiles.trusted_keys"),
+			History:     viper.GetInt("modules.profiles.history"),
+			Rescan:      viper.GetInt("modules.profiles.rescan"),
+			Debounce:    viper.GetInt("modules.profiles.debounce"),
+		},
 	}
 }
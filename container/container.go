@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"jtso/logger"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 )
 
@@ -22,6 +24,25 @@ type ContainerStats struct {
 
 var Cstats *ContainerStats
 
+// Container name -> lock of its restarts and stops
+var (
+	lifecycleMu sync.Mutex
+	lifecycle   = make(map[string]*sync.Mutex)
+)
+
+// Serialise the restarts and stops of a container - returns the unlock
+func lockContainer(name string) func() {
+	lifecycleMu.Lock()
+	mu, ok := lifecycle[name]
+	if !ok {
+		mu = new(sync.Mutex)
+		lifecycle[name] = mu
+	}
+	lifecycleMu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
 func Init(i int) {
 	Cstats = new(ContainerStats)
 	Cstats.Interval = i
@@ -226,6 +247,7 @@ func ListContainers() []container.Summary {
 }
 
 func RestartContainer(name string) error {
+	defer lockContainer(name)()
 	timeout := 30
 
 	// Open Docker API
@@ -247,7 +269,122 @@ func RestartContainer(name string) error {
 
 }
 
+// Restart a container with the given environment variables. The environment
+// of a container is fixed at creation: the container is recreated with the
+// same configuration when a variable changes. The new container is created
+// before the old one is stopped, and the old one is restarted if the new one
+// does not start. The restarts of a container are serialised.
+func RestartWithEnv(name string, env map[string]string) error {
+	defer lockContainer(name)()
+	timeout := 30
+	ctx := context.Background()
+
+	// Open Docker API
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		logger.Log.Errorf("Unable to open Docker session: %v", err)
+		return err
+	}
+	defer cli.Close()
+
+	inspect, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		logger.Log.Errorf("Unable to inspect %s container: %v", name, err)
+		return err
+	}
+
+	// replace the managed variables
+	current := make(map[string]bool)
+	newEnv := make([]string, 0, len(inspect.Config.Env)+len(env))
+	for _, e := range inspect.Config.Env {
+		current[e] = true
+		k, _, _ := strings.Cut(e, "=")
+		if _, ok := env[k]; !ok {
+			newEnv = append(newEnv, e)
+		}
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	changed := false
+	for _, k := range keys {
+		e := k + "=" + env[k]
+		changed = changed || !current[e]
+		newEnv = append(newEnv, e)
+	}
+
+	if !changed {
+		err = cli.ContainerRestart(ctx, name, container.StopOptions{Signal: "SIGTERM", Timeout: &timeout})
+		if err != nil {
+			logger.Log.Errorf("Unable to restart %s container: %v", name, err)
+			return err
+		}
+		logger.Log.Infof("%s container has been restarted", name)
+		return nil
+	}
+
+	// keep the network attachments
+	endpoints := make(map[string]*network.EndpointSettings)
+	if inspect.NetworkSettings != nil {
+		for n, ep := range inspect.NetworkSettings.Networks {
+			endpoints[n] = &network.EndpointSettings{IPAMConfig: ep.IPAMConfig, Links: ep.Links, Aliases: ep.Aliases, DriverOpts: ep.DriverOpts}
+		}
+	}
+
+	// remove the leftovers of an interrupted recreation
+	newName, oldName := name+"_new", name+"_old"
+	cli.ContainerRemove(ctx, newName, container.RemoveOptions{Force: true})
+	cli.ContainerRemove(ctx, oldName, container.RemoveOptions{Force: true})
+
+	cfg := *inspect.Config
+	cfg.Env = newEnv
+	created, err := cli.ContainerCreate(ctx, &cfg, inspect.HostConfig, &network.NetworkingConfig{EndpointsConfig: endpoints}, nil, newName)
+	if err != nil {
+		logger.Log.Errorf("Unable to recreate %s container: %v", name, err)
+		return err
+	}
+
+	if err := cli.ContainerStop(ctx, inspect.ID, container.StopOptions{Signal: "SIGTERM", Timeout: &timeout}); err != nil {
+		logger.Log.Errorf("Unable to stop %s container: %v", name, err)
+		cli.ContainerRemove(ctx, created.ID, container.RemoveOptions{Force: true})
+		return err
+	}
+
+	// swap the containers, then start the new one - on failure the old one
+	// gets back its name and is restarted
+	renamed := false
+	err = cli.ContainerRename(ctx, inspect.ID, oldName)
+	if err == nil {
+		renamed = true
+		if err = cli.ContainerRename(ctx, created.ID, name); err == nil {
+			err = cli.ContainerStart(ctx, created.ID, container.StartOptions{})
+		}
+	}
+	if err != nil {
+		logger.Log.Errorf("Unable to start the recreated %s container - restarting the previous one: %v", name, err)
+		cli.ContainerRemove(ctx, created.ID, container.RemoveOptions{Force: true})
+		if renamed {
+			if rerr := cli.ContainerRename(ctx, inspect.ID, name); rerr != nil {
+				logger.Log.Errorf("Unable to rename back %s container: %v", name, rerr)
+			}
+		}
+		if serr := cli.ContainerStart(ctx, inspect.ID, container.StartOptions{}); serr != nil {
+			logger.Log.Errorf("Unable to restart the previous %s container: %v", name, serr)
+		}
+		return err
+	}
+
+	if err := cli.ContainerRemove(ctx, inspect.ID, container.RemoveOptions{}); err != nil {
+		logger.Log.Errorf("Unable to remove the previous %s container: %v", name, err)
+	}
+	logger.Log.Infof("%s container has been recreated with its new environment", name)
+	return nil
+}
+
 func StopContainer(name string) {
+	defer lockContainer(name)()
 	timeout := 30
 
 	// Open Docker API
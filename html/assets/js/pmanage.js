@@ -150,6 +150,8 @@ function updateDoc() {
   var tele = document.getElementById("profileTele");
   var graf = document.getElementById("profileGraf");
   var kapa = document.getElementById("profileKapa");
+  var signer = document.getElementById("profileSigner");
+  var compo = document.getElementById("profileCompo");
   var descpanel = document.getElementById("descpanel");
 
   
@@ -159,6 +161,8 @@ function updateDoc() {
     tele.innerHTML = "";
     graf.innerHTML = "";
     kapa.innerHTML = "";
+    signer.innerHTML = "";
+    compo.innerHTML = "";
     descpanel.classList.add("d-none");
     $('#modifyI').hide();
     $('#resetI').hide();
@@ -184,6 +188,8 @@ function updateDoc() {
             tele.innerHTML = json.tele.trim();
             graf.innerHTML = json.graf.trim();
             kapa.innerHTML = json.kapa.trim();
+            signer.innerHTML = escapeHtml(json.signer) + "</br>SHA-256: " + escapeHtml(json.hash);
+            compo.innerHTML = escapeHtml(json.compo);
             descpanel.classList.remove("d-none");
             $('#modifyI').show();
             $('#resetI').show();
@@ -468,3 +474,217 @@ document
     $('#config').modal('hide');
   });
 
+
+function escapeHtml(s) {
+  return String(s).replace(/[&<>"']/g, function (c) {
+    return { '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' }[c];
+  });
+}
+
+function renderLint(report) {
+  if (!report || !report.issues || report.issues.length == 0) {
+    return "No issue found.";
+  }
+  var html = '<table class="table table-sm"><thead><tr><th>Level</th><th>File</th><th>Message</th></tr></thead><tbody>';
+  report.issues.forEach(function (i) {
+    var color = i.level == "error" ? "red" : "orange";
+    html += '<tr><td style="color:' + color + '">' + escapeHtml(i.level) + '</td><td>' + escapeHtml(i.file) + '</td><td>' + escapeHtml(i.msg) + '</td></tr>';
+  });
+  html += '</tbody></table>';
+  return html;
+}
+
+function uploadProfile() {
+  const fileInput = document.getElementById('profileInput');
+  fileInput.value = "";
+  fileInput.onchange = async () => {
+    // the package and optionally its detached signature
+    const files = Array.from(fileInput.files);
+    const file = files.find(f => f.name.toLowerCase().endsWith('.tgz'));
+    const sig = files.find(f => f.name.toLowerCase().endsWith('.sig'));
+    if (!file) {
+      alertify.alert("JSTO...", "Please select a .tgz profile package and optionally its .sig signature.");
+      return;
+    }
+
+    const formData = new FormData();
+    formData.append('tgzFile', file);
+    if (sig) {
+      formData.append('sigFile', sig);
+    }
+
+    try {
+      waitingDialog.show();
+      const response = await fetch('/uploadprofile', {
+        method: 'POST',
+        body: formData
+      });
+      const result = await response.json();
+      waitingDialog.hide();
+
+      if (result.status == "OK") {
+        alertify.confirm(escapeHtml(result.msg) + "</br></br>" + renderLint(result.data), function (e) {
+          window.location.reload();
+        }).setHeader('JSTO...');
+      } else {
+        alertify.alert(escapeHtml(result.msg), renderLint(result.data)).set('resizable', true).resizeTo('60%', '50%');
+      }
+    } catch (error) {
+      alertify.alert("JSTO...", "An error occurred while uploading the file: " + JSON.stringify(error))
+      waitingDialog.hide();
+    }
+  };
+  fileInput.click();
+}
+
+function pinProfile(p, hash) {
+  $.ajax({
+    type: 'POST',
+    url: "/profilepin",
+    data: JSON.stringify({ "profile": p, "hash": hash }),
+    contentType: "application/json",
+    dataType: "json",
+    success: function (json) {
+      if (json.status == "OK") {
+        alertify.closeAll();
+        alertify.success(json.msg);
+        setTimeout(updateDoc, 2000);
+      } else {
+        alertify.alert("JSTO...", json.msg);
+      }
+    },
+    error: function (xhr, ajaxOptions, thrownError) {
+      alertify.alert("JSTO...", "Unexpected error");
+    }
+  });
+}
+
+function showVersions() {
+  var p = document.getElementById("profiles").value.trim();
+  if (p == "default") {
+    alertify.alert("JSTO...", "Please select a profile.");
+    return;
+  }
+  $.ajax({
+    type: 'POST',
+    url: "/profileversions",
+    data: JSON.stringify({ "profile": p }),
+    contentType: "application/json",
+    dataType: "json",
+    success: function (json) {
+      if (json.status != "OK") {
+        alertify.alert("JSTO...", json.msg);
+        return;
+      }
+      var d = json.data;
+      var html = '<p>' + (d.pinned ? 'Pinned to ' + escapeHtml(d.pinned.substring(0, 12)) +
+        ' <button class="btn btn-sm btn-outline-primary" onclick="pinProfile(\'' + escapeHtml(p) + '\', \'\')">Follow latest</button>' :
+        'Follows the latest package') + '</p>';
+      html += '<table class="table table-sm"><thead><tr><th>Uploaded</th><th>Version</th><th>SHA-256</th><th>Signer</th><th></th></tr></thead><tbody>';
+      d.versions.forEach(function (v) {
+        var state = '';
+        if (v.hash == d.active) {
+          state = '<span class="badge bg-success">active</span>';
+        } else {
+          state = '<button class="btn btn-sm btn-warning" onclick="pinProfile(\'' + escapeHtml(p) + '\', \'' + escapeHtml(v.hash) + '\')">Pin</button>';
+        }
+        html += '<tr><td>' + new Date(v.ts * 1000).toLocaleString() + '</td><td>' + escapeHtml(v.version) +
+          '</td><td title="' + escapeHtml(v.hash) + '">' + escapeHtml(v.hash.substring(0, 12)) + '</td><td>' +
+          escapeHtml(v.signer || '-') + '</td><td>' + state + '</td></tr>';
+      });
+      html += '</tbody></table>';
+      alertify.alert("Versions of profile " + escapeHtml(p), html).set('resizable', true).resizeTo('60%', '50%');
+    },
+    error: function (xhr, ajaxOptions, thrownError) {
+      alertify.alert("JSTO...", "Unexpected error");
+    }
+  });
+}
+
+function paramRequest(data, onSuccess) {
+  $.ajax({
+    type: 'POST',
+    url: "/profileparams",
+    data: JSON.stringify(data),
+    contentType: "application/json",
+    dataType: "json",
+    success: function (json) {
+      if (json.status == "OK") {
+        onSuccess(json);
+      } else {
+        alertify.alert("JSTO...", json.msg);
+      }
+    },
+    error: function (xhr, ajaxOptions, thrownError) {
+      alertify.alert("JSTO...", "Unexpected error");
+    }
+  });
+}
+
+function setParam(p, param, scope, target, value) {
+  paramRequest({ "action": "set", "profile": p, "param": param, "scope": scope, "target": target, "value": value }, function (json) {
+    alertify.closeAll();
+    alertify.success(json.msg);
+    showParams();
+  });
+}
+
+function setRouterGroup(p, router) {
+  var group = document.getElementById("group_" + router).value;
+  paramRequest({ "action": "setgroup", "profile": p, "target": router, "value": group }, function (json) {
+    alertify.closeAll();
+    alertify.success(json.msg);
+    showParams();
+  });
+}
+
+function addParamValue(p) {
+  setParam(p, document.getElementById("newParam").value, document.getElementById("newScope").value,
+    document.getElementById("newTarget").value, document.getElementById("newValue").value);
+}
+
+function showParams() {
+  var p = document.getElementById("profiles").value.trim();
+  if (p == "default") {
+    alertify.alert("JSTO...", "Please select a profile.");
+    return;
+  }
+  paramRequest({ "action": "get", "profile": p }, function (json) {
+    var d = json.data;
+    var ep = escapeHtml(p);
+    if (!d.parameters || d.parameters.length == 0) {
+      alertify.alert("Parameters of profile " + ep, "<p>This profile has no parameter.</p>");
+      return;
+    }
+    var html = '<h6>Declared parameters</h6><table class="table table-sm"><thead><tr><th>Name</th><th>Type</th><th>Default</th><th>Description</th></tr></thead><tbody>';
+    var options = '';
+    d.parameters.forEach(function (e) {
+      html += '<tr><td>' + escapeHtml(e.name) + '</td><td>' + escapeHtml(e.type) + '</td><td>' + escapeHtml(String(e.default)) +
+        '</td><td>' + escapeHtml(e.description || '') + '</td></tr>';
+      options += '<option value="' + escapeHtml(e.name) + '">' + escapeHtml(e.name) + '</option>';
+    });
+    html += '</tbody></table>';
+
+    html += '<h6>Values - a router value wins over a group value</h6><table class="table table-sm"><thead><tr><th>Parameter</th><th>Scope</th><th>Group / Router</th><th>Value</th><th></th></tr></thead><tbody>';
+    d.values.forEach(function (v) {
+      html += '<tr><td>' + escapeHtml(v.param) + '</td><td>' + escapeHtml(v.scope) + '</td><td>' + escapeHtml(v.target) +
+        '</td><td>' + escapeHtml(v.value) + '</td><td><button class="btn btn-sm btn-outline-danger" onclick="setParam(\'' + ep + '\', \'' +
+        escapeHtml(v.param) + '\', \'' + escapeHtml(v.scope) + '\', \'' + escapeHtml(v.target) + '\', \'\')">Remove</button></td></tr>';
+    });
+    html += '<tr><td><select id="newParam" class="form-select form-select-sm">' + options + '</select></td>' +
+      '<td><select id="newScope" class="form-select form-select-sm"><option value="group">group</option><option value="router">router</option></select></td>' +
+      '<td><input id="newTarget" class="form-control form-control-sm"></td><td><input id="newValue" class="form-control form-control-sm"></td>' +
+      '<td><button class="btn btn-sm btn-primary" onclick="addParamValue(\'' + ep + '\')">Set</button></td></tr>';
+    html += '</tbody></table>';
+    html += '<p class="small text-muted">Telegraf and TICKscripts use the values of each router. Dashboards are shared: they use the values of the routers only when all of them share the same values, the default values otherwise.</p>';
+
+    html += '<h6>Router groups</h6><table class="table table-sm"><thead><tr><th>Router</th><th>Group</th><th></th></tr></thead><tbody>';
+    Object.keys(d.routers).sort().forEach(function (r) {
+      var er = escapeHtml(r);
+      html += '<tr><td>' + er + '</td><td><input id="group_' + er + '" class="form-control form-control-sm" value="' + escapeHtml(d.routers[r]) +
+        '"></td><td><button class="btn btn-sm btn-outline-primary" onclick="setRouterGroup(\'' + ep + '\', \'' + er + '\')">Save</button></td></tr>';
+    });
+    html += '</tbody></table>';
+    alertify.alert("Parameters of profile " + ep, html).set('resizable', true).resizeTo('70%', '70%');
+  });
+}
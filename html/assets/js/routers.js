@@ -48,6 +48,12 @@ function addRouter() {
                   <button onclick="reset('${h}', '${s}', this)" class="btn btn-success" style="margin-left: 5px;" type="button">
                       <i class="fa fa-sync" style="font-size: 15px;"></i>
                   </button>
+                  <button onclick="metaHistory('${s}')" class="btn btn-primary" style="margin-left: 5px;" type="button">
+                      <i class="fa fa-history" style="font-size: 15px;"></i>
+                  </button>
+                  <button onclick="reports('${s}')" class="btn btn-primary" style="margin-left: 5px;" type="button">
+                      <i class="fa fa-stethoscope" style="font-size: 15px;"></i>
+                  </button>
                   <button onclick="remove('${s}', this)" class="btn btn-danger" style="margin-left: 5px;" type="submit">
                       <i class="fa fa-trash" style="font-size: 15px;"></i>
                   </button>
@@ -149,6 +155,99 @@ function remove(name, td) {
   }).setHeader('JSTO...');
 }
 
+function escapeHtml(t) {
+  return $('<div>').text(t).html();
+}
+
+function metaHistory(sname) {
+  var dataToSend = {
+    "shortname": sname,
+    "limit": 500
+  };
+  waitingDialog.show();
+  $.ajax({
+    type: 'POST',
+    url: "/metahistory",
+    data: JSON.stringify(dataToSend),
+    contentType: "application/json",
+    dataType: "json",
+    success: function (json) {
+      waitingDialog.hide();
+      if (json.status != "OK") {
+        alertify.alert("JSTO...", json.msg);
+        return;
+      }
+      if (!json.data || json.data.length == 0) {
+        alertify.alert("JSTO...", "No metadata change recorded for router " + sname);
+        return;
+      }
+      var html = '<div style="max-height: 400px; overflow-y: auto;"><table class="table table-striped table-sm">';
+      html += '<thead><tr><th>Date</th><th>Change</th><th>Key</th><th>Old</th><th>New</th></tr></thead><tbody>';
+      json.data.forEach(function (c) {
+        html += '<tr><td>' + new Date(c.ts * 1000).toLocaleString() + '</td><td>' + escapeHtml(c.kind) + '</td><td>' +
+          escapeHtml(c.key) + (c.attribute ? ' (' + escapeHtml(c.attribute) + ')' : '') + '</td><td>' +
+          escapeHtml(c.old) + '</td><td>' + escapeHtml(c.new) + '</td></tr>';
+      });
+      html += '</tbody></table></div>';
+      alertify.alert("Metadata changes of " + sname, html).set('resizable', true).resizeTo('70%', '60%');
+    },
+    error: function (xhr, ajaxOptions, thrownError) {
+      waitingDialog.hide();
+      alertify.alert("JSTO...", "Unexpected error");
+    }
+  });
+}
+
+function reports(sname) {
+  var dataToSend = {
+    "shortname": sname,
+    "limit": 20
+  };
+  waitingDialog.show();
+  $.ajax({
+    type: 'POST',
+    url: "/runreports",
+    data: JSON.stringify(dataToSend),
+    contentType: "application/json",
+    dataType: "json",
+    success: function (json) {
+      waitingDialog.hide();
+      if (json.status != "OK") {
+        alertify.alert("JSTO...", json.msg);
+        return;
+      }
+      if (!json.data || json.data.length == 0) {
+        alertify.alert("JSTO...", "No enrichment run recorded for router " + sname);
+        return;
+      }
+      var html = '<div style="max-height: 450px; overflow-y: auto;">';
+      json.data.forEach(function (r) {
+        html += '<h6>' + new Date(r.ts * 1000).toLocaleString() + ' - ' + (r.success ? '<span class="text-success">OK</span>' : '<span class="text-danger">FAILED</span>') +
+          ' (' + r.duration + ' ms)' + (r.error ? ' - ' + escapeHtml(r.error) : '') + '</h6>';
+        html += '<table class="table table-striped table-sm"><thead><tr><th>RPC</th><th>Duration (ms)</th><th>Size</th><th>Status</th></tr></thead><tbody>';
+        (r.rpcs || []).forEach(function (rpc) {
+          var status = rpc.success ? (rpc.parse_error ? 'Parse error: ' + escapeHtml(rpc.parse_error) : 'OK') : escapeHtml(rpc.error);
+          html += '<tr><td>' + escapeHtml(rpc.rpc) + '</td><td>' + rpc.duration + '</td><td>' + rpc.size + '</td><td>' + status + '</td></tr>';
+        });
+        html += '</tbody></table>';
+        var entities = [];
+        $.each(r.entities || {}, function (k, v) { entities.push(escapeHtml(k) + ': ' + v); });
+        html += '<p><b>Entities:</b> ' + entities.join(', ') + '</p>';
+        if (r.skipped && r.skipped.length > 0) {
+          html += '<p><b>Not tagged (filtered by interface rules):</b> ' + r.skipped.map(escapeHtml).join(', ') + '</p>';
+        }
+        html += '<hr/>';
+      });
+      html += '</div>';
+      alertify.alert("Enrichment reports of " + sname, html).set('resizable', true).resizeTo('70%', '70%');
+    },
+    error: function (xhr, ajaxOptions, thrownError) {
+      waitingDialog.hide();
+      alertify.alert("JSTO...", "Unexpected error");
+    }
+  });
+}
+
 function showInfo() {
   alertify.alert("JSTO...", "CSV file must include these following fields with the ';' separator:</br></br>[shortName];[HostName]</br>");
 }
@@ -13,6 +13,38 @@ function saveSettings() {
   var kFormat = document.getElementById("KafkaFormat").value.trim().toLowerCase();
   var kCompression = document.getElementById("KafkaCompression").value.trim().toLowerCase();
   var kMessageSize = document.getElementById("KafkaMessageSize").value.trim();  
+  var kSasl = document.getElementById("KafkaSasl").value;
+  var kSaslUser = document.getElementById("KafkaSaslUser").value.trim();
+  var kSaslPwd = document.getElementById("KafkaSaslPwd").value;
+  var kTls = document.getElementById("KafkaTls").checked;
+  var kCA = document.getElementById("KafkaCA").value.trim();
+  var kSkip = document.getElementById("KafkaSkipVerify").checked;
+  var kRouting = document.getElementById("KafkaRouting").value;
+  var kTopicTag = document.getElementById("KafkaTopicTag").value.trim();
+  var kRoutes = document.getElementById("KafkaRoutes").value.trim();
+  var kExcluded = document.getElementById("KafkaExcluded").value.trim();
+  var pEnabled = document.getElementById("UsePrometheus").checked;
+  var routes = [];
+  $("#RoutesTable tbody tr").each(function () {
+    routes.push({
+      "output": $(this).data("output"),
+      "namepass": $(this).find(".route-namepass").val().trim(),
+      "fieldpass": $(this).find(".route-fieldpass").val().trim(),
+      "tagpass": $(this).find(".route-tagpass").val().trim()
+    });
+  });
+  var pMode = document.getElementById("PromMode").value;
+  var pPort = document.getElementById("PromPort").value.trim();
+  var pEndpoint = document.getElementById("PromEndpoint").value.trim();
+  var iVersion = document.getElementById("InfluxVersion").value;
+  var iUrl = document.getElementById("InfluxUrl").value.trim();
+  var iDatabase = document.getElementById("InfluxDatabase").value.trim();
+  var iOrg = document.getElementById("InfluxOrg").value.trim();
+  var iUser = document.getElementById("InfluxUser").value.trim();
+  var iPwd = document.getElementById("InfluxPwd").value;
+  var iToken = document.getElementById("InfluxToken").value.trim();
+  var iCA = document.getElementById("InfluxCA").value.trim();
+  var iSkip = document.getElementById("InfluxSkipVerify").checked;
   var mbSize = document.getElementById("MetricBatchSize").value.trim();
   var mbLimit = document.getElementById("MetricBufferLimit").value.trim();
   var flushInterval = document.getElementById("FlushInterval").value.trim();
@@ -93,6 +125,60 @@ function saveSettings() {
     return;
   } 
 
+  if (kEnabled && kSasl != "" && (kSaslUser == "" || kSaslPwd == "")) {
+    alertify.alert("JSTO...", "Kafka SASL username and password cannot be empty if SASL is enabled");
+    return;
+  }
+
+  if (kEnabled && kRouting == "tag" && kTopicTag == "") {
+    alertify.alert("JSTO...", "Kafka topic tag cannot be empty with tag routing");
+    return;
+  }
+
+  if (kEnabled && kRouting == "rules" && !/^\s*[^=,]+=[^=,]+\s*(,\s*[^=,]+=[^=,]+\s*)*$/.test(kRoutes)) {
+    alertify.alert("JSTO...", "Kafka topic per measurement should be a list of measurement=topic");
+    return;
+  }
+  var kafkaTls = "no";
+  if (kTls) {
+    kafkaTls = "yes";
+  }
+  var kafkaSkip = "no";
+  if (kSkip) {
+    kafkaSkip = "yes";
+  }
+
+  if (pPort == "" || isNaN(pPort) || parseInt(pPort) <= 0 || parseInt(pPort) > 65523) {
+    alertify.alert("JSTO...", "Invalid Prometheus port");
+    return;
+  }
+
+  if (pEnabled && pMode == "remotewrite" && !/^https?:\/\//.test(pEndpoint)) {
+    alertify.alert("JSTO...", "Prometheus remote write endpoint should be an http(s) URL");
+    return;
+  }
+  var promEnabled = 0;
+  if (pEnabled) {
+    promEnabled = 1;
+  }
+
+  if (!/^https?:\/\//.test(iUrl)) {
+    alertify.alert("JSTO...", "InfluxDB URL should be an http(s) URL");
+    return;
+  }
+  if (iDatabase == "") {
+    alertify.alert("JSTO...", "InfluxDB database or bucket cannot be empty");
+    return;
+  }
+  if (iVersion != "v1" && (iOrg == "" || iToken == "")) {
+    alertify.alert("JSTO...", "InfluxDB organization and token are required for InfluxDB 2.x and 3.x");
+    return;
+  }
+  var influxSkip = "no";
+  if (iSkip) {
+    influxSkip = "yes";
+  }
+
   if (u == "" || p == "" || u2 == "" || p2 == "") {
     alertify.alert("JSTO...", "Username and password fields cannot be empty");
     return;
@@ -116,7 +202,31 @@ function saveSettings() {
     "kafkaversion": kVersion,
     "kafkaformat": kFormat,
     "kafkacompression": DictKafkaCodec[kCompression],
-    "kafkamessagesize": parseInt(kMessageSize)
+    "kafkamessagesize": parseInt(kMessageSize),
+    "kafkasasl": kSasl,
+    "kafkasasluser": kSaslUser,
+    "kafkasaslpwd": kSaslPwd,
+    "kafkatls": kafkaTls,
+    "kafkaca": kCA,
+    "kafkaskipverify": kafkaSkip,
+    "kafkarouting": kRouting,
+    "kafkatopictag": kTopicTag,
+    "kafkaroutes": kRoutes,
+    "kafkaexcluded": kExcluded,
+    "routes": routes,
+    "promenabled": promEnabled,
+    "prommode": pMode,
+    "promport": parseInt(pPort),
+    "promendpoint": pEndpoint,
+    "influxversion": iVersion,
+    "influxurl": iUrl,
+    "influxdatabase": iDatabase,
+    "influxorg": iOrg,
+    "influxuser": iUser,
+    "influxpwd": iPwd,
+    "influxtoken": iToken,
+    "influxca": iCA,
+    "influxskipverify": influxSkip
   };
   // send data
   $(function () {
@@ -139,4 +249,36 @@ function saveSettings() {
       }
     });
   });
-}
\ No newline at end of file
+}
+function saveAgent(family) {
+  var row = $("#AgentTable tbody tr[data-family='" + family + "']");
+  var dataToSend = {
+    "family": family,
+    "metricbatchsize": row.find(".agent-batch").val().trim(),
+    "metricbufferlimit": row.find(".agent-buffer").val().trim(),
+    "flushinterval": row.find(".agent-flushinterval").val().trim(),
+    "flushjitter": row.find(".agent-flushjitter").val().trim(),
+    "collectionjitter": row.find(".agent-collectionjitter").val().trim(),
+    "logtarget": row.find(".agent-logtarget").val().trim()
+  };
+  // send data
+  $(function () {
+    $.ajax({
+      type: 'POST',
+      url: "/updateagent",
+      data: JSON.stringify(dataToSend),
+      contentType: "application/json",
+      dataType: "json",
+      success: function (json) {
+        if (json.status == "OK") {
+          alertify.success("Agent tuning of the family " + family + " has been successfully updated");
+        } else {
+          alertify.alert("JSTO...", json.msg);
+        }
+      },
+      error: function (xhr, ajaxOptions, thrownError) {
+        alertify.alert("JSTO...", "Unexpected error");
+      }
+    });
+  });
+}
@@ -0,0 +1,229 @@
+package influx
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"jtso/logger"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// Versions of InfluxDB
+const (
+	V1 string = "v1"
+	V2 string = "v2"
+	V3 string = "v3"
+)
+
+// Connection to InfluxDB. Database is the bucket for v2 and v3. Username and
+// Password are used by v1, Token by v2 and v3.
+type Connection struct {
+	Version    string
+	Url        string
+	Database   string
+	Org        string
+	Username   string
+	Password   string
+	Token      string
+	CA         string
+	SkipVerify bool
+}
+
+var (
+	connMu sync.Mutex
+	conn   = Connection{Version: V1, Url: "http://influxdb:8086", Database: "jtsdb"}
+)
+
+// Set the connection used by the management functions
+func SetConnection(c Connection) {
+	connMu.Lock()
+	defer connMu.Unlock()
+	conn = c
+}
+
+// Current connection
+func Current() Connection {
+	connMu.Lock()
+	defer connMu.Unlock()
+	return conn
+}
+
+func (c *Connection) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: c.SkipVerify}
+	if c.CA != "" {
+		pem, err := os.ReadFile(c.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CA)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// InfluxQL client - v1 only
+func (c *Connection) newClient() (client.Client, error) {
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.NewHTTPClient(client.HTTPConfig{
+		Addr:      c.Url,
+		Username:  c.Username,
+		Password:  c.Password,
+		TLSConfig: tlsCfg,
+	})
+}
+
+// Call the HTTP API of v2 and v3 - the JSON answer is decoded into out
+func (c *Connection) api(method string, path string, query url.Values, body []byte, contentType string, out interface{}) error {
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+	httpClient := &http.Client{Timeout: 20 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+
+	u := strings.TrimRight(c.Url, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+c.Token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	payload, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(payload)))
+	}
+	if out != nil {
+		return json.Unmarshal(payload, out)
+	}
+	return nil
+}
+
+// Delete the data of the bucket matching a predicate - empty means all
+func (c *Connection) delete(predicate string) error {
+	if c.Version == V3 {
+		logger.Log.Errorf("Deleting data is not supported by InfluxDB 3")
+		return fmt.Errorf("deleting data is not supported by InfluxDB 3")
+	}
+	body, _ := json.Marshal(map[string]string{
+		"start":     "1970-01-01T00:00:00Z",
+		"stop":      time.Now().UTC().Add(time.Minute).Format(time.RFC3339),
+		"predicate": predicate,
+	})
+	err := c.api(http.MethodPost, "/api/v2/delete", url.Values{"org": {c.Org}, "bucket": {c.Database}}, body, "application/json", nil)
+	if err != nil {
+		logger.Log.Errorf("Unable to delete data from the bucket %s: %v", c.Database, err)
+	}
+	return err
+}
+
+type bucket struct {
+	Id             string `json:"id"`
+	RetentionRules []struct {
+		Type         string `json:"type"`
+		EverySeconds int64  `json:"everySeconds"`
+	} `json:"retentionRules"`
+}
+
+func (c *Connection) findBucket() (*bucket, error) {
+	if c.Version == V3 {
+		return nil, fmt.Errorf("bucket retention is not supported by InfluxDB 3")
+	}
+	var answer struct {
+		Buckets []bucket `json:"buckets"`
+	}
+	err := c.api(http.MethodGet, "/api/v2/buckets", url.Values{"org": {c.Org}, "name": {c.Database}}, nil, "", &answer)
+	if err != nil {
+		return nil, err
+	}
+	if len(answer.Buckets) == 0 {
+		return nil, fmt.Errorf("bucket %s not found", c.Database)
+	}
+	return &answer.Buckets[0], nil
+}
+
+// Retention of the bucket - 0s means infinite like InfluxQL
+func (c *Connection) bucketRetention() (string, error) {
+	b, err := c.findBucket()
+	if err != nil {
+		logger.Log.Errorf("Unable to get the retention of the bucket %s: %v", c.Database, err)
+		return "", err
+	}
+	every := int64(0)
+	for _, r := range b.RetentionRules {
+		if r.Type == "expire" {
+			every = r.EverySeconds
+		}
+	}
+	duration := (time.Duration(every) * time.Second).String()
+	logger.Log.Infof("Retention of the bucket %s is: %s", c.Database, duration)
+	return duration, nil
+}
+
+func (c *Connection) setBucketRetention(duration string) error {
+	d, err := normalizeDuration(duration)
+	if err != nil {
+		return err
+	}
+	b, err := c.findBucket()
+	if err != nil {
+		logger.Log.Errorf("Unable to update the retention of the bucket %s: %v", c.Database, err)
+		return err
+	}
+	rules := []map[string]interface{}{}
+	if d > 0 {
+		rules = append(rules, map[string]interface{}{"type": "expire", "everySeconds": int64(d / time.Second)})
+	}
+	body, _ := json.Marshal(map[string]interface{}{"retentionRules": rules})
+	if err := c.api(http.MethodPatch, "/api/v2/buckets/"+b.Id, nil, body, "application/json", nil); err != nil {
+		logger.Log.Errorf("Unable to update the retention of the bucket %s: %v", c.Database, err)
+		return err
+	}
+	logger.Log.Infof("Retention of the bucket %s modified successfully: Duration=%s", c.Database, duration)
+	return nil
+}
+
+// Write the annotations in line protocol - v2 and v3 accept the v2 write API
+func (c *Connection) writeAnnotations(events []Annotation) error {
+	var lines bytes.Buffer
+	for _, e := range events {
+		pt, err := e.point()
+		if err != nil {
+			logger.Log.Errorf("Unable to create influxdb point: %v", err)
+			return err
+		}
+		lines.WriteString(pt.String())
+		lines.WriteByte('\n')
+	}
+	query := url.Values{"org": {c.Org}, "bucket": {c.Database}, "precision": {"ns"}}
+	if err := c.api(http.MethodPost, "/api/v2/write", query, lines.Bytes(), "text/plain; charset=utf-8", nil); err != nil {
+		logger.Log.Errorf("Unable to write annotations into influxdb: %v", err)
+		return err
+	}
+	logger.Log.Infof("%d annotation(s) written into Influxdb", len(events))
+	return nil
+}
@@ -11,12 +11,15 @@ import (
 )
 
 const (
-	influxDBURL      = "http://influxdb:8086"
-	influxDBDatabase = "jtsdb"
 	influxRetention  = "autogen"
 	DefaultRetention = "30d"
 )
 
+// Double quoted InfluxQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+}
+
 func RetentionDurationEqual(a, b string) (bool, error) {
 	da, err := normalizeDuration(a)
 	if err != nil {
@@ -46,10 +49,16 @@ func normalizeDuration(s string) (time.Duration, error) {
 }
 
 func EmptyDB() error {
+	conn := Current()
+	if conn.Version != V1 {
+		if err := conn.delete(""); err != nil {
+			return err
+		}
+		logger.Log.Infof("Influxdb %s has been successfully empty", conn.Database)
+		return nil
+	}
 	// Create a new HTTP client
-	c, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr: influxDBURL,
-	})
+	c, err := conn.newClient()
 	if err != nil {
 		logger.Log.Errorf("Unable to establish influxdb connexion: %v", err)
 		return err
@@ -60,12 +69,12 @@ func EmptyDB() error {
 	// Create a new query
 	q := client.Query{
 		Command:  "DROP SERIES FROM /.*/",
-		Database: influxDBDatabase,
+		Database: conn.Database,
 	}
 
 	// Execute the query
 	if response, err := c.Query(q); err == nil && response.Error() == nil {
-		logger.Log.Infof("Influxdb %s has been successfully empty", influxDBDatabase)
+		logger.Log.Infof("Influxdb %s has been successfully empty", conn.Database)
 		return nil
 	} else {
 		logger.Log.Errorf("No response from influxdb: %v", err)
@@ -78,11 +87,17 @@ func DropMeasurement(measurement string) error {
 	if measurement == "" {
 		return fmt.Errorf("measurement name cannot be empty")
 	}
+	conn := Current()
+	if conn.Version != V1 {
+		if err := conn.delete(fmt.Sprintf("_measurement=%q", measurement)); err != nil {
+			return err
+		}
+		logger.Log.Infof("InfluxDB measurement %s has been successfully cleared", measurement)
+		return nil
+	}
 
 	// Create a new HTTP client
-	c, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr: influxDBURL,
-	})
+	c, err := conn.newClient()
 	if err != nil {
 		logger.Log.Errorf("Unable to establish InfluxDB connection: %v", err)
 		return err
@@ -91,8 +106,8 @@ func DropMeasurement(measurement string) error {
 
 	// Prepare the query to drop all series from the measurement
 	q := client.Query{
-		Command:  fmt.Sprintf("DROP SERIES FROM \"%s\"", measurement),
-		Database: influxDBDatabase,
+		Command:  "DROP SERIES FROM " + quoteIdent(measurement),
+		Database: conn.Database,
 	}
 
 	// Execute the query
@@ -113,10 +128,16 @@ func DropMeasurement(measurement string) error {
 }
 
 func DropRouter(r string) error {
+	conn := Current()
+	if conn.Version != V1 {
+		if err := conn.delete(fmt.Sprintf("device=%q", r)); err != nil {
+			return err
+		}
+		logger.Log.Infof("Router %s has been successfully removed from Influxdb", r)
+		return nil
+	}
 	// Create a new HTTP client
-	c, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr: influxDBURL,
-	})
+	c, err := conn.newClient()
 	if err != nil {
 		logger.Log.Errorf("Enable to establish influxdb connexion: %v", err)
 		return err
@@ -127,7 +148,7 @@ func DropRouter(r string) error {
 	// Create a new query
 	q := client.Query{
 		Command:  fmt.Sprintf("DROP SERIES FROM /.*/ WHERE device='%s'", strings.ReplaceAll(r, "'", "\\'")),
-		Database: influxDBDatabase,
+		Database: conn.Database,
 	}
 	// Execute the query
 	if response, err := c.Query(q); err == nil && response.Error() == nil {
@@ -140,10 +161,12 @@ func DropRouter(r string) error {
 }
 
 func GetRetentionPolicyDuration() (string, error) {
+	conn := Current()
+	if conn.Version != V1 {
+		return conn.bucketRetention()
+	}
 	// Create a new HTTP client
-	c, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr: influxDBURL,
-	})
+	c, err := conn.newClient()
 	if err != nil {
 		logger.Log.Errorf("Unable to establish influxdb connexion: %v", err)
 		return "", err
@@ -152,8 +175,8 @@ func GetRetentionPolicyDuration() (string, error) {
 
 	// Create the query
 	q := client.Query{
-		Command:  "SHOW RETENTION POLICIES ON " + influxDBDatabase,
-		Database: influxDBDatabase,
+		Command:  "SHOW RETENTION POLICIES ON " + quoteIdent(conn.Database),
+		Database: conn.Database,
 	}
 
 	// Execute the query
@@ -184,10 +207,12 @@ func GetRetentionPolicyDuration() (string, error) {
 }
 
 func AlterRetentionPolicyDuration(duration string) error {
+	conn := Current()
+	if conn.Version != V1 {
+		return conn.setBucketRetention(duration)
+	}
 	// Create a new HTTP client
-	c, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr: influxDBURL,
-	})
+	c, err := conn.newClient()
 	if err != nil {
 		logger.Log.Errorf("Unable to establish influxdb connexion: %v", err)
 		return err
@@ -196,14 +221,14 @@ func AlterRetentionPolicyDuration(duration string) error {
 
 	// Build ALTER RETENTION POLICY query (only changing duration)
 	cmd := fmt.Sprintf(
-		`ALTER RETENTION POLICY "%s" ON "%s" DURATION %s`,
-		influxRetention, influxDBDatabase, duration,
+		`ALTER RETENTION POLICY %s ON %s DURATION %s`,
+		quoteIdent(influxRetention), quoteIdent(conn.Database), duration,
 	)
 
 	// Create the query
 	q := client.Query{
 		Command:  cmd,
-		Database: influxDBDatabase,
+		Database: conn.Database,
 	}
 
 	// Execute the query
@@ -220,3 +245,61 @@ func AlterRetentionPolicyDuration(duration string) error {
 	logger.Log.Infof("Retention policy %s duration modified successfully: Duration=%s", influxRetention, duration)
 	return nil
 }
+
+// Annotation is an event written into InfluxDB to be overlaid on Grafana dashboards
+type Annotation struct {
+	Device string
+	Kind   string
+	Key    string
+	Text   string
+	Time   time.Time
+}
+
+// Point of the annotation in the jtso_events measurement
+func (e *Annotation) point() (*client.Point, error) {
+	tags := map[string]string{"device": e.Device, "kind": e.Kind, "key": e.Key}
+	fields := map[string]interface{}{"title": e.Kind, "text": e.Text}
+	return client.NewPoint("jtso_events", tags, fields, e.Time)
+}
+
+// WriteAnnotations writes a list of annotation events in the jtso_events measurement
+func WriteAnnotations(events []Annotation) error {
+	if len(events) == 0 {
+		return nil
+	}
+	conn := Current()
+	if conn.Version != V1 {
+		return conn.writeAnnotations(events)
+	}
+	// Create a new HTTP client
+	c, err := conn.newClient()
+	if err != nil {
+		logger.Log.Errorf("Unable to establish influxdb connexion: %v", err)
+		return err
+	}
+	defer c.Close()
+
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{
+		Database:        conn.Database,
+		RetentionPolicy: influxRetention,
+		Precision:       "s",
+	})
+	if err != nil {
+		logger.Log.Errorf("Unable to create influxdb batch: %v", err)
+		return err
+	}
+	for _, e := range events {
+		pt, err := e.point()
+		if err != nil {
+			logger.Log.Errorf("Unable to create influxdb point: %v", err)
+			return err
+		}
+		bp.AddPoint(pt)
+	}
+	if err := c.Write(bp); err != nil {
+		logger.Log.Errorf("Unable to write annotations into influxdb: %v", err)
+		return err
+	}
+	logger.Log.Infof("%d annotation(s) written into Influxdb", len(events))
+	return nil
+}
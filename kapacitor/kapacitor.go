@@ -1,6 +1,7 @@
 package kapacitor
 
 import (
+	"jtso/influx"
 	"jtso/logger"
 	"net"
 	"os"
@@ -82,7 +83,7 @@ func StartTick(t []string) error {
 		// Create a new task using the TICK script content
 		ticket := client.CreateTaskOptions{
 			Type:       client.StreamTask,
-			DBRPs:      []client.DBRP{{Database: "jtsdb", RetentionPolicy: "autogen"}},
+			DBRPs:      []client.DBRP{{Database: influx.Current().Database, RetentionPolicy: "autogen"}},
 			TICKscript: string(tickScriptContent),
 			Status:     client.Enabled,
 			ID:         taskName,
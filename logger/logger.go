@@ -62,3 +62,19 @@ func CloseLogger() {
 		logFile.Close()
 	}
 }
+
+// StartConsoleLogger logs only to the console - used by the command line tools
+func StartConsoleLogger() {
+	level := logrus.InfoLevel
+	if Verbose {
+		level = logrus.DebugLevel
+	}
+	Log = &logrus.Logger{
+		Out:   os.Stdout,
+		Level: level,
+		Formatter: &easy.Formatter{
+			TimestampFormat: "2006-01-02 15:04:05",
+			LogFormat:       "%time% [%lvl%] %msg%\n",
+		},
+	}
+}
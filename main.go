@@ -11,9 +11,12 @@ import (
 	"jtso/influx"
 	"jtso/kapacitor"
 	"jtso/logger"
-	_ "jtso/output"
+	"jtso/netconf"
+	"jtso/ondemand"
+	"jtso/output"
 	"jtso/portal"
 	"jtso/sqlite"
+	"jtso/watcher"
 	"jtso/worker"
 	"os"
 	"os/signal"
@@ -42,6 +45,19 @@ const DBPATH = "/etc/jtso/jtso.db"
 
 func main() {
 	var err error
+
+	// Command line tools
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			os.Exit(runReplay(os.Args[2:]))
+		case "simulate":
+			os.Exit(runSimulate(os.Args[2:]))
+		case "profile":
+			os.Exit(runProfile(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 	if ConfigFile == "" {
 		fmt.Println("Please provide the path of the Yaml configuration file")
@@ -56,9 +72,31 @@ func main() {
 	// Create New Config container
 	Cfg := config.NewConfigContainer(ConfigFile)
 
+	// Load the interface selection rules of the enricher
+	err = output.MyMeta.LoadRules(Cfg.Enricher.Interfaces)
+	if err != nil {
+		logger.Log.Errorf("Unable to load enricher interface rules - fallback to default rules: %v", err)
+	}
+
 	// Create a shared Context with cancel function
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Select the NETCONF layer mode (live, record or replay)
+	err = netconf.SetMode(Cfg.Netconf.Mode, Cfg.Netconf.CaptureDir)
+	if err != nil {
+		logger.Log.Errorf("Invalid netconf mode - fallback to live mode: %v", err)
+	}
+
+	err = association.SetSignPolicy(Cfg.Profiles.Signature, Cfg.Profiles.TrustedKeys)
+	if err != nil {
+		logger.Log.Errorf("Invalid profile signature policy - fallback to enforce: %v", err)
+		association.SetSignPolicy(association.SIGN_ENFORCE, Cfg.Profiles.TrustedKeys)
+	}
+	association.SetHistoryKeep(Cfg.Profiles.History)
+
+	// Share the context with the enrichment workers
+	worker.Init(ctx)
+
 	// Clean all kapacitor tasks
 	maxAttempts := Cfg.Kapacitor.BootTimeout
 	for i := 1; i <= maxAttempts; i++ {
@@ -108,26 +146,19 @@ func main() {
 		}
 	}()
 
-	// create a ticker to refresh the profiles
-	ticker2 := time.NewTicker(1 * time.Minute)
-
-	// Create the Thread that periodically refreshes the profiles
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker2.C:
-				association.PeriodicCheck(Cfg)
-			}
-		}
-	}()
+	// Watch the profiles, the ondemand configurations and the certificates
+	w := watcher.New(time.Duration(Cfg.Profiles.Debounce) * time.Second)
+	w.Add("profiles", association.PROFILES, func() { association.PeriodicCheck(Cfg) })
+	w.Add("ondemand", ondemand.PATH_ONDEMAND, ondemand.Refresh)
+	w.Add("certificates", portal.PATH_CERT, portal.ReloadCertificate)
+	go w.Run(ctx, time.Duration(Cfg.Profiles.Rescan)*time.Minute)
 
 	// Clean Active profiles - reset directory
 	association.CleanActiveDirectory()
 
 	// Trigger a first run of some background processes
 	association.PeriodicCheck(Cfg)
+	ondemand.Refresh()
 
 	go worker.Collect(Cfg)
 	go association.ConfigueStack(Cfg, "all")
@@ -177,7 +208,6 @@ func main() {
 
 	// Stop tickers
 	ticker.Stop()
-	ticker2.Stop()
 	ticker3.Stop()
 
 	// close DB
@@ -0,0 +1,604 @@
+package maker
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ---------------------------------------------------- //
+// Reverse import of a Telegraf TOML configuration
+// ---------------------------------------------------- //
+
+// Sections and options of the imported files JTSO does not model
+type ImportReport struct {
+	// e.g. inputs.cpu or agent
+	Unsupported []string `json:"unsupported"`
+	// e.g. inputs.gnmi.tls_min_version
+	Ignored []string `json:"ignored"`
+}
+
+// Options rendered by JTSO from its own settings (routers, credentials,
+// TLS, output connections) - dropped without report
+var importManaged = map[string]bool{
+	"addresses": true, "username": true, "password": true, "enable_tls": true,
+	"tls_ca": true, "tls_cert": true, "tls_key": true, "tls_min_version": true,
+	"insecure_skip_verify": true, "long_field": true, "check_jnpr_extension": true,
+	"bytes2float": true, "time_layout": true, "urls": true, "database": true,
+	"bucket": true, "organization": true, "token": true, "timeout": true,
+	"sasl_mechanism": true, "sasl_username": true, "sasl_password": true,
+	"topic_suffix": true, "topic_tag": true, "headers": true, "metric_version": true,
+}
+
+// A table of the imported file - remembers the consumed options
+type importTable struct {
+	path string
+	m    map[string]any
+	used map[string]bool
+	r    *ImportReport
+}
+
+func newImportTable(path string, m map[string]any, r *ImportReport) *importTable {
+	return &importTable{path: path, m: m, used: make(map[string]bool), r: r}
+}
+
+func (t *importTable) get(key string) (any, bool) {
+	v, ok := t.m[key]
+	t.used[key] = true
+	return v, ok
+}
+
+func (t *importTable) ignore(key string, why string) {
+	t.r.Ignored = append(t.r.Ignored, strings.TrimSpace(t.path+"."+key+" "+why))
+}
+
+func (t *importTable) str(key string) string {
+	v, ok := t.get(key)
+	if !ok {
+		return ""
+	}
+	switch s := v.(type) {
+	case string:
+		return s
+	case int64, float64, bool:
+		return fmt.Sprint(s)
+	}
+	t.ignore(key, "(not a string)")
+	return ""
+}
+
+func (t *importTable) list(key string) []string {
+	v, ok := t.get(key)
+	if !ok {
+		return []string{}
+	}
+	out := make([]string, 0)
+	switch l := v.(type) {
+	case []any:
+		for _, e := range l {
+			out = append(out, fmt.Sprint(e))
+		}
+	case string:
+		out = append(out, l)
+	default:
+		t.ignore(key, "(not a list)")
+	}
+	return out
+}
+
+func (t *importTable) num(key string) int {
+	v, ok := t.get(key)
+	if !ok {
+		return 0
+	}
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case string:
+		if i, err := strconv.Atoi(n); err == nil {
+			return i
+		}
+	}
+	t.ignore(key, "(not a number)")
+	return 0
+}
+
+func (t *importTable) boolean(key string) (bool, bool) {
+	v, ok := t.get(key)
+	if !ok {
+		return false, false
+	}
+	if b, ok := v.(bool); ok {
+		return b, true
+	}
+	t.ignore(key, "(not a boolean)")
+	return false, false
+}
+
+// Duration in seconds - a bare number is a number of seconds as in Telegraf
+func (t *importTable) seconds(key string) int {
+	v, ok := t.get(key)
+	if !ok {
+		return 0
+	}
+	switch d := v.(type) {
+	case int64:
+		return int(d)
+	case float64:
+		return int(math.Ceil(d))
+	case string:
+		if dur, err := time.ParseDuration(d); err == nil {
+			s := int(math.Ceil(dur.Seconds()))
+			if dur > 0 && dur%time.Second != 0 {
+				t.ignore(key, fmt.Sprintf("(%s rounded up to %ds)", d, s))
+			}
+			return s
+		}
+	}
+	t.ignore(key, "(not a duration)")
+	return 0
+}
+
+// Sub tables - a single table or an array of tables
+func (t *importTable) tables(key string) []*importTable {
+	v, ok := t.get(key)
+	if !ok {
+		return nil
+	}
+	return importTables(t.path+"."+key, v, t.r)
+}
+
+// Option with a fixed value in the JTSO template - reported if it differs
+func (t *importTable) fixed(key string, value string) {
+	if v, ok := t.get(key); ok && fmt.Sprint(v) != value {
+		t.ignore(key, fmt.Sprintf("(%v - JTSO renders %s)", v, value))
+	}
+}
+
+// Report the options not consumed
+func (t *importTable) done() {
+	keys := make([]string, 0)
+	for k := range t.m {
+		if !t.used[k] && !importManaged[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		t.ignore(k, "")
+	}
+}
+
+func importTables(path string, v any, r *ImportReport) []*importTable {
+	out := make([]*importTable, 0)
+	switch l := v.(type) {
+	case map[string]any:
+		out = append(out, newImportTable(path, l, r))
+	case []any:
+		for _, e := range l {
+			if m, ok := e.(map[string]any); ok {
+				out = append(out, newImportTable(path, m, r))
+			}
+		}
+	}
+	return out
+}
+
+// Group of an input named <prefix>_<group> by its alias
+func importGroup(t *importTable, prefix string) string {
+	alias := t.str("alias")
+	return strings.TrimPrefix(alias, prefix+"_")
+}
+
+func importGnmi(t *importTable) GnmiInput {
+	g := GnmiInput{
+		Group:      importGroup(t, "gnmi"),
+		Encoding:   t.str("encoding"),
+		Redial:     t.str("redial"),
+		MaxMsgSize: t.str("max_msg_size"),
+		Aliases:    make([]Alias, 0),
+		Subs:       make([]Subscription, 0),
+	}
+	if b, ok := t.boolean("long_tag"); ok && !b {
+		g.LongTag = &b
+	}
+	if b, ok := t.boolean("strip_origin"); ok && !b {
+		g.StripOrigin = &b
+	}
+	for _, a := range t.tables("aliases") {
+		names := make([]string, 0, len(a.m))
+		for n := range a.m {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			g.Aliases = append(g.Aliases, Alias{Name: n, Prefixes: a.list(n)})
+		}
+	}
+	sub := func(s *importTable) Subscription {
+		mode := s.str("subscription_mode")
+		if mode == "" {
+			mode = "sample"
+		}
+		return Subscription{
+			Name:      s.str("name"),
+			Origin:    s.str("origin"),
+			Path:      s.str("path"),
+			Mode:      mode,
+			Interval:  s.seconds("sample_interval"),
+			Heartbeat: s.seconds("heartbeat_interval"),
+		}
+	}
+	for _, s := range t.tables("subscription") {
+		e := sub(s)
+		e.SuppressRedundant, _ = s.boolean("suppress_redundant")
+		g.Subs = append(g.Subs, e)
+		s.done()
+	}
+	for _, s := range t.tables("tag_subscription") {
+		g.TagSubs = append(g.TagSubs, TagSubscription{Subscription: sub(s), Elements: s.list("elements")})
+		s.done()
+	}
+	t.done()
+	return g
+}
+
+func importNetconf(t *importTable) NetconfInput {
+	n := NetconfInput{
+		Group:  importGroup(t, "netconf"),
+		Redial: t.str("redial"),
+		Subs:   make([]NetSubscription, 0),
+	}
+	for _, s := range t.tables("subscription") {
+		sub := NetSubscription{
+			Name:     s.str("name"),
+			RPC:      s.str("junos_rpc"),
+			Interval: s.seconds("sample_interval"),
+			Fields:   make([]NetField, 0),
+		}
+		// <xpath>:<type> - the xpath may contain colons
+		for _, f := range s.list("fields") {
+			i := strings.LastIndex(f, ":")
+			if i < 0 {
+				s.ignore("fields", fmt.Sprintf("(%q has no type)", f))
+				continue
+			}
+			sub.Fields = append(sub.Fields, NetField{FieldPath: f[:i], FieldType: f[i+1:]})
+		}
+		n.Subs = append(n.Subs, sub)
+		s.done()
+	}
+	t.done()
+	return n
+}
+
+func importRename(t *importTable) Rename {
+	p := Rename{Order: t.num("order"), Namepass: t.list("namepass"), Entries: make([]EntryRename, 0)}
+	for _, e := range t.tables("replace") {
+		if tag := e.str("tag"); tag != "" {
+			p.Entries = append(p.Entries, EntryRename{TypeRename: 0, From: tag, To: e.str("dest")})
+		} else if field := e.str("field"); field != "" {
+			p.Entries = append(p.Entries, EntryRename{TypeRename: 1, From: field, To: e.str("dest")})
+		}
+		e.done()
+	}
+	t.done()
+	return p
+}
+
+func importConverter(t *importTable) Converter {
+	p := Converter{Order: t.num("order"), Namepass: t.list("namepass")}
+	// several fields tables are merged
+	for _, f := range t.tables("fields") {
+		p.IntegerType = append(p.IntegerType, f.list("integer")...)
+		p.TagType = append(p.TagType, f.list("tag")...)
+		p.FloatType = append(p.FloatType, f.list("float")...)
+		p.StringType = append(p.StringType, f.list("string")...)
+		p.BoolType = append(p.BoolType, f.list("boolean")...)
+		p.UnsignedType = append(p.UnsignedType, f.list("unsigned")...)
+		f.done()
+	}
+	t.done()
+	return p
+}
+
+func importEnrichment(t *importTable) Enrichment {
+	p := Enrichment{Order: t.num("order"), Namepass: t.list("namepass"), Level1: t.str("level1tagkey"), Level2: t.list("level2tagkey")}
+	p.TwoLevels, _ = t.boolean("twolevels")
+	// /var/metadata/metadata_<family>.json
+	file := filepath.Base(t.str("enrichfilepath"))
+	p.Family = strings.TrimSuffix(strings.TrimPrefix(file, "metadata_"), ".json")
+	t.fixed("refreshperiod", "1")
+	t.done()
+	return p
+}
+
+func importRate(t *importTable) Rate {
+	p := Rate{Order: t.num("order"), Namepass: t.list("namepass"), Fields: t.list("fields")}
+	t.fixed("period", "10m")
+	t.fixed("suffix", "_rate")
+	t.fixed("factor", "1")
+	t.fixed("retention", "1h")
+	t.fixed("delta_min", "10s")
+	t.done()
+	return p
+}
+
+func importMonitoring(t *importTable) Monitoring {
+	p := Monitoring{Order: t.num("order"), Namepass: t.list("namepass"), Probes: make([]Probe, 0)}
+	t.fixed("measurement", "ALARMING")
+	t.fixed("tag_name", "ALARM_TYPE")
+	t.fixed("period", "10m")
+	t.fixed("retention", "1h")
+	for _, e := range t.tables("probe") {
+		p.Probes = append(p.Probes, Probe{
+			Name:      e.str("alarm_name"),
+			Field:     e.str("field"),
+			ProbeType: e.str("probe_type"),
+			Threshold: e.num("threshold"),
+			Operator:  e.str("operator"),
+			Tags:      e.list("tags"),
+		})
+		e.fixed("copy_tag", "true")
+		e.done()
+	}
+	t.done()
+	return p
+}
+
+func importFiltering(t *importTable) Filtering {
+	p := Filtering{Order: t.num("order"), Namepass: t.list("namepass"), Filters: make([]Filter, 0)}
+	for i, key := range []string{"tags", "fields"} {
+		for _, e := range t.tables(key) {
+			p.Filters = append(p.Filters, Filter{FilterType: i, Key: e.str("key"), Pattern: e.str("pattern"), Action: e.str("action")})
+			e.done()
+		}
+	}
+	t.done()
+	return p
+}
+
+func importEnum(t *importTable) Enum {
+	p := Enum{Order: t.num("order"), Namepass: t.list("namepass"), Entries: make([]EnumEntry, 0)}
+	for _, e := range t.tables("mapping") {
+		entry := EnumEntry{Tag: e.str("tag"), Dest: e.str("dest"), Maps: make([]Mapping, 0)}
+		if entry.Tag == "" {
+			// JTSO maps tags only
+			e.r.Ignored = append(e.r.Ignored, e.path+" of the field "+e.str("field")+" (only tag mappings are supported)")
+			continue
+		}
+		for _, v := range e.tables("value_mappings") {
+			in := make([]string, 0, len(v.m))
+			for k := range v.m {
+				in = append(in, k)
+			}
+			sort.Strings(in)
+			for _, k := range in {
+				entry.Maps = append(entry.Maps, Mapping{In: k, Out: v.str(k)})
+			}
+		}
+		p.Entries = append(p.Entries, entry)
+		e.done()
+	}
+	t.done()
+	return p
+}
+
+func importRegex(t *importTable) Regex {
+	p := Regex{Order: t.num("order"), Namepass: t.list("namepass"), Entries: make([]RegEntry, 0)}
+	for i, key := range []string{"tag_rename", "field_rename", "tags", "fields"} {
+		for _, e := range t.tables(key) {
+			entry := RegEntry{
+				RegType:     i,
+				Key:         e.str("key"),
+				Pattern:     e.str("pattern"),
+				Replacement: e.str("replacement"),
+				ResultKey:   e.str("result_key"),
+			}
+			entry.Append, _ = e.boolean("append")
+			p.Entries = append(p.Entries, entry)
+			e.done()
+		}
+	}
+	t.done()
+	return p
+}
+
+func importStrings(t *importTable) Strings {
+	p := Strings{Order: t.num("order"), Namepass: t.list("namepass"), Entries: make([]StrEntry, 0)}
+	for method, key := range []string{"lowercase", "uppercase"} {
+		for _, e := range t.tables(key) {
+			if tag := e.str("tag"); tag != "" {
+				p.Entries = append(p.Entries, StrEntry{StrType: 0, Method: method, Data: tag})
+			} else if field := e.str("field"); field != "" {
+				p.Entries = append(p.Entries, StrEntry{StrType: 1, Method: method, Data: field})
+			}
+			e.done()
+		}
+	}
+	t.done()
+	return p
+}
+
+func importPivot(t *importTable) Pivot {
+	p := Pivot{Order: t.num("order"), Namepass: t.list("namepass"), Tag: t.str("tag_key"), Field: t.str("value_key")}
+	t.done()
+	return p
+}
+
+func importClone(t *importTable) Clone {
+	p := Clone{Order: t.num("order"), Namepass: t.list("namepass"), Override: t.str("name_override")}
+	t.done()
+	return p
+}
+
+func importXreducer(t *importTable) Xreducer {
+	p := Xreducer{Order: t.num("order"), Namepass: t.list("namepass"), Tags: make([]string, 0), Fields: make([]string, 0)}
+	for _, e := range t.tables("tags") {
+		p.Tags = append(p.Tags, e.str("key"))
+		e.done()
+	}
+	for _, e := range t.tables("fields") {
+		p.Fields = append(p.Fields, e.str("key"))
+		e.done()
+	}
+	t.done()
+	return p
+}
+
+// Import a Telegraf TOML configuration - the sections JTSO models are mapped
+// into a TelegrafConfig, the others are listed in the report. Several files
+// of the same agent (telegraf.conf and telegraf.d) can be imported in turn
+// into the same config.
+func ImportToml(content []byte, config *TelegrafConfig, report *ImportReport) error {
+	root := make(map[string]any)
+	if err := toml.Unmarshal(content, &root); err != nil {
+		return fmt.Errorf("invalid TOML: %w", err)
+	}
+
+	sections := make([]string, 0, len(root))
+	for s := range root {
+		sections = append(sections, s)
+	}
+	sort.Strings(sections)
+	for _, s := range sections {
+		plugins, ok := root[s].(map[string]any)
+		if !ok || s == "agent" || s == "global_tags" {
+			report.Unsupported = append(report.Unsupported, s)
+			continue
+		}
+		names := make([]string, 0, len(plugins))
+		for n := range plugins {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			path := s + "." + n
+			if s != "inputs" && s != "processors" && s != "outputs" {
+				// e.g. aggregators or secretstores
+				report.Unsupported = append(report.Unsupported, path)
+				continue
+			}
+			tables := importTables(path, plugins[n], report)
+			switch path {
+			case "inputs.gnmi":
+				for _, t := range tables {
+					config.GnmiList = append(config.GnmiList, importGnmi(t))
+				}
+			case "inputs.netconf_junos":
+				for _, t := range tables {
+					config.NetconfList = append(config.NetconfList, importNetconf(t))
+				}
+			case "processors.rename":
+				for _, t := range tables {
+					config.RenameList = append(config.RenameList, importRename(t))
+				}
+			case "processors.converter":
+				for _, t := range tables {
+					config.ConverterList = append(config.ConverterList, importConverter(t))
+				}
+			case "processors.enrichment":
+				for _, t := range tables {
+					config.EnrichmentList = append(config.EnrichmentList, importEnrichment(t))
+				}
+			case "processors.rate":
+				for _, t := range tables {
+					config.RateList = append(config.RateList, importRate(t))
+				}
+			case "processors.monitoring":
+				for _, t := range tables {
+					config.MonitoringList = append(config.MonitoringList, importMonitoring(t))
+				}
+			case "processors.regex":
+				for _, t := range tables {
+					config.RegexList = append(config.RegexList, importRegex(t))
+				}
+			case "processors.enum":
+				for _, t := range tables {
+					config.EnumList = append(config.EnumList, importEnum(t))
+				}
+			case "processors.strings":
+				for _, t := range tables {
+					config.StringsList = append(config.StringsList, importStrings(t))
+				}
+			case "processors.filtering":
+				for _, t := range tables {
+					config.FilteringList = append(config.FilteringList, importFiltering(t))
+				}
+			case "processors.pivot":
+				for _, t := range tables {
+					config.PivotList = append(config.PivotList, importPivot(t))
+				}
+			case "processors.clone":
+				for _, t := range tables {
+					config.CloneList = append(config.CloneList, importClone(t))
+				}
+			case "processors.xreducer":
+				for _, t := range tables {
+					config.XreducerList = append(config.XreducerList, importXreducer(t))
+				}
+			case "outputs.influxdb", "outputs.influxdb_v2":
+				for _, t := range tables {
+					o := InfluxOutput{Retention: t.str("retention_policy"), Fieldpass: t.list("fieldpass")}
+					if o.Retention == "" {
+						o.Retention = "autogen"
+					}
+					config.InfluxList = append(config.InfluxList, o)
+					t.done()
+				}
+			case "outputs.file":
+				for _, t := range tables {
+					o := FileOutput{Format: t.str("data_format")}
+					if files := t.list("files"); len(files) > 0 {
+						// rendered in /var/log
+						o.Filename = filepath.Base(files[0])
+					}
+					config.FileList = append(config.FileList, o)
+					t.done()
+				}
+			case "outputs.kafka":
+				for _, t := range tables {
+					config.KafkaList = append(config.KafkaList, KafkaOutput{
+						Brokers:          t.list("brokers"),
+						Topic:            t.str("topic"),
+						Format:           t.str("data_format"),
+						Version:          t.str("version"),
+						MessageSize:      t.num("max_message_bytes"),
+						CompressionCodec: t.num("compression_codec"),
+						Fieldpass:        t.list("fieldpass"),
+					})
+					t.done()
+				}
+			case "outputs.prometheus_client":
+				for _, t := range tables {
+					o := PrometheusOutput{Fieldpass: t.list("fieldpass")}
+					if _, port, ok := strings.Cut(t.str("listen"), ":"); ok {
+						o.Port, _ = strconv.Atoi(port)
+					}
+					config.PrometheusList = append(config.PrometheusList, o)
+					t.done()
+				}
+			case "outputs.http":
+				for _, t := range tables {
+					if t.str("data_format") != "prometheusremotewrite" {
+						report.Unsupported = append(report.Unsupported, path)
+						continue
+					}
+					config.PrometheusList = append(config.PrometheusList, PrometheusOutput{Url: t.str("url"), Fieldpass: t.list("fieldpass")})
+					t.done()
+				}
+			default:
+				report.Unsupported = append(report.Unsupported, path)
+			}
+		}
+	}
+	return nil
+}
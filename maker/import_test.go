@@ -0,0 +1,180 @@
+package maker
+
+import (
+	"io"
+	"jtso/logger"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const importSample = `
+[[inputs.gnmi]]
+  alias = "gnmi_core"
+  addresses = ["r1:9339"]
+  encoding = "proto"
+  redial = "10s"
+  long_tag = false
+  [inputs.gnmi.aliases]
+    "/interfaces" = ["/interfaces/interface"]
+  [[inputs.gnmi.subscription]]
+    name = "ifcounters"
+    origin = "openconfig"
+    path = "/interfaces/interface[name=et-0/0/0]/state/counters"
+    subscription_mode = "sample"
+    sample_interval = "30s"
+  [[inputs.gnmi.subscription]]
+    name = "ifstatus"
+    path = "/interfaces/interface/state/oper-status"
+    subscription_mode = "on_change"
+    heartbeat_interval = "60s"
+
+[[inputs.netconf_junos]]
+  alias = "netconf_core"
+  addresses = ["r1:830"]
+  redial = "10s"
+  [[inputs.netconf_junos.subscription]]
+    name = "COMMIT"
+    junos_rpc = "<get-commit-information/>"
+    fields = ["/commit-information/commit-history/sequence-number:int", "/commit-information/commit-history/user:string"]
+    sample_interval = "60s"
+
+[[processors.rename]]
+  order = 1
+  namepass = ["ifcounters"]
+  [[processors.rename.replace]]
+    tag = "/interfaces/interface/name"
+    dest = "device"
+  [[processors.rename.replace]]
+    field = "in-octets"
+    dest = "rx"
+
+[[processors.converter]]
+  order = 2
+  namepass = ["ifcounters"]
+  [processors.converter.fields]
+    integer = ["rx"]
+    tag = ["in-pkts"]
+
+[[processors.enum]]
+  order = 3
+  namepass = ["ifstatus"]
+  [[processors.enum.mapping]]
+    tag = "oper-status"
+    dest = "status"
+    [processors.enum.mapping.value_mappings]
+      UP = "1"
+      DOWN = "0"
+
+[[processors.regex]]
+  order = 4
+  namepass = ["ifcounters"]
+  [[processors.regex.tags]]
+    key = "device"
+    pattern = "^(\\w+)-.*$"
+    replacement = "${1}"
+  [[processors.regex.tag_rename]]
+    pattern = "^name$"
+    replacement = "ifname"
+
+[[processors.strings]]
+  order = 5
+  namepass = ["ifstatus"]
+  [[processors.strings.uppercase]]
+    tag = "status"
+  [[processors.strings.lowercase]]
+    field = "description"
+
+[[processors.xreducer]]
+  order = 6
+  namepass = ["ifcounters"]
+  [[processors.xreducer.tags]]
+    key = "device"
+  [[processors.xreducer.fields]]
+    key = "rx"
+
+[[outputs.influxdb]]
+  urls = ["http://influxdb:8086"]
+  database = "jtsdb"
+  retention_policy = "autogen"
+  fieldpass = ["rx", "status"]
+
+[[outputs.file]]
+  files = ["/var/log/core.log"]
+  data_format = "json"
+
+[[outputs.kafka]]
+  brokers = ["kafka:9092"]
+  topic = "jtso"
+  data_format = "json"
+  version = "2.8.0"
+  max_message_bytes = 1000000
+  compression_codec = 2
+  fieldpass = ["rx"]
+`
+
+func TestImportRoundTrip(t *testing.T) {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+
+	imported := TelegrafConfig{}
+	report := ImportReport{}
+	if err := ImportToml([]byte(importSample), &imported, &report); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(report.Unsupported) > 0 || len(report.Ignored) > 0 {
+		t.Fatalf("unexpected report of the sample: %+v", report)
+	}
+
+	rendered, err := RenderConf(&imported)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	again := TelegrafConfig{}
+	report = ImportReport{}
+	if err := ImportToml([]byte(*rendered), &again, &report); err != nil {
+		t.Fatalf("import of the rendered config: %v\n%s", err, *rendered)
+	}
+	if len(report.Unsupported) > 0 || len(report.Ignored) > 0 {
+		t.Errorf("unexpected report of the rendered config: %+v", report)
+	}
+	if !reflect.DeepEqual(imported, again) {
+		t.Errorf("round trip mismatch\nimported: %+v\nrendered: %+v\n%s", imported, again, *rendered)
+	}
+}
+
+func TestImportReportsScopeAndMergesConverter(t *testing.T) {
+	sample := `
+[[processors.rename]]
+  order = 1
+  namedrop = ["ifstatus"]
+  [processors.rename.tagpass]
+    device = ["r1"]
+  [[processors.rename.replace]]
+    tag = "name"
+    dest = "ifname"
+
+[[processors.converter]]
+  order = 2
+  [[processors.converter.fields]]
+    integer = ["rx"]
+  [[processors.converter.fields]]
+    integer = ["tx"]
+    float = ["load"]
+`
+	config := TelegrafConfig{}
+	report := ImportReport{}
+	if err := ImportToml([]byte(sample), &config, &report); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := []string{"processors.rename.namedrop", "processors.rename.tagpass"}
+	if !reflect.DeepEqual(report.Ignored, want) {
+		t.Errorf("ignored = %v, want %v", report.Ignored, want)
+	}
+	c := config.ConverterList[0]
+	if !reflect.DeepEqual(c.IntegerType, []string{"rx", "tx"}) || !reflect.DeepEqual(c.FloatType, []string{"load"}) {
+		t.Errorf("converter integer = %v, float = %v", c.IntegerType, c.FloatType)
+	}
+}
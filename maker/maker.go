@@ -7,6 +7,7 @@ import (
 	"jtso/logger"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -29,25 +30,37 @@ var order = map[string]int{
 func LoadConfig(filePath string) (*TelegrafConfig, error) {
 
 	// First load JSON file
-	file, err := os.Open(filePath)
+	content, err := os.ReadFile(filePath)
 	if err != nil {
 		logger.Log.Errorf("Error opening file %s: %v", filePath, err)
 		return nil, err
 	}
-	defer file.Close()
+	return ParseConfig(content, filePath)
+}
 
-	// Unmarshall JSON in structure
+// Unmarshal a JSON template already loaded - name is only used in the logs
+func ParseConfig(content []byte, name string) (*TelegrafConfig, error) {
 	var config TelegrafConfig
-	err = json.NewDecoder(file).Decode(&config)
+	err := json.Unmarshal(content, &config)
 	if err != nil {
-		logger.Log.Errorf("Error unmarshaling JSON file %s: %v", filePath, err)
+		logger.Log.Errorf("Error unmarshaling JSON file %s: %v", name, err)
 		return nil, err
 	}
 
-	logger.Log.Debugf("Successfully Load JSON template from %s", filePath)
+	logger.Log.Debugf("Successfully Load JSON template from %s", name)
 	return &config, nil
 }
 
+var tomlEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\r", "\\r", "\t", "\\t")
+
+// Escape a string rendered between double quotes in a TOML file
+func tomlEscape(s string) string {
+	return tomlEscaper.Replace(s)
+}
+
+// Functions of the templates rendering user input
+var templateFuncs = template.FuncMap{"tomlEscape": tomlEscape}
+
 func mergeUniqueInPlaceString(a *[]string, b []string) {
 	unique := make(map[string]struct{}) // Track unique values
 
@@ -105,14 +118,217 @@ func mergeInPlaceStruct(a interface{}, b interface{}) {
 
 }
 
-func findShortestSubstring(A, B string) (string, string) {
-	if strings.Contains(A, B) {
-		return B, "B" // B is a substring of A, return the shorter one (B)
+// Settings of a gNMI input - subscriptions of inputs with the same key are
+// rendered in the same [[inputs.gnmi]] block
+func (g *GnmiInput) key() string {
+	tls := "default"
+	if g.Tls != nil {
+		tls = strconv.FormatBool(*g.Tls)
+	}
+	encoding, redial := g.Encoding, g.Redial
+	if encoding == "" {
+		encoding = "proto"
+	}
+	if redial == "" {
+		redial = "10s"
+	}
+	longTag, stripOrigin := true, true
+	if g.LongTag != nil {
+		longTag = *g.LongTag
+	}
+	if g.StripOrigin != nil {
+		stripOrigin = *g.StripOrigin
+	}
+	return strings.Join([]string{g.Group, encoding, redial, tls, g.MaxMsgSize,
+		strconv.FormatBool(longTag), strconv.FormatBool(stripOrigin)}, "|")
+}
+
+// Index of the input with the settings of target - appended if missing
+func gnmiInputIndex(dst *[]GnmiInput, target GnmiInput) int {
+	for i := range *dst {
+		if (*dst)[i].key() == target.key() {
+			return i
+		}
+	}
+	target.Aliases = []Alias{}
+	target.Subs = []Subscription{}
+	target.TagSubs = []TagSubscription{}
+	*dst = append(*dst, target)
+	return len(*dst) - 1
+}
+
+// Merge tag subscriptions - a subscription with the same name and path keeps
+// the lowest interval and the union of the elements
+func mergeTagSubs(a *[]TagSubscription, b []TagSubscription) {
+	for _, newEntry := range b {
+		match := false
+		for i := range *a {
+			if (*a)[i].Name == newEntry.Name && (*a)[i].Path == newEntry.Path && (*a)[i].Origin == newEntry.Origin {
+				if newEntry.Interval < (*a)[i].Interval {
+					(*a)[i].Interval = newEntry.Interval
+				}
+				mergeUniqueInPlaceString(&(*a)[i].Elements, newEntry.Elements)
+				match = true
+				break
+			}
+		}
+		if !match {
+			newEntry.Elements = append([]string{}, newEntry.Elements...)
+			*a = append(*a, newEntry)
+		}
+	}
+}
+
+// Settings of a NETCONF input
+func (n *NetconfInput) key() string {
+	redial := n.Redial
+	if redial == "" {
+		redial = "10s"
+	}
+	return n.Group + "|" + redial
+}
+
+func mergeAliases(a *[]Alias, b []Alias) {
+	lenAlias := len(*a)
+	for _, newEntry := range b {
+		match := false
+		for i := 0; i < lenAlias; i++ {
+			if newEntry.Name == (*a)[i].Name {
+				mergeUniqueInPlaceString(&(*a)[i].Prefixes, newEntry.Prefixes)
+				match = true
+				break
+			}
+		}
+		if !match {
+			newEntry.Prefixes = append([]string{}, newEntry.Prefixes...)
+			*a = append(*a, newEntry)
+		}
+	}
+}
+
+// Merge gNMI inputs by settings. A subscription naming an input group goes
+// to the input of that group - declared in the same file or with default
+// settings. Aliases follow the subscriptions.
+func mergeGnmiInputs(dst *[]GnmiInput, src []GnmiInput) {
+	declared := make(map[string]GnmiInput)
+	for _, g := range src {
+		if g.Group != "" {
+			declared[g.Group] = g
+		}
+	}
+	for _, g := range src {
+		// tag subscriptions apply to the input they are declared in
+		if len(g.TagSubs) > 0 {
+			index := gnmiInputIndex(dst, g)
+			mergeTagSubs(&(*dst)[index].TagSubs, g.TagSubs)
+		}
+		for _, sub := range g.Subs {
+			target := g
+			if sub.Input != "" && sub.Input != g.Group {
+				if d, ok := declared[sub.Input]; ok {
+					target = d
+				} else {
+					target = GnmiInput{Group: sub.Input}
+				}
+			}
+			index := gnmiInputIndex(dst, target)
+			mergeAliases(&(*dst)[index].Aliases, g.Aliases)
+			(*dst)[index].Subs = append((*dst)[index].Subs, sub)
+		}
+	}
+}
+
+// Merge NETCONF inputs by settings - subscriptions with the same measurement
+// name and RPC are merged
+func mergeNetconfInputs(dst *[]NetconfInput, src []NetconfInput) {
+	declared := make(map[string]NetconfInput)
+	for _, n := range src {
+		if n.Group != "" {
+			declared[n.Group] = n
+		}
 	}
-	if strings.Contains(B, A) {
-		return A, "A" // A is a substring of B, return the shorter one (A)
+	for _, n := range src {
+		for _, newEntry := range n.Subs {
+			target := n
+			if newEntry.Input != "" && newEntry.Input != n.Group {
+				if d, ok := declared[newEntry.Input]; ok {
+					target = d
+				} else {
+					target = NetconfInput{Group: newEntry.Input}
+				}
+			}
+			index := -1
+			for i := range *dst {
+				if (*dst)[i].key() == target.key() {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				target.Subs = []NetSubscription{}
+				*dst = append(*dst, target)
+				index = len(*dst) - 1
+			}
+			in := &(*dst)[index]
+			match := false
+			for i := range in.Subs {
+				// First check if same MEASUREMENT NAME and same RPC
+				if newEntry.Name == in.Subs[i].Name && newEntry.RPC == in.Subs[i].RPC {
+					mergeNetFieldsInPlaceNetField(&in.Subs[i].Fields, newEntry.Fields)
+					match = true
+					break
+				}
+			}
+			if !match {
+				newEntry.Fields = append([]NetField{}, newEntry.Fields...)
+				in.Subs = append(in.Subs, newEntry)
+			}
+		}
 	}
-	return "", "" // No match
+}
+
+// Fold the subscriptions streaming a subset of the data of another
+// subscription of the same input: the paths are compared element by element
+// with their keys, and only subscriptions with the same measurement name,
+// mode and options are folded so that the measurements do not change. The
+// lowest interval is kept.
+func optimizeSubs(subs []Subscription) []Subscription {
+	paths := make([]gnmiPath, len(subs))
+	for i := range subs {
+		paths[i] = parsePath(subs[i].Path)
+		if subs[i].Origin != "" {
+			paths[i].Origin = subs[i].Origin
+		}
+	}
+	folded := make([]bool, len(subs))
+
+	for i := range subs {
+		if folded[i] {
+			continue
+		}
+		for j := range subs {
+			if i == j || folded[j] || subs[i].Name != subs[j].Name || subMode(&subs[i]) != subMode(&subs[j]) ||
+				subs[i].Heartbeat != subs[j].Heartbeat || subs[i].SuppressRedundant != subs[j].SuppressRedundant {
+				continue
+			}
+			// i streams the data of j - for identical paths keep the first one
+			if paths[i].contains(paths[j]) && (!paths[j].contains(paths[i]) || i < j) {
+				if subs[j].Interval < subs[i].Interval {
+					subs[i].Interval = subs[j].Interval
+				}
+				folded[j] = true
+				logger.Log.Infof("gNMI subscription %s %s folded into %s", subs[j].Name, subs[j].Path, subs[i].Path)
+			}
+		}
+	}
+
+	newSubs := subs[:0] // Reuse the existing slice memory
+	for i := range subs {
+		if !folded[i] {
+			newSubs = append(newSubs, subs[i])
+		}
+	}
+	return newSubs
 }
 
 func OptimizeConf(listOfConf []*TelegrafConfig) *TelegrafConfig {
@@ -125,56 +341,14 @@ func OptimizeConf(listOfConf []*TelegrafConfig) *TelegrafConfig {
 		//---------------------------------------------------------------
 		// Optimise GNMI input plugin
 		//---------------------------------------------------------------
-		if len(entry.GnmiList) > 0 {
-			if len(config.GnmiList) == 0 {
-				config.GnmiList = append([]GnmiInput{}, entry.GnmiList...)
-			} else {
-				// Merge Alias first - Today we support only one gNMI INPUT - this explain [0]
-				lenAlias := len(config.GnmiList[0].Aliases)
-				for _, newEntry := range entry.GnmiList[0].Aliases {
-					match := false
-					for i := 0; i < lenAlias; i++ {
-						if newEntry.Name == config.GnmiList[0].Aliases[i].Name {
-							mergeUniqueInPlaceString(&config.GnmiList[0].Aliases[i].Prefixes, newEntry.Prefixes)
-							match = true
-							break
-						}
-					}
-					if !match {
-						config.GnmiList[0].Aliases = append(config.GnmiList[0].Aliases, newEntry)
-					}
-				}
-
-				// Then Merge all subscriptions - optimisation will be done later
-				mergeInPlaceStruct(&config.GnmiList[0].Subs, entry.GnmiList[0].Subs)
-			}
-		}
+		// Inputs are merged by group and settings
+		mergeGnmiInputs(&config.GnmiList, entry.GnmiList)
 
 		//---------------------------------------------------------------
 		// Optimise Netconf input plugin
 		//---------------------------------------------------------------
-		if len(entry.NetconfList) > 0 {
-			if len(config.NetconfList) == 0 {
-				config.NetconfList = append([]NetconfInput{}, entry.NetconfList...)
-			} else {
-				// Merge Subscriptions
-				lenSubs := len(config.NetconfList[0].Subs)
-				for _, newEntry := range entry.NetconfList[0].Subs {
-					match := false
-					for i := 0; i < lenSubs; i++ {
-						// First check if same MEASUREMENT NAME and same RPC
-						if newEntry.Name == config.NetconfList[0].Subs[i].Name && newEntry.RPC == config.NetconfList[0].Subs[i].RPC {
-							mergeNetFieldsInPlaceNetField(&config.NetconfList[0].Subs[i].Fields, newEntry.Fields)
-							match = true
-							break
-						}
-					}
-					if !match {
-						config.NetconfList[0].Subs = append(config.NetconfList[0].Subs, newEntry)
-					}
-				}
-			}
-		}
+		// Inputs are merged by group and settings
+		mergeNetconfInputs(&config.NetconfList, entry.NetconfList)
 
 		//---------------------------------------------------------------
 		// Optimise Clone plugin: No optimisation
@@ -539,48 +713,23 @@ func OptimizeConf(listOfConf []*TelegrafConfig) *TelegrafConfig {
 				mergeUniqueInPlaceString(&config.KafkaList[0].Fieldpass, entry.KafkaList[0].Fieldpass)
 			}
 		}
-	}
-
-	// Last step is to optimize Gnmi subscriptions
-	if len(config.GnmiList) > 0 {
-		newSubs := config.GnmiList[0].Subs[:0] // Reuse the existing slice memory
 
-		for i := 0; i < len(config.GnmiList[0].Subs); i++ {
-			if config.GnmiList[0].Subs[i].Path == "" {
-				continue
-			}
-			for j := 0; j < len(config.GnmiList[0].Subs); j++ {
-				if i != j && config.GnmiList[0].Subs[j].Path != "" {
-					shortestPath, who := findShortestSubstring(config.GnmiList[0].Subs[i].Path, config.GnmiList[0].Subs[j].Path)
-					if shortestPath != "" {
-						if who == "B" {
-							// Keep lowest interval
-							if config.GnmiList[0].Subs[i].Interval < config.GnmiList[0].Subs[j].Interval {
-								config.GnmiList[0].Subs[j].Interval = config.GnmiList[0].Subs[i].Interval
-							}
-							// Mark i for removal
-							config.GnmiList[0].Subs[i].Path = ""
-							break
-						} else {
-							// Keep lowest interval
-							if config.GnmiList[0].Subs[j].Interval < config.GnmiList[0].Subs[i].Interval {
-								config.GnmiList[0].Subs[i].Interval = config.GnmiList[0].Subs[j].Interval
-							}
-							// Mark j for removal
-							config.GnmiList[0].Subs[j].Path = ""
-						}
-					}
-				}
-			}
-		}
-
-		for i := 0; i < len(config.GnmiList[0].Subs); i++ {
-			if config.GnmiList[0].Subs[i].Path != "" {
-				newSubs = append(newSubs, config.GnmiList[0].Subs[i])
+		//---------------------------------------------------------------
+		// Optimise Prometheus output plugin
+		//---------------------------------------------------------------
+		if len(entry.PrometheusList) > 0 {
+			if len(config.PrometheusList) == 0 {
+				config.PrometheusList = append([]PrometheusOutput{}, entry.PrometheusList...)
+			} else {
+				// We merge fieldpass - we support today only one Prometheus Output that explains the [0]
+				mergeUniqueInPlaceString(&config.PrometheusList[0].Fieldpass, entry.PrometheusList[0].Fieldpass)
 			}
 		}
+	}
 
-		config.GnmiList[0].Subs = newSubs
+	// Last step is to optimize Gnmi subscriptions of each input
+	for i := range config.GnmiList {
+		config.GnmiList[i].Subs = optimizeSubs(config.GnmiList[i].Subs)
 	}
 
 	return &config
@@ -629,7 +778,7 @@ func RenderConf(config *TelegrafConfig) (*string, error) {
 	}
 	// Manage Influx Output
 	if len(config.InfluxList) > 0 {
-		t, err := template.New("influxTemplate").Parse(InfluxTemplate)
+		t, err := template.New("influxTemplate").Funcs(templateFuncs).Parse(InfluxTemplate)
 		if err != nil {
 			logger.Log.Errorf("Error parsing Influx template: %v", err)
 		} else {
@@ -645,7 +794,7 @@ func RenderConf(config *TelegrafConfig) (*string, error) {
 	}
 	// Manage File Output
 	if len(config.FileList) > 0 {
-		t, err := template.New("fileTemplate").Parse(FileTemplate)
+		t, err := template.New("fileTemplate").Funcs(templateFuncs).Parse(FileTemplate)
 		if err != nil {
 			logger.Log.Errorf("Error parsing File template: %v", err)
 		} else {
@@ -661,7 +810,7 @@ func RenderConf(config *TelegrafConfig) (*string, error) {
 	}
 	// Manage Kafka Output
 	if len(config.KafkaList) > 0 {
-		t, err := template.New("kafkaTemplate").Parse(KafkaTemplate)
+		t, err := template.New("kafkaTemplate").Funcs(templateFuncs).Parse(KafkaTemplate)
 		if err != nil {
 			logger.Log.Errorf("Error parsing Kafka template: %v", err)
 		} else {
@@ -676,6 +825,15 @@ func RenderConf(config *TelegrafConfig) (*string, error) {
 		}
 	}
 
+	// Manage Prometheus Output
+	if len(config.PrometheusList) > 0 {
+		result, err := RenderPrometheus(config.PrometheusList)
+		if err == nil {
+			footer += *result
+			hasOutput = true
+		}
+	}
+
 	// Stop if no input or output have been generated
 	if !hasInput || !hasOutput {
 		logger.Log.Error("Unable to continue - no Input and Output plugins found or generated")
@@ -828,12 +986,7 @@ func RenderConf(config *TelegrafConfig) (*string, error) {
 
 	// Manage Regex Processor
 	if len(config.RegexList) > 0 {
-		funcMap := template.FuncMap{
-			"tomlEscape": func(s string) string {
-				return strings.ReplaceAll(s, "\\", "\\\\")
-			},
-		}
-		t, err := template.New("regexTemplate").Funcs(funcMap).Parse(RegexTemplate)
+		t, err := template.New("regexTemplate").Funcs(templateFuncs).Parse(RegexTemplate)
 		if err != nil {
 			logger.Log.Errorf("Error parsing Regex template: %v", err)
 		} else {
@@ -883,3 +1036,38 @@ func RenderConf(config *TelegrafConfig) (*string, error) {
 
 	return &fullConfig, nil
 }
+
+// Render only Prometheus outputs - used for the scrape endpoint shared by all
+// the collections of a family
+func RenderPrometheus(list []PrometheusOutput) (*string, error) {
+	t, err := template.New("prometheusTemplate").Funcs(templateFuncs).Parse(PrometheusTemplate)
+	if err != nil {
+		logger.Log.Errorf("Error parsing Prometheus template: %v", err)
+		return nil, err
+	}
+	var result bytes.Buffer
+	err = t.Execute(&result, list)
+	if err != nil {
+		logger.Log.Errorf("Unable to generate Prometheus toml payload - err: %v", err)
+		return nil, err
+	}
+	payload := result.String()
+	return &payload, nil
+}
+
+// Render the [agent] section of the telegraf.conf of a family
+func RenderAgent(agent AgentConfig) (*string, error) {
+	t, err := template.New("agentTemplate").Funcs(templateFuncs).Parse(AgentTemplate)
+	if err != nil {
+		logger.Log.Errorf("Error parsing agent template: %v", err)
+		return nil, err
+	}
+	var result bytes.Buffer
+	err = t.Execute(&result, agent)
+	if err != nil {
+		logger.Log.Errorf("Unable to generate agent toml payload - err: %v", err)
+		return nil, err
+	}
+	payload := result.String()
+	return &payload, nil
+}
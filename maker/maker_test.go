@@ -0,0 +1,119 @@
+package maker
+
+import (
+	"io"
+	"jtso/logger"
+	"reflect"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRenderTagpassEscaping(t *testing.T) {
+	tagpass := map[string][]string{
+		"if.name":  {`et-0/0/0`, `say "hi"`},
+		"a b":      {`c:\temp`},
+		`quote"ed`: {"x"},
+	}
+	outputs := renderOutputs(t, TelegrafConfig{
+		InfluxList: []InfluxOutput{{Retention: "autogen", Fieldpass: []string{`f"1`}, Tagpass: tagpass}},
+		FileList:   []FileOutput{{Filename: "out.log", Format: "json", Tagpass: tagpass}},
+		KafkaList:  []KafkaOutput{{Brokers: []string{"kafka:9092"}, Topic: "jtso", Tagpass: tagpass}},
+		PrometheusList: []PrometheusOutput{
+			{Port: 9273, Tagpass: tagpass},
+			{Url: "http://prom:9090/api/v1/write", Tagpass: tagpass},
+		},
+	})
+	for _, name := range []string{"influxdb", "file", "kafka", "prometheus_client", "http"} {
+		for _, o := range outputs[name].([]any) {
+			got := make(map[string][]string)
+			for k, v := range o.(map[string]any)["tagpass"].(map[string]any) {
+				for _, e := range v.([]any) {
+					got[k] = append(got[k], e.(string))
+				}
+			}
+			if !reflect.DeepEqual(got, tagpass) {
+				t.Errorf("%s tagpass = %v, want %v", name, got, tagpass)
+			}
+		}
+	}
+	if f := outputs["influxdb"].([]any)[0].(map[string]any)["fieldpass"].([]any); f[0] != `f"1` {
+		t.Errorf("influxdb fieldpass = %v", f)
+	}
+}
+
+// Render a config with the given outputs and parse it back
+func renderOutputs(t *testing.T, config TelegrafConfig) map[string]any {
+	t.Helper()
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+
+	config.NetconfList = []NetconfInput{{Subs: []NetSubscription{{Name: "COMMIT", RPC: "<get-commit-information/>"}}}}
+	rendered, err := RenderConf(&config)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	root := make(map[string]any)
+	if err := toml.Unmarshal([]byte(*rendered), &root); err != nil {
+		t.Fatalf("invalid TOML: %v\n%s", err, *rendered)
+	}
+	return root["outputs"].(map[string]any)
+}
+
+func TestRenderInfluxSettingsEscaping(t *testing.T) {
+	outputs := renderOutputs(t, TelegrafConfig{InfluxList: []InfluxOutput{
+		{Retention: "autogen", Database: `my"db`, Url: `http://influx:8086/"x`, Username: `us\er`, CA: `/certs/"ca".pem`},
+		{V2: true, Url: "http://influx:8086", Org: `org" = 1`, Database: "bu\ncket"},
+	}})
+	v1 := outputs["influxdb"].([]any)[0].(map[string]any)
+	for key, want := range map[string]string{"database": `my"db`, "username": `us\er`, "tls_ca": `/certs/"ca".pem`} {
+		if v1[key] != want {
+			t.Errorf("influxdb %s = %v, want %q", key, v1[key], want)
+		}
+	}
+	if urls := v1["urls"].([]any); urls[0] != `http://influx:8086/"x` {
+		t.Errorf("influxdb urls = %v", urls)
+	}
+	v2 := outputs["influxdb_v2"].([]any)[0].(map[string]any)
+	if v2["organization"] != `org" = 1` || v2["bucket"] != "bu\ncket" {
+		t.Errorf("influxdb_v2 organization = %v, bucket = %v", v2["organization"], v2["bucket"])
+	}
+}
+
+func TestRenderKafkaSettingsEscaping(t *testing.T) {
+	outputs := renderOutputs(t, TelegrafConfig{KafkaList: []KafkaOutput{{
+		Brokers:       []string{"kafka:9093"},
+		Topic:         `jtso"topic`,
+		TopicTag:      `tag\name`,
+		Format:        "json",
+		SaslMechanism: "PLAIN",
+		SaslUser:      "us\"er\n",
+		Tls:           true,
+		CA:            `/certs/"ca".pem`,
+	}}})
+	o := outputs["kafka"].([]any)[0].(map[string]any)
+	for key, want := range map[string]string{"topic": `jtso"topic`, "topic_tag": `tag\name`, "sasl_username": "us\"er\n", "tls_ca": `/certs/"ca".pem`} {
+		if o[key] != want {
+			t.Errorf("kafka %s = %v, want %q", key, o[key], want)
+		}
+	}
+}
+
+func TestRenderAgentEscaping(t *testing.T) {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+
+	agent := AgentConfig{MetricBatchSize: "5000", MetricBufferLimit: "100000", CollectionJitter: "0s", FlushInterval: "5s", FlushJitter: "0s", LogFile: `/var/log/"telegraf".log`}
+	rendered, err := RenderAgent(agent)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	root := make(map[string]any)
+	if err := toml.Unmarshal([]byte(*rendered), &root); err != nil {
+		t.Fatalf("invalid TOML: %v\n%s", err, *rendered)
+	}
+	if got := root["agent"].(map[string]any)["logfile"]; got != agent.LogFile {
+		t.Errorf("logfile = %v, want %q", got, agent.LogFile)
+	}
+}
@@ -7,23 +7,24 @@ package maker
 // ---------------------------------------------------- //
 
 type TelegrafConfig struct {
-	GnmiList       []GnmiInput    `json:"gnmi_inputs"`
-	NetconfList    []NetconfInput `json:"netconf_inputs"`
-	CloneList      []Clone        `json:"clone_list"`
-	PivotList      []Pivot        `json:"pivot_list"`
-	RenameList     []Rename       `json:"rename_list"`
-	XreducerList   []Xreducer     `json:"xreducer_list"`
-	ConverterList  []Converter    `json:"converter_list"`
-	EnrichmentList []Enrichment   `json:"enrichment_list"`
-	RateList       []Rate         `json:"rate_list"`
-	MonitoringList []Monitoring   `json:"monitoring_list"`
-	FilteringList  []Filtering    `json:"filtering_list"`
-	EnumList       []Enum         `json:"enum_list"`
-	RegexList      []Regex        `json:"regex_list"`
-	StringsList    []Strings      `json:"strings_list"`
-	FileList       []FileOutput   `json:"file_outputs"`
-	InfluxList     []InfluxOutput `json:"influx_outputs"`
-	KafkaList      []KafkaOutput  `json:"kafka_outputs"`
+	GnmiList       []GnmiInput        `json:"gnmi_inputs"`
+	NetconfList    []NetconfInput     `json:"netconf_inputs"`
+	CloneList      []Clone            `json:"clone_list"`
+	PivotList      []Pivot            `json:"pivot_list"`
+	RenameList     []Rename           `json:"rename_list"`
+	XreducerList   []Xreducer         `json:"xreducer_list"`
+	ConverterList  []Converter        `json:"converter_list"`
+	EnrichmentList []Enrichment       `json:"enrichment_list"`
+	RateList       []Rate             `json:"rate_list"`
+	MonitoringList []Monitoring       `json:"monitoring_list"`
+	FilteringList  []Filtering        `json:"filtering_list"`
+	EnumList       []Enum             `json:"enum_list"`
+	RegexList      []Regex            `json:"regex_list"`
+	StringsList    []Strings          `json:"strings_list"`
+	FileList       []FileOutput       `json:"file_outputs"`
+	InfluxList     []InfluxOutput     `json:"influx_outputs"`
+	KafkaList      []KafkaOutput      `json:"kafka_outputs"`
+	PrometheusList []PrometheusOutput `json:"prometheus_outputs"`
 }
 
 // ---------------------------------------------------- //
@@ -33,10 +34,25 @@ type TelegrafConfig struct {
 type Subscription struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
-	// sample or on_change
+	// Optional origin - overrides the origin of the path
+	Origin string `json:"origin,omitempty"`
+	// sample, on_change or target_defined
 	Mode string `json:"mode"`
 	// in sec
 	Interval int `json:"interval"`
+	// in sec - 0 means no heartbeat
+	Heartbeat         int  `json:"heartbeat_interval,omitempty"`
+	SuppressRedundant bool `json:"suppress_redundant,omitempty"`
+	// Optional input group - the subscription is rendered in this gNMI input
+	Input string `json:"input,omitempty"`
+}
+
+// Subscription whose values are added as tags to the other subscriptions of
+// the input - e.g. interface descriptions streamed on change. Elements are
+// the path elements whose keys must match.
+type TagSubscription struct {
+	Subscription
+	Elements []string `json:"elements"`
 }
 
 type Alias struct {
@@ -44,24 +60,46 @@ type Alias struct {
 	AliasOf  string   `json:"aliasof"`
 	Prefixes []string `json:"prefix_list"`
 }
+
+// Environment variables of the Telegraf containers holding the credentials -
+// the templates only reference them so that no rendered file contains a secret
+const (
+	ENV_GNMI_USERNAME       string = "JTSO_GNMI_USERNAME"
+	ENV_GNMI_PASSWORD       string = "JTSO_GNMI_PASSWORD"
+	ENV_NETCONF_USERNAME    string = "JTSO_NETCONF_USERNAME"
+	ENV_NETCONF_PASSWORD    string = "JTSO_NETCONF_PASSWORD"
+	ENV_INFLUX_PASSWORD     string = "JTSO_INFLUX_PASSWORD"
+	ENV_INFLUX_TOKEN        string = "JTSO_INFLUX_TOKEN"
+	ENV_KAFKA_SASL_PASSWORD string = "JTSO_KAFKA_SASL_PASSWORD"
+)
+
 type GnmiInput struct {
 	Rtrs         []string
-	Username     string
-	Password     string
 	UseTls       bool
 	SkipVerify   bool
 	UseTlsClient bool
-	Aliases      []Alias        `json:"aliases"`
-	Subs         []Subscription `json:"subscriptions"`
+	// Input group and its settings - inputs with the same settings are merged
+	Group    string `json:"group"`
+	Encoding string `json:"encoding"`
+	Redial   string `json:"redial"`
+	Tls      *bool  `json:"tls,omitempty"`
+	// e.g. 4MB - empty means the Telegraf default
+	MaxMsgSize  string            `json:"max_msg_size,omitempty"`
+	LongTag     *bool             `json:"long_tag,omitempty"`
+	StripOrigin *bool             `json:"strip_origin,omitempty"`
+	Aliases     []Alias           `json:"aliases"`
+	Subs        []Subscription    `json:"subscriptions"`
+	TagSubs     []TagSubscription `json:"tag_subscriptions,omitempty"`
 }
 
-// Go Template Receive a list of GnmiInput (we should only have one) = GnmiList
+// Go Template Receive a list of GnmiInput (one per input group) = GnmiList
 
 const GnmiInputTemplate = `
 ###############################################################################
 #                               GNMI INPUT PLUGIN                             #
 ###############################################################################
-{{range .}}[[inputs.gnmi]]
+{{range .}}[[inputs.gnmi]] {{if .Group}}
+  alias = "gnmi_{{.Group}}" {{end}}
   addresses = [
       {{- range $index, $name := .Rtrs}}
       {{- if $index}},{{end}}
@@ -69,8 +107,8 @@ const GnmiInputTemplate = `
       {{- end}}
       ]
 
-  username = "{{.Username}}"
-  password = "{{.Password}}" {{if .UseTls}}
+  username = "${JTSO_GNMI_USERNAME}"
+  password = "${JTSO_GNMI_PASSWORD}" {{if .UseTls}}
   ## enable client-side TLS and define CA to authenticate the device
   enable_tls = true
   tls_ca = "/var/cert/RootCA.crt"
@@ -83,17 +121,18 @@ const GnmiInputTemplate = `
   tls_cert = "/var/cert/client.crt"
   tls_key = "/var/cert/client.key" {{end}}
   {{end}}
-  encoding = "proto"
-  redial = "10s"
-  long_tag = true
+  encoding = "{{if .Encoding}}{{.Encoding}}{{else}}proto{{end}}"
+  redial = "{{if .Redial}}{{.Redial}}{{else}}10s{{end}}" {{if .MaxMsgSize}}
+  max_msg_size = "{{.MaxMsgSize}}" {{end}}
+  long_tag = {{if .LongTag}}{{.LongTag}}{{else}}true{{end}}
   long_field = true
-  strip_origin = true
+  strip_origin = {{if .StripOrigin}}{{.StripOrigin}}{{else}}true{{end}}
   check_jnpr_extension = true
   bytes2float = true
 
     {{if .Aliases}} 
     [inputs.gnmi.aliases] {{range .Aliases}}
-      {{.Name}} = [
+      "{{.Name}}" = [
       {{- range $index, $name := .Prefixes}}
       {{- if $index}},{{end}}
       "{{$name}}"
@@ -101,10 +140,27 @@ const GnmiInputTemplate = `
       ]{{end}}{{end}}
 	{{range .Subs}}
     [[inputs.gnmi.subscription]]
-      name = "{{.Name}}"
+      name = "{{.Name}}" {{if .Origin}}
+      origin = "{{.Origin}}" {{end}}
       path = "{{.Path}}"
       subscription_mode = "{{.Mode}}"
-      sample_interval = "{{.Interval}}s"
+      sample_interval = "{{.Interval}}s" {{if .Heartbeat}}
+      heartbeat_interval = "{{.Heartbeat}}s" {{end}}{{if .SuppressRedundant}}
+      suppress_redundant = true {{end}}
+  {{end}}{{range .TagSubs}}
+    [[inputs.gnmi.tag_subscription]]
+      name = "{{.Name}}" {{if .Origin}}
+      origin = "{{.Origin}}" {{end}}
+      path = "{{.Path}}"
+      subscription_mode = "{{.Mode}}"
+      sample_interval = "{{.Interval}}s" {{if .Heartbeat}}
+      heartbeat_interval = "{{.Heartbeat}}s" {{end}}
+      elements = [
+      {{- range $index, $name := .Elements}}
+      {{- if $index}},{{end}}
+      "{{$name}}"
+      {{- end}}
+      ]
   {{end}}
 {{end}}
 `
@@ -124,22 +180,26 @@ type NetSubscription struct {
 	Fields []NetField `json:"fields"`
 	// in sec
 	Interval int `json:"interval"`
+	// Optional input group - the subscription is rendered in this NETCONF input
+	Input string `json:"input,omitempty"`
 }
 
 type NetconfInput struct {
-	Rtrs     []string
-	Username string
-	Password string
-	Subs     []NetSubscription `json:"subscriptions"`
+	Rtrs []string
+	// Input group and its settings - inputs with the same settings are merged
+	Group  string            `json:"group"`
+	Redial string            `json:"redial"`
+	Subs   []NetSubscription `json:"subscriptions"`
 }
 
-// Go Template Receive a list of NetconfInput (we should only have one) = NetconfList
+// Go Template Receive a list of NetconfInput (one per input group) = NetconfList
 
 const NetconfInputTemplate = `
 ###############################################################################
 #                             NETCONF INPUT PLUGIN                            #
 ###############################################################################
-{{range .}}[[inputs.netconf_junos]]
+{{range .}}[[inputs.netconf_junos]] {{if .Group}}
+  alias = "netconf_{{.Group}}" {{end}}
   ## Address of the Juniper NETCONF server
   addresses = [
       {{- range $index, $name := .Rtrs}}
@@ -149,11 +209,11 @@ const NetconfInputTemplate = `
       ]
 
   ## define credentials
-  username = "{{.Username}}"
-  password = "{{.Password}}"
+  username = "${JTSO_NETCONF_USERNAME}"
+  password = "${JTSO_NETCONF_PASSWORD}"
 
   ## redial in case of failures after
-  redial = "10s"
+  redial = "{{if .Redial}}{{.Redial}}{{else}}10s{{end}}"
 
   ## Time Layout for epoch convertion - specify a sample Date/Time layout - default layout is the following:
   time_layout = "2006-01-02 15:04:05 MST"
@@ -719,6 +779,17 @@ const StringTemplate = `
 type InfluxOutput struct {
 	Retention string
 	Fieldpass []string `json:"fieldpass"`
+	// Connection - filled by JTSO, the defaults target the local InfluxDB 1.x
+	V2         bool   `json:"-"`
+	Url        string `json:"-"`
+	Database   string `json:"-"`
+	Org        string `json:"-"`
+	Username   string `json:"-"`
+	CA         string `json:"-"`
+	SkipVerify bool   `json:"-"`
+	// Routing policy
+	Namepass []string            `json:"-"`
+	Tagpass  map[string][]string `json:"-"`
 }
 
 // Go Template Receive a list of InfluxOutput (we should only have one) = InfluxList
@@ -727,17 +798,33 @@ const InfluxTemplate = `
 ###############################################################################
 #                              INFLUX OUTPUT PLUGIN                           #
 ###############################################################################
-{{range .}}[[outputs.influxdb]]
-  database="jtsdb"
-  urls = ["http://influxdb:8086"]
-  timeout = "20s"
-  retention_policy = "{{.Retention}}"
+{{range .}}{{if .V2}}[[outputs.influxdb_v2]]
+  urls = ["{{tomlEscape .Url}}"]
+  organization = "{{tomlEscape .Org}}"
+  bucket = "{{tomlEscape .Database}}"
+  token = "${JTSO_INFLUX_TOKEN}"{{else}}[[outputs.influxdb]]
+  database="{{if .Database}}{{tomlEscape .Database}}{{else}}jtsdb{{end}}"
+  urls = ["{{if .Url}}{{tomlEscape .Url}}{{else}}http://influxdb:8086{{end}}"]
+  retention_policy = "{{tomlEscape .Retention}}"{{if .Username}}
+  username = "{{tomlEscape .Username}}"
+  password = "${JTSO_INFLUX_PASSWORD}"{{end}}{{end}}
+  timeout = "20s"{{if .CA}}
+  tls_ca = "{{tomlEscape .CA}}"{{end}}{{if .SkipVerify}}
+  insecure_skip_verify = true{{end}}{{if .Namepass}}
+  namepass = [
+  {{- range $index, $name := .Namepass}}
+  {{- if $index}},{{end}}
+      "{{tomlEscape $name}}"
+  {{- end}}
+  ]{{end}}
   fieldpass = [
   {{- range $index, $name := .Fieldpass}}
   {{- if $index}},{{end}}
-      "{{$name}}"
+      "{{tomlEscape $name}}"
   {{- end}}
-  ]
+  ]{{if .Tagpass}}
+  [outputs.{{if .V2}}influxdb_v2{{else}}influxdb{{end}}.tagpass]{{range $tag, $values := .Tagpass}}
+    "{{tomlEscape $tag}}" = [{{range $index, $value := $values}}{{if $index}}, {{end}}"{{tomlEscape $value}}"{{end}}]{{end}}{{end}}
 {{end}}
 `
 
@@ -748,6 +835,10 @@ const InfluxTemplate = `
 type FileOutput struct {
 	Filename string `json:"filename"`
 	Format   string `json:"fieldpass"`
+	// Routing policy
+	Namepass  []string            `json:"-"`
+	Fieldpass []string            `json:"-"`
+	Tagpass   map[string][]string `json:"-"`
 }
 
 // Go Template Receive a list of FileOutput (we should only have one) = FileList
@@ -758,7 +849,21 @@ const FileTemplate = `
 ###############################################################################
 {{range .}}[[outputs.file]]
   files = ["/var/log/{{.Filename}}"]
-  data_format = "{{.Format}}"
+  data_format = "{{.Format}}"{{if .Namepass}}
+  namepass = [
+  {{- range $index, $name := .Namepass}}
+  {{- if $index}},{{end}}
+      "{{tomlEscape $name}}"
+  {{- end}}
+  ]{{end}}{{if .Fieldpass}}
+  fieldpass = [
+  {{- range $index, $name := .Fieldpass}}
+  {{- if $index}},{{end}}
+      "{{tomlEscape $name}}"
+  {{- end}}
+  ]{{end}}{{if .Tagpass}}
+  [outputs.file.tagpass]{{range $tag, $values := .Tagpass}}
+    "{{tomlEscape $tag}}" = [{{range $index, $value := $values}}{{if $index}}, {{end}}"{{tomlEscape $value}}"{{end}}]{{end}}{{end}}
 {{end}}
 `
 
@@ -774,9 +879,24 @@ type KafkaOutput struct {
 	MessageSize      int      `json:"message_size"`
 	CompressionCodec int      `json:"compression_codec"`
 	Fieldpass        []string `json:"fieldpass"`
+	// Security - filled by JTSO. SaslMechanism is PLAIN, SCRAM-SHA-256 or
+	// SCRAM-SHA-512, empty means no SASL
+	SaslMechanism string `json:"-"`
+	SaslUser      string `json:"-"`
+	Tls           bool   `json:"-"`
+	CA            string `json:"-"`
+	SkipVerify    bool   `json:"-"`
+	// Routing - the topic is suffixed by the measurement, or taken from a tag.
+	// Namepass and Namedrop split the measurements between several outputs.
+	TopicSuffix bool     `json:"-"`
+	TopicTag    string   `json:"-"`
+	Namepass    []string `json:"-"`
+	Namedrop    []string `json:"-"`
+	// Routing policy
+	Tagpass map[string][]string `json:"-"`
 }
 
-// Go Template Receive a list of KafkaOutput (we should only have one) = KafkaList
+// Go Template Receive a list of KafkaOutput = KafkaList
 
 const KafkaTemplate = `
 ###############################################################################
@@ -786,19 +906,116 @@ const KafkaTemplate = `
   brokers = [
   {{- range $index, $name := .Brokers}}
   {{- if $index}},{{end}}
-      "{{$name}}"
+      "{{tomlEscape $name}}"
   {{- end}}
   ]
-  topic = "{{.Topic}}"
-  data_format = "{{.Format}}"
-  version = "{{.Version}}"
+  topic = "{{tomlEscape .Topic}}"{{if .TopicTag}}
+  topic_tag = "{{tomlEscape .TopicTag}}"{{end}}
+  data_format = "{{tomlEscape .Format}}"
+  version = "{{tomlEscape .Version}}"
   max_message_bytes = {{.MessageSize}}
-  compression_codec = {{.CompressionCodec}}
+  compression_codec = {{.CompressionCodec}}{{if .SaslMechanism}}
+  sasl_mechanism = "{{.SaslMechanism}}"
+  sasl_username = "{{tomlEscape .SaslUser}}"
+  sasl_password = "${JTSO_KAFKA_SASL_PASSWORD}"{{end}}{{if .Tls}}
+  enable_tls = true{{if .CA}}
+  tls_ca = "{{tomlEscape .CA}}"{{end}}{{if .SkipVerify}}
+  insecure_skip_verify = true{{end}}{{end}}{{if .Namepass}}
+  namepass = [
+  {{- range $index, $name := .Namepass}}
+  {{- if $index}},{{end}}
+      "{{tomlEscape $name}}"
+  {{- end}}
+  ]{{end}}{{if .Namedrop}}
+  namedrop = [
+  {{- range $index, $name := .Namedrop}}
+  {{- if $index}},{{end}}
+      "{{tomlEscape $name}}"
+  {{- end}}
+  ]{{end}}
   fieldpass = [
   {{- range $index, $name := .Fieldpass}}
   {{- if $index}},{{end}}
-      "{{$name}}"
+      "{{tomlEscape $name}}"
   {{- end}}
-  ]
+  ]{{if .Tagpass}}
+  [outputs.kafka.tagpass]{{range $tag, $values := .Tagpass}}
+    "{{tomlEscape $tag}}" = [{{range $index, $value := $values}}{{if $index}}, {{end}}"{{tomlEscape $value}}"{{end}}]{{end}}{{end}}{{if .TopicSuffix}}
+  [outputs.kafka.topic_suffix]
+    method = "measurement"
+    separator = "."{{end}}
+{{end}}
+`
+
+// ---------------------------------------------------- //
+// Prometheus Output plugin
+// ---------------------------------------------------- //
+
+// Url set means remote write to this endpoint, otherwise a scrape endpoint
+// is exposed on Port
+type PrometheusOutput struct {
+	Port      int      `json:"port"`
+	Url       string   `json:"url"`
+	Fieldpass []string `json:"fieldpass"`
+	// Routing policy
+	Namepass []string            `json:"-"`
+	Tagpass  map[string][]string `json:"-"`
+}
+
+// Go Template Receive a list of PrometheusOutput (we should only have one) = PrometheusList
+
+const PrometheusTemplate = `
+###############################################################################
+#                            PROMETHEUS OUTPUT PLUGIN                         #
+###############################################################################
+{{range .}}{{if .Url}}[[outputs.http]]
+  url = "{{.Url}}"
+  data_format = "prometheusremotewrite"{{else}}[[outputs.prometheus_client]]
+  listen = ":{{.Port}}"
+  metric_version = 2{{end}}{{if .Namepass}}
+  namepass = [
+  {{- range $index, $name := .Namepass}}
+  {{- if $index}},{{end}}
+      "{{tomlEscape $name}}"
+  {{- end}}
+  ]{{end}}
+  fieldpass = [
+  {{- range $index, $name := .Fieldpass}}
+  {{- if $index}},{{end}}
+      "{{tomlEscape $name}}"
+  {{- end}}
+  ]{{if .Url}}
+  [outputs.http.headers]
+    Content-Type = "application/x-protobuf"
+    Content-Encoding = "snappy"
+    X-Prometheus-Remote-Write-Version = "0.1.0"{{end}}{{if .Tagpass}}
+  [outputs.{{if .Url}}http{{else}}prometheus_client{{end}}.tagpass]{{range $tag, $values := .Tagpass}}
+    "{{tomlEscape $tag}}" = [{{range $index, $value := $values}}{{if $index}}, {{end}}"{{tomlEscape $value}}"{{end}}]{{end}}{{end}}
 {{end}}
 `
+
+// Telegraf agent of a family - Extra holds the lines of the [agent] section
+// not managed by JTSO, kept as they are
+type AgentConfig struct {
+	MetricBatchSize   string
+	MetricBufferLimit string
+	FlushInterval     string
+	FlushJitter       string
+	CollectionJitter  string
+	Debug             bool
+	LogFile           string
+	Extra             []string
+}
+
+// Go Template Receive an AgentConfig
+
+const AgentTemplate = `[agent]{{range .Extra}}
+{{.}}{{end}}
+  metric_batch_size = {{.MetricBatchSize}}
+  metric_buffer_limit = {{.MetricBufferLimit}}
+  collection_jitter = "{{.CollectionJitter}}"
+  flush_interval = "{{.FlushInterval}}"
+  flush_jitter = "{{.FlushJitter}}"
+  debug = {{.Debug}}
+  logfile = "{{tomlEscape .LogFile}}"
+`
@@ -0,0 +1,102 @@
+package maker
+
+import (
+	"io"
+	"jtso/logger"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+)
+
+func TestOptimizeConfInputGroups(t *testing.T) {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+
+	sub := func(name, path, input string) Subscription {
+		return Subscription{Name: name, Path: path, Mode: "sample", Interval: 30, Input: input}
+	}
+	alias := func(name string) Alias {
+		return Alias{Name: name, Prefixes: []string{"/" + name}}
+	}
+	field := func(path string) NetField {
+		return NetField{FieldPath: path, FieldType: "int"}
+	}
+	commit := func(f NetField) NetSubscription {
+		return NetSubscription{Name: "COMMIT", RPC: "<get-commit-information/>", Interval: 60, Fields: []NetField{f}}
+	}
+	// the first profile sends one subscription to the slow group, declared by
+	// the second profile
+	first := &TelegrafConfig{
+		GnmiList: []GnmiInput{{
+			Aliases: []Alias{alias("a")},
+			Subs:    []Subscription{sub("s1", "/interfaces", ""), sub("s2", "/components", "slow")},
+		}},
+		NetconfList: []NetconfInput{{Subs: []NetSubscription{commit(field("/commit/user"))}}},
+	}
+	second := &TelegrafConfig{
+		GnmiList: []GnmiInput{
+			{Aliases: []Alias{alias("b")}, Subs: []Subscription{sub("s4", "/lldp", "")}},
+			{Group: "slow", Aliases: []Alias{alias("c")}, Subs: []Subscription{sub("s3", "/system", "")}},
+		},
+		NetconfList: []NetconfInput{{Subs: []NetSubscription{commit(field("/commit/date"))}}},
+		FileList:    []FileOutput{{Filename: "out.log", Format: "json"}},
+	}
+
+	rendered, err := RenderConf(OptimizeConf([]*TelegrafConfig{first, second}))
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	root := make(map[string]any)
+	if err := toml.Unmarshal([]byte(*rendered), &root); err != nil {
+		t.Fatalf("invalid TOML: %v\n%s", err, *rendered)
+	}
+	inputs := root["inputs"].(map[string]any)
+
+	gnmi := inputs["gnmi"].([]any)
+	if len(gnmi) != 2 {
+		t.Fatalf("%d gnmi inputs rendered, want 2\n%s", len(gnmi), *rendered)
+	}
+	type block struct {
+		subs    []string
+		aliases []string
+	}
+	got := make(map[string]block)
+	for _, g := range gnmi {
+		in := g.(map[string]any)
+		b := block{}
+		for _, s := range in["subscription"].([]any) {
+			b.subs = append(b.subs, s.(map[string]any)["name"].(string))
+		}
+		for a := range in["aliases"].(map[string]any) {
+			b.aliases = append(b.aliases, a)
+		}
+		sort.Strings(b.subs)
+		sort.Strings(b.aliases)
+		alias, _ := in["alias"].(string)
+		got[alias] = b
+	}
+	want := map[string]block{
+		// aliases follow the subscriptions
+		"":          {subs: []string{"s1", "s4"}, aliases: []string{"a", "b"}},
+		"gnmi_slow": {subs: []string{"s2", "s3"}, aliases: []string{"a", "c"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("gnmi inputs = %+v, want %+v", got, want)
+	}
+
+	netconf := inputs["netconf_junos"].([]any)
+	if len(netconf) != 1 {
+		t.Fatalf("%d netconf inputs rendered, want 1", len(netconf))
+	}
+	subs := netconf[0].(map[string]any)["subscription"].([]any)
+	if len(subs) != 1 {
+		t.Fatalf("%d netconf subscriptions rendered, want the merged COMMIT one", len(subs))
+	}
+	fields := subs[0].(map[string]any)["fields"].([]any)
+	if !reflect.DeepEqual(fields, []any{"/commit/user:int", "/commit/date:int"}) {
+		t.Errorf("netconf fields = %v", fields)
+	}
+}
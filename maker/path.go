@@ -0,0 +1,122 @@
+package maker
+
+import (
+	"strings"
+)
+
+// Element of a gNMI path with its keys - /interfaces/interface[name=et-0/0/0]
+type pathElem struct {
+	Name string
+	Keys map[string]string
+}
+
+// Parsed gNMI path
+type gnmiPath struct {
+	Origin string
+	Elems  []pathElem
+}
+
+// Parse a gNMI path: an optional origin ("origin:/path"), then elements
+// separated by "/" - a "/" inside a key value is not a separator
+func parsePath(path string) gnmiPath {
+	var p gnmiPath
+	path = strings.TrimSpace(path)
+	if i := strings.Index(path, ":/"); i > 0 && !strings.ContainsAny(path[:i], "/[") {
+		p.Origin = path[:i]
+		path = path[i+1:]
+	}
+
+	var elem strings.Builder
+	depth := 0
+	flush := func() {
+		if elem.Len() > 0 {
+			p.Elems = append(p.Elems, parseElem(elem.String()))
+			elem.Reset()
+		}
+	}
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '\\' && i+1 < len(path):
+			elem.WriteByte(c)
+			elem.WriteByte(path[i+1])
+			i++
+			continue
+		case c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case c == '/' && depth == 0:
+			flush()
+			continue
+		}
+		elem.WriteByte(c)
+	}
+	flush()
+	return p
+}
+
+// Parse "name[k1=v1][k2=v2]"
+func parseElem(s string) pathElem {
+	e := pathElem{Keys: make(map[string]string)}
+	i := strings.IndexByte(s, '[')
+	if i < 0 {
+		e.Name = s
+		return e
+	}
+	e.Name = s[:i]
+	for rest := s[i:]; strings.HasPrefix(rest, "["); {
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			break
+		}
+		k, v, _ := strings.Cut(rest[1:end], "=")
+		e.Keys[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		rest = rest[end+1:]
+	}
+	return e
+}
+
+// Origins are compatible when they are the same - no origin is the
+// openconfig origin
+func compatibleOrigin(a, b string) bool {
+	norm := func(o string) string {
+		if o == "" {
+			return "openconfig"
+		}
+		return o
+	}
+	return norm(a) == norm(b)
+}
+
+// True if every data of b is also streamed by a: same origin, a is a prefix
+// of b and every key of a selects the same entry in b. A missing key or a "*"
+// value matches any entry.
+func (a gnmiPath) contains(b gnmiPath) bool {
+	if !compatibleOrigin(a.Origin, b.Origin) || len(a.Elems) > len(b.Elems) {
+		return false
+	}
+	for i, ea := range a.Elems {
+		eb := b.Elems[i]
+		if ea.Name != "*" && ea.Name != eb.Name {
+			return false
+		}
+		for k, v := range ea.Keys {
+			if v == "*" {
+				continue
+			}
+			if vb, ok := eb.Keys[k]; !ok || vb != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Subscription mode - sample is the default of the gNMI input
+func subMode(s *Subscription) string {
+	if s.Mode == "" {
+		return "sample"
+	}
+	return strings.ToLower(s.Mode)
+}
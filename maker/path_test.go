@@ -0,0 +1,96 @@
+package maker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+		elems  []pathElem
+	}{
+		{
+			name:  "plain",
+			path:  "/interfaces/interface/state",
+			elems: []pathElem{{Name: "interfaces", Keys: map[string]string{}}, {Name: "interface", Keys: map[string]string{}}, {Name: "state", Keys: map[string]string{}}},
+		},
+		{
+			name:  "slash in key value",
+			path:  "/interfaces/interface[name=et-0/0/0]/state",
+			elems: []pathElem{{Name: "interfaces", Keys: map[string]string{}}, {Name: "interface", Keys: map[string]string{"name": "et-0/0/0"}}, {Name: "state", Keys: map[string]string{}}},
+		},
+		{
+			name:  "several keys",
+			path:  "/network-instances/network-instance[name=default]/protocols/protocol[identifier=BGP][name=bgp]",
+			elems: []pathElem{{Name: "network-instances", Keys: map[string]string{}}, {Name: "network-instance", Keys: map[string]string{"name": "default"}}, {Name: "protocols", Keys: map[string]string{}}, {Name: "protocol", Keys: map[string]string{"identifier": "BGP", "name": "bgp"}}},
+		},
+		{
+			name:   "origin",
+			path:   "openconfig:/interfaces",
+			origin: "openconfig",
+			elems:  []pathElem{{Name: "interfaces", Keys: map[string]string{}}},
+		},
+		{
+			name:  "colon in key value is not an origin",
+			path:  "/components/component[name=FPC0:/PIC1]",
+			elems: []pathElem{{Name: "components", Keys: map[string]string{}}, {Name: "component", Keys: map[string]string{"name": "FPC0:/PIC1"}}},
+		},
+		{
+			name:  "wildcards",
+			path:  "/interfaces/*/subinterface[index=*]",
+			elems: []pathElem{{Name: "interfaces", Keys: map[string]string{}}, {Name: "*", Keys: map[string]string{}}, {Name: "subinterface", Keys: map[string]string{"index": "*"}}},
+		},
+		{
+			name:  "escaped slash",
+			path:  `/junos/a\/b`,
+			elems: []pathElem{{Name: "junos", Keys: map[string]string{}}, {Name: `a\/b`, Keys: map[string]string{}}},
+		},
+		{
+			name: "root",
+			path: "/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parsePath(tt.path)
+			if p.Origin != tt.origin {
+				t.Errorf("origin = %q, want %q", p.Origin, tt.origin)
+			}
+			if !reflect.DeepEqual(p.Elems, tt.elems) {
+				t.Errorf("elems = %+v, want %+v", p.Elems, tt.elems)
+			}
+		})
+	}
+}
+
+func TestPathContains(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"identical", "/interfaces/interface[name=et-0/0/0]/state", "/interfaces/interface[name=et-0/0/0]/state", true},
+		{"prefix", "/interfaces", "/interfaces/interface/state/counters", true},
+		{"longer", "/interfaces/interface/state", "/interfaces", false},
+		{"other branch", "/interfaces/interface/state", "/interfaces/interface/config", false},
+		{"missing key matches any entry", "/interfaces/interface", "/interfaces/interface[name=et-0/0/0]", true},
+		{"key not in the other path", "/interfaces/interface[name=et-0/0/0]", "/interfaces/interface", false},
+		{"same key value with slash", "/interfaces/interface[name=et-0/0/0]", "/interfaces/interface[name=et-0/0/0]/state", true},
+		{"other key value", "/interfaces/interface[name=et-0/0/0]", "/interfaces/interface[name=et-0/0/1]/state", false},
+		{"wildcard key", "/interfaces/interface[name=*]", "/interfaces/interface[name=et-0/0/1]/state", true},
+		{"wildcard element", "/interfaces/*/state", "/interfaces/interface/state/counters", true},
+		{"default origin", "openconfig:/interfaces", "/interfaces/interface", true},
+		{"other origin", "junos:/interfaces", "/interfaces/interface", false},
+		{"same origin", "junos:/system", "junos:/system/linecard", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePath(tt.a).contains(parsePath(tt.b)); got != tt.want {
+				t.Errorf("%s contains %s = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package netconf
+
+import (
+	"fmt"
+	"jtso/logger"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/openshift-telco/go-netconf-client/netconf"
+	"github.com/openshift-telco/go-netconf-client/netconf/message"
+	"golang.org/x/crypto/ssh"
+)
+
+// NETCONF layer modes
+const (
+	MODE_LIVE   string = "live"
+	MODE_RECORD string = "record"
+	MODE_REPLAY string = "replay"
+)
+
+// A NETCONF client able to issue RPCs - either a live session or a capture
+type Client interface {
+	// Issue the RPC and return the raw reply
+	RPC(request string, timeout int) (string, error)
+	Close() error
+}
+
+var (
+	modeMu     sync.RWMutex
+	mode       string = MODE_LIVE
+	captureDir string = "/var/capture/"
+)
+
+// File of the capture of a router holding its family
+const familyFile = "family"
+
+var reTag = regexp.MustCompile(`<([a-zA-Z0-9-]+)\s*/?>`)
+
+// Select the NETCONF layer mode. In record mode every RPC request and raw
+// reply is saved under dir/<router>/. In replay mode the captured replies are
+// served instead of opening a session.
+func SetMode(m string, dir string) error {
+	m = strings.ToLower(strings.TrimSpace(m))
+	if m == "" {
+		m = MODE_LIVE
+	}
+	if m != MODE_LIVE && m != MODE_RECORD && m != MODE_REPLAY {
+		return fmt.Errorf("unknown netconf mode %s", m)
+	}
+	modeMu.Lock()
+	defer modeMu.Unlock()
+	mode = m
+	if dir != "" {
+		captureDir = dir
+	}
+	if mode != MODE_LIVE {
+		logger.Log.Infof("Netconf layer running in %s mode - capture directory %s", mode, captureDir)
+	}
+	return nil
+}
+
+func currentMode() (string, string) {
+	modeMu.RLock()
+	defer modeMu.RUnlock()
+	return mode, captureDir
+}
+
+// Build a stable file name from the RPC request
+// <get-interface-information><terse/></get-interface-information> => get-interface-information_terse
+func rpcFileName(request string) string {
+	seen := make(map[string]bool)
+	parts := make([]string, 0)
+	for _, m := range reTag.FindAllStringSubmatch(request, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			parts = append(parts, m[1])
+		}
+	}
+	if len(parts) == 0 {
+		return "rpc"
+	}
+	return strings.Join(parts, "_")
+}
+
+// Capture directory of a router
+func routerDir(dir string, router string) string {
+	return filepath.Join(dir, strings.ReplaceAll(router, string(os.PathSeparator), "_"))
+}
+
+// Live NETCONF session over SSH
+type sshClient struct {
+	session *netconf.Session
+}
+
+func (c *sshClient) RPC(request string, timeout int) (string, error) {
+	reply, err := c.session.SyncRPC(message.NewRPC(request), int32(timeout))
+	if err != nil {
+		return "", err
+	}
+	if reply == nil {
+		return "", fmt.Errorf("empty reply")
+	}
+	return reply.Data, nil
+}
+
+func (c *sshClient) Close() error {
+	return c.session.Close()
+}
+
+// Live client saving every request and reply
+type recordClient struct {
+	Client
+	dir string
+}
+
+func (c *recordClient) RPC(request string, timeout int) (string, error) {
+	data, err := c.Client.RPC(request, timeout)
+	if err != nil {
+		return data, err
+	}
+	name := rpcFileName(request)
+	if werr := os.WriteFile(filepath.Join(c.dir, name+".request.xml"), []byte(request), 0644); werr != nil {
+		logger.Log.Errorf("Unable to capture the request %s: %v", name, werr)
+	}
+	if werr := os.WriteFile(filepath.Join(c.dir, name+".xml"), []byte(data), 0644); werr != nil {
+		logger.Log.Errorf("Unable to capture the reply %s: %v", name, werr)
+	}
+	return data, nil
+}
+
+// Save the family of a router in its capture - replayed with the same family
+func recordFamily(router string, family string) {
+	m, dir := currentMode()
+	if m != MODE_RECORD {
+		return
+	}
+	rdir := routerDir(dir, router)
+	if err := os.WriteFile(filepath.Join(rdir, familyFile), []byte(family+"\n"), 0644); err != nil {
+		logger.Log.Errorf("[%s] Unable to capture the family: %v", router, err)
+	}
+}
+
+// Family recorded in the capture of a router - empty if unknown
+func CapturedFamily(dir string, router string) string {
+	data, err := os.ReadFile(filepath.Join(routerDir(dir, router), familyFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Client serving captured replies
+type replayClient struct {
+	dir string
+}
+
+func (c *replayClient) RPC(request string, timeout int) (string, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, rpcFileName(request)+".xml"))
+	if err != nil {
+		return "", fmt.Errorf("no captured reply for %s: %v", rpcFileName(request), err)
+	}
+	return string(data), nil
+}
+
+func (c *replayClient) Close() error {
+	return nil
+}
+
+// Open a NETCONF client according to the current mode
+func Open(router string, port int, sshConfig *ssh.ClientConfig) (Client, error) {
+	m, dir := currentMode()
+
+	if m == MODE_REPLAY {
+		rdir := routerDir(dir, router)
+		if _, err := os.Stat(rdir); err != nil {
+			return nil, fmt.Errorf("no capture for router %s: %v", router, err)
+		}
+		return &replayClient{dir: rdir}, nil
+	}
+
+	session, err := netconf.DialSSH(fmt.Sprintf("%s:%d", router, port), sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	err = session.SendHello(&message.Hello{Capabilities: netconf.DefaultCapabilities})
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("error while sending Hello: %v", err)
+	}
+	c := &sshClient{session: session}
+
+	if m == MODE_RECORD {
+		rdir := routerDir(dir, router)
+		if err := os.MkdirAll(rdir, 0755); err != nil {
+			logger.Log.Errorf("[%s] Unable to create the capture directory: %v", router, err)
+			return c, nil
+		}
+		return &recordClient{Client: c, dir: rdir}, nil
+	}
+	return c, nil
+}
@@ -1,15 +1,15 @@
 package netconf
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"jtso/logger"
 	"jtso/output"
+	"jtso/sqlite"
 	"jtso/xml"
 	"strings"
-	"sync"
+	"time"
 
-	"github.com/openshift-telco/go-netconf-client/netconf"
-	"github.com/openshift-telco/go-netconf-client/netconf/message"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -21,8 +21,9 @@ type RouterTask struct {
 	Family  string
 	Port    int
 	Timeout int
-	Wg      *sync.WaitGroup
 	Jsonify *output.Metadata
+	// Number of run reports to keep for the router - 0 or less disables the report
+	Reports int
 }
 
 func GetFacts(r string, u string, p string, port int, timeout int) (*xml.Version, error) {
@@ -37,42 +38,34 @@ func GetFacts(r string, u string, p string, port int, timeout int) (*xml.Version
 	var replyVersion *xml.Version
 	var HwInfo *xml.Hw
 
-	session, err := netconf.DialSSH(fmt.Sprintf("%s:%d", r, port), sshConfig)
+	session, err := Open(r, port, sshConfig)
 	if err != nil {
 		logger.Log.Errorf("[%s] Unable to open Netconf session: %v", r, err)
 		return nil, err
 	}
 
 	defer session.Close()
-	capabilities := netconf.DefaultCapabilities
-	err = session.SendHello(&message.Hello{Capabilities: capabilities})
-	if err != nil {
-		logger.Log.Errorf("[%s] Error while sending Hello: %v", r, err)
-		return nil, err
-	}
 
 	d := "<get-software-information></get-software-information>"
-	rpc := message.NewRPC(d)
-	reply, err := session.SyncRPC(rpc, int32(timeout))
-	if err != nil || reply == nil || strings.Contains(reply.Data, "<rpc-error>") {
+	reply, err := session.RPC(d, timeout)
+	if err != nil || strings.Contains(reply, "<rpc-error>") {
 		logger.Log.Warnf("[%s] No Version information: %v", r, err)
 		return nil, err
 
 	} else {
 		// Unmarshall the reply
-		replyVersion, err = xml.ParseVersion(reply.Data)
+		replyVersion, err = xml.ParseVersion(reply)
 		if err != nil {
 			logger.Log.Warnf("[%s] Unable to parse version information: %v", r, err)
 			logger.Log.Warnf("[%s] Try another command", r)
 			d := "<get-software-information><local/></get-software-information>"
-			rpc := message.NewRPC(d)
-			reply, err := session.SyncRPC(rpc, int32(timeout))
-			if err != nil || reply == nil || strings.Contains(reply.Data, "<rpc-error>") {
+			reply, err := session.RPC(d, timeout)
+			if err != nil || strings.Contains(reply, "<rpc-error>") {
 				logger.Log.Errorf("[%s] No Version information: %v", r, err)
 				return nil, err
 			} else {
 				// Unmarshall the reply
-				replyVersion, err = xml.ParseVersion(reply.Data)
+				replyVersion, err = xml.ParseVersion(reply)
 				if err != nil {
 					logger.Log.Errorf("[%s] Unable to parse version information: %v", r, err)
 					return nil, err
@@ -88,14 +81,13 @@ func GetFacts(r string, u string, p string, port int, timeout int) (*xml.Version
 	case "ptx10001-36mr":
 		// here we have to check if it's a real ptx or vjunosevolved
 		d = "<get-chassis-inventory></get-chassis-inventory>"
-		rpc = message.NewRPC(d)
-		reply, err = session.SyncRPC(rpc, int32(timeout))
-		if err != nil || reply == nil || strings.Contains(reply.Data, "<rpc-error>") {
+		reply, err = session.RPC(d, timeout)
+		if err != nil || strings.Contains(reply, "<rpc-error>") {
 			logger.Log.Errorf("[%s] No Chassis HW information: %v", r, err)
 			return nil, err
 		} else {
 			// Unmarshall the reply
-			HwInfo, err = xml.ParseChassis(reply.Data)
+			HwInfo, err = xml.ParseChassis(reply)
 			if err != nil {
 				logger.Log.Errorf("[%s] Unable to parse chassis hardware: %v", r, err)
 				return nil, err
@@ -119,10 +111,25 @@ func GetFacts(r string, u string, p string, port int, timeout int) (*xml.Version
 	return replyVersion, nil
 }
 
+// Failure to open the session - the only error the worker pool retries
+type DialError struct {
+	Err error
+}
+
+func (e *DialError) Error() string { return e.Err.Error() }
+func (e *DialError) Unwrap() error { return e.Err }
+
+// Implements worker.Retryable
+func (e *DialError) Retryable() bool { return true }
+
+// Id of the task
+func (r *RouterTask) Id() string {
+	return r.Name
+}
+
 // The Worker function
-func (r *RouterTask) Work() error {
+func (r *RouterTask) Work(ctx context.Context) error {
 	defer logger.HandlePanic()
-	defer r.Wg.Done()
 
 	logger.Log.Infof("[%s] Start collecting and updating Metadata", r.Name)
 
@@ -142,103 +149,155 @@ func (r *RouterTask) Work() error {
 	rawData.RtrName = r.Name
 	rawData.Family = r.Family
 
-	var hasIf, hasHw, hasLacp, hasIsis bool
-
-	session, err := netconf.DialSSH(fmt.Sprintf("%s:%d", r.Name, r.Port), sshConfig)
+	var hasDesc, hasTerse, hasHw, hasLacp, hasIsis bool
 
-	if err != nil {
-		logger.Log.Errorf("[%s] Unable to open Netconf session: %v", r.Name, err)
-		return err
+	// Run report of this collection - stored at the end of the run
+	report := &sqlite.RunReport{
+		Router:    r.Name,
+		Timestamp: time.Now().Unix(),
+		Rpcs:      make([]*sqlite.RpcReport, 0),
+		Entities:  make(map[string]int),
+		Skipped:   make([]string, 0),
 	}
+	start := time.Now()
+	defer func() {
+		report.DurationMs = time.Since(start).Milliseconds()
+		if r.Reports <= 0 {
+			return
+		}
+		if err := sqlite.AddRunReport(report, r.Reports); err != nil {
+			logger.Log.Errorf("[%s] Unable to store the run report: %v", r.Name, err)
+		}
+	}()
 
-	defer session.Close()
+	// Bound the dial by the task deadline
+	dialTimeout := time.Duration(r.Timeout) * time.Second
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < dialTimeout {
+		dialTimeout = time.Until(deadline)
+	}
+	sshConfig.Timeout = dialTimeout
+	session, err := Open(r.Name, r.Port, sshConfig)
 
-	capabilities := netconf.DefaultCapabilities
-	err = session.SendHello(&message.Hello{Capabilities: capabilities})
 	if err != nil {
-		logger.Log.Errorf("[%s] Error while sending Hello: %v", r.Name, err)
-		return err
+		logger.Log.Errorf("[%s] Unable to open Netconf session: %v", r.Name, err)
+		report.Error = "Unable to open Netconf session: " + err.Error()
+		if ctx.Err() != nil {
+			return err
+		}
+		return &DialError{Err: err}
 	}
 
-	d := "<get-interface-information><descriptions/></get-interface-information>"
-	rpc := message.NewRPC(d)
-	reply, err := session.SyncRPC(rpc, int32(r.Timeout))
-	if err != nil || reply == nil || strings.Contains(reply.Data, "<rpc-error>") {
-		logger.Log.Warnf("[%s] No interfaces description information: %v", r.Name, err)
+	defer session.Close()
+	recordFamily(r.Name, r.Family)
+
+	// Close the session as soon as the task is cancelled or its deadline expires
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			logger.Log.Warnf("[%s] Collection cancelled: %v", r.Name, ctx.Err())
+			session.Close()
+		case <-done:
+		}
+	}()
 
+	data, ok := r.rpcCall(session, report, "interface-descriptions", "<get-interface-information><descriptions/></get-interface-information>")
+	if !ok {
+		logger.Log.Warnf("[%s] No interfaces description information: %v", r.Name, lastRpcError(report))
 	} else {
 		// Unmarshall the reply
-		rawData.IfDesc, err = xml.ParseIfdesc(reply.Data)
+		rawData.IfDesc, err = xml.ParseIfdesc(data)
 		if err != nil {
 			logger.Log.Warnf("[%s] Unable to parse interface description: %v", r.Name, err)
+			parseFailed(report, err)
 		} else {
-			hasIf = true
+			hasDesc = true
+			report.Entities["descriptions-physical"] = len(rawData.IfDesc.Physicals)
+			report.Entities["descriptions-logical"] = len(rawData.IfDesc.Logicals)
 		}
-
 	}
 
-	d = "<get-interface-information><terse/></get-interface-information>"
-	rpc = message.NewRPC(d)
-	reply, err = session.SyncRPC(rpc, int32(r.Timeout))
-	if err != nil || reply == nil || strings.Contains(reply.Data, "<rpc-error>") {
-		logger.Log.Warnf("[%s] No interfaces terse information: %v", r.Name, err)
-
+	data, ok = r.rpcCall(session, report, "interface-terse", "<get-interface-information><terse/></get-interface-information>")
+	if !ok {
+		logger.Log.Warnf("[%s] No interfaces terse information: %v", r.Name, lastRpcError(report))
 	} else {
 		// Unmarshall the reply
-		rawData.IfList, err = xml.ParseIflist(reply.Data)
+		rawData.IfList, err = xml.ParseIflist(data)
 		if err != nil {
 			logger.Log.Warnf("[%s] Unable to parse interface terse: %v", r.Name, err)
+			parseFailed(report, err)
 		} else {
-			hasIf = true
+			hasTerse = true
+			report.Entities["interfaces"] = len(rawData.IfList.Physicals)
+			// Keep track of the interfaces filtered out by the enricher rules
+			names := make([]string, 0, len(rawData.IfList.Physicals))
+			for _, phy := range rawData.IfList.Physicals {
+				names = append(names, strings.Trim(phy.Name, "\n"))
+			}
+			report.Skipped = r.Jsonify.Skipped(r.Family, names)
 		}
-
 	}
 
-	d = "<get-chassis-inventory></get-chassis-inventory>"
-	rpc = message.NewRPC(d)
-	reply, err = session.SyncRPC(rpc, int32(r.Timeout))
-	if err != nil || reply == nil || strings.Contains(reply.Data, "<rpc-error>") {
-		logger.Log.Warnf("[%s] No Chassis HW information: %v", r.Name, err)
+	data, ok = r.rpcCall(session, report, "chassis-inventory", "<get-chassis-inventory></get-chassis-inventory>")
+	if !ok {
+		logger.Log.Warnf("[%s] No Chassis HW information: %v", r.Name, lastRpcError(report))
 	} else {
 		// Unmarshall the reply
-		rawData.HwInfo, err = xml.ParseChassis(reply.Data)
+		rawData.HwInfo, err = xml.ParseChassis(data)
 		if err != nil {
 			logger.Log.Warnf("[%s] Unable to parse chassis hardware: %v", r.Name, err)
+			parseFailed(report, err)
 		} else {
 			hasHw = true
+			report.Entities["modules"] = len(rawData.HwInfo.Chassis.Modules)
 		}
 	}
 
-	d = "<get-lacp-interface-information></get-lacp-interface-information>"
-	rpc = message.NewRPC(d)
-	reply, err = session.SyncRPC(rpc, int32(r.Timeout))
-	if err != nil || reply == nil || strings.Contains(reply.Data, "<rpc-error>") {
-		logger.Log.Warnf("[%s] No LACP Interface information: %v", r.Name, err)
+	data, ok = r.rpcCall(session, report, "lacp-interfaces", "<get-lacp-interface-information></get-lacp-interface-information>")
+	if !ok {
+		logger.Log.Warnf("[%s] No LACP Interface information: %v", r.Name, lastRpcError(report))
 	} else {
 		// Unmarshall the reply
-		rawData.LacpInfo, rawData.LacpDigest, err = xml.ParseLacp(reply.Data)
+		rawData.LacpInfo, rawData.LacpDigest, err = xml.ParseLacp(data)
 		if err != nil {
 			logger.Log.Warnf("[%s] Unable to parse LACP Interface: %v", r.Name, err)
+			parseFailed(report, err)
 		} else {
 			hasLacp = true
+			report.Entities["lag-members"] = len(rawData.LacpDigest.LacpMap)
 		}
 	}
 
-	d = "<get-isis-overview-information></get-isis-overview-information>"
-	rpc = message.NewRPC(d)
-	reply, err = session.SyncRPC(rpc, int32(r.Timeout))
-	if err != nil || reply == nil || strings.Contains(reply.Data, "<rpc-error>") {
-		logger.Log.Warnf("[%s] No ISIS Overview information: %v", r.Name, err)
+	data, ok = r.rpcCall(session, report, "isis-overview", "<get-isis-overview-information></get-isis-overview-information>")
+	if !ok {
+		logger.Log.Warnf("[%s] No ISIS Overview information: %v", r.Name, lastRpcError(report))
 	} else {
 		// Unmarshall the reply
-		rawData.IsisInfo, err = xml.ParseIsis(reply.Data)
+		rawData.IsisInfo, err = xml.ParseIsis(data)
 		if err != nil {
 			logger.Log.Warnf("[%s] Unable to parse ISIS Overview: %v", r.Name, err)
+			parseFailed(report, err)
 		} else {
 			hasIsis = true
+			report.Entities["isis-instances"] = len(rawData.IsisInfo.Overview)
 		}
 	}
 
+	// Don't update the metadata with partial data
+	if ctx.Err() != nil {
+		report.Error = "Collection cancelled: " + ctx.Err().Error()
+		return ctx.Err()
+	}
+
+	// Without the interfaces the router entry would be emptied - keep the previous one
+	hasIf := hasDesc && hasTerse
+	if !hasIf {
+		logger.Log.Errorf("[%s] Interface information unavailable - previous metadata kept", r.Name)
+		report.Error = "Interface information unavailable - previous metadata kept"
+		return errors.New(report.Error)
+	}
+
 	// Display detail only if verbose set
 	if logger.Verbose {
 		logger.Log.Debug("")
@@ -311,8 +370,15 @@ func (r *RouterTask) Work() error {
 	err = r.Jsonify.UpdateMeta(rawData)
 	if err != nil {
 		logger.Log.Errorf("[%s] Unable to update the MetaData structure: %v", r.Name, err)
+		report.Error = "Unable to update the MetaData structure: " + err.Error()
 		return err
 	}
+	report.Entities["tagged"] = r.Jsonify.Count(r.Family, r.Name)
+	// a partial collection still updates the metadata but is not a success
+	report.Success = rpcsSucceeded(report)
+	if !report.Success {
+		report.Error = "Partial collection - see the RPC diagnostics"
+	}
 
 	logger.Log.Infof("[%s] End of collecting and updating Metadata", r.Name)
 	return nil
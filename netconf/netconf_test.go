@@ -0,0 +1,78 @@
+package netconf
+
+import (
+	"context"
+	"io"
+	"jtso/logger"
+	"jtso/output"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func replaySetup(t *testing.T) {
+	t.Helper()
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+	if err := SetMode(MODE_REPLAY, "testdata"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetMode(MODE_LIVE, "") })
+	output.MyMeta.Clear()
+	if err := output.MyMeta.LoadRules(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkReplay(t *testing.T) {
+	replaySetup(t)
+
+	task := &RouterTask{Name: "r1", Family: "mx", Timeout: 10, Jsonify: output.MyMeta, Reports: -1}
+	if err := task.Work(context.Background()); err != nil {
+		t.Fatalf("Work: %v", err)
+	}
+
+	meta := output.MyMeta.Meta["mx"]["r1"]
+	tests := []struct {
+		entry, key, want string
+	}{
+		{"et-0/0/1", "DESC", "TO_CORE_1"},
+		{"et-0/0/1", "LINKNAME", "et-0/0/1 - TO_CORE_1"},
+		{"et-0/0/1", "port_name", "0/0/1 - TO_CORE_1"},
+		{"et-0/0/1", "channel", "no"},
+		{"et-0/0/2", "DESC", "Unknown"},
+		{"et-0/0/2", "LAG", "ae0"},
+		{"et-0/0/1.0", "DESC", "CORELINK"},
+		{"FPC0", "HW_TYPE", "LMIC16-BASE"},
+		{"LEVEL1TAGS", "MODEL", "MX304"},
+		{"LEVEL1TAGS", "MPLS_V4_SID", "1"},
+		{"LEVEL1TAGS", "MPLS_V4_LABEL", "16001"},
+	}
+	for _, tt := range tests {
+		if got := meta[tt.entry][tt.key]; got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.entry, tt.key, got, tt.want)
+		}
+	}
+	if _, ok := meta["lo0"]; !ok {
+		t.Errorf("lo0 should be kept by the default rules")
+	}
+	if _, ok := meta["dsc"]; ok {
+		t.Errorf("dsc should be filtered out by the default rules")
+	}
+}
+
+func TestWorkReplayKeepsMetaWithoutInterfaces(t *testing.T) {
+	replaySetup(t)
+
+	previous := map[string]map[string]string{"et-0/0/1": {"DESC": "PREVIOUS"}}
+	output.MyMeta.Meta["mx"] = map[string]map[string]map[string]string{"r2": previous}
+
+	// the capture of r2 has no interface-terse reply
+	task := &RouterTask{Name: "r2", Family: "mx", Timeout: 10, Jsonify: output.MyMeta, Reports: -1}
+	if err := task.Work(context.Background()); err == nil {
+		t.Fatal("Work should fail without the interface-terse reply")
+	}
+	if got := output.MyMeta.Meta["mx"]["r2"]["et-0/0/1"]["DESC"]; got != "PREVIOUS" {
+		t.Errorf("previous metadata not kept: DESC = %q", got)
+	}
+}
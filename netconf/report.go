@@ -0,0 +1,60 @@
+package netconf
+
+import (
+	"errors"
+	"jtso/sqlite"
+	"strings"
+	"time"
+)
+
+// Issue one RPC and record its diagnostic in the run report.
+// Return the reply data and false if the RPC has failed.
+func (r *RouterTask) rpcCall(session Client, report *sqlite.RunReport, name string, d string) (string, bool) {
+	start := time.Now()
+	reply, err := session.RPC(d, r.Timeout)
+
+	diag := &sqlite.RpcReport{Rpc: name, DurationMs: time.Since(start).Milliseconds()}
+	report.Rpcs = append(report.Rpcs, diag)
+
+	switch {
+	case err != nil:
+		diag.Error = err.Error()
+	case strings.Contains(reply, "<rpc-error>"):
+		diag.Size = len(reply)
+		diag.Error = "rpc-error in reply"
+	default:
+		diag.Size = len(reply)
+		diag.Success = true
+		return reply, true
+	}
+	return "", false
+}
+
+// Error of the last RPC of the report
+func lastRpcError(report *sqlite.RunReport) error {
+	if len(report.Rpcs) == 0 || report.Rpcs[len(report.Rpcs)-1].Error == "" {
+		return nil
+	}
+	return errors.New(report.Rpcs[len(report.Rpcs)-1].Error)
+}
+
+// Flag a parse error on the last RPC of the report
+func parseFailed(report *sqlite.RunReport, err error) {
+	if len(report.Rpcs) == 0 {
+		return
+	}
+	report.Rpcs[len(report.Rpcs)-1].ParseError = err.Error()
+}
+
+// True if every RPC of the report has succeeded and its reply was parsed
+func rpcsSucceeded(report *sqlite.RunReport) bool {
+	if len(report.Rpcs) == 0 {
+		return false
+	}
+	for _, rpc := range report.Rpcs {
+		if !rpc.Success || rpc.ParseError != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type (
@@ -99,11 +100,53 @@ func Save(f string, profile RunningProfile) error {
 	if err != nil {
 		return fmt.Errorf("failed to write file %s: %w", filePath, err)
 	}
+	Refresh()
 
 	return nil
 }
 
+// Saved configurations - refreshed by the file watcher
+var (
+	configMu     sync.Mutex
+	configCache  []string
+	configLoaded bool
+)
+
+// Rescan the saved configurations. Invalid JSON files are reported and skipped.
+func Refresh() {
+	configs, err := scanConfigs()
+	if err != nil {
+		logger.Log.Errorf("Unable to refresh ondemand configurations: %v", err)
+		return
+	}
+	valid := make([]string, 0, len(configs))
+	for _, c := range configs {
+		data, err := os.ReadFile(filepath.Join(PATH_ONDEMAND, c+".json"))
+		if err != nil || !json.Valid(data) {
+			logger.Log.Warnf("Ondemand configuration %s is not a valid JSON file - skip it", c)
+			continue
+		}
+		valid = append(valid, c)
+	}
+	configMu.Lock()
+	configCache = valid
+	configLoaded = true
+	configMu.Unlock()
+	logger.Log.Debugf("%d ondemand configuration(s) available", len(valid))
+}
+
 func ListConfigs() ([]string, error) {
+	configMu.Lock()
+	if configLoaded {
+		configs := append([]string(nil), configCache...)
+		configMu.Unlock()
+		return configs, nil
+	}
+	configMu.Unlock()
+	return scanConfigs()
+}
+
+func scanConfigs() ([]string, error) {
 	var configs []string
 
 	// Read directory entries
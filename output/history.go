@@ -0,0 +1,190 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"jtso/config"
+	"jtso/influx"
+	"jtso/logger"
+	"jtso/sqlite"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kind of metadata changes
+const (
+	CHANGE_IF_ADDED    string = "interface-added"
+	CHANGE_IF_REMOVED  string = "interface-removed"
+	CHANGE_DESC        string = "description"
+	CHANGE_LAG         string = "lag"
+	CHANGE_OPTIC       string = "optic"
+	CHANGE_ISIS_SID    string = "isis-sid"
+	LEVEL1_KEY         string = "LEVEL1TAGS"
+	ATTRIBUTE_DESC     string = "DESC"
+	ATTRIBUTE_LAG      string = "LAG"
+	ATTRIBUTE_OPTIC    string = "OPTIC_DESC"
+	ATTRIBUTE_MPLS_PFX string = "MPLS_"
+)
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// An entry is an interface if it carries a description tag
+func isInterface(key string, attrs map[string]string) bool {
+	if key == LEVEL1_KEY {
+		return false
+	}
+	_, ok := attrs[ATTRIBUTE_DESC]
+	return ok
+}
+
+// Compute the changes between 2 metadata maps of a router
+func diffMeta(router string, prev, cur map[string]map[string]string, ts int64) []*sqlite.MetaChange {
+	changes := make([]*sqlite.MetaChange, 0)
+	add := func(kind, key, attr, old, new string) {
+		changes = append(changes, &sqlite.MetaChange{Router: router, Timestamp: ts, Kind: kind, Key: key, Attribute: attr, Old: old, New: new})
+	}
+
+	// Removed entries
+	for _, key := range sortedKeys(prev) {
+		if _, ok := cur[key]; ok {
+			continue
+		}
+		if isInterface(key, prev[key]) {
+			add(CHANGE_IF_REMOVED, key, "", prev[key][ATTRIBUTE_DESC], "")
+		}
+		if optic, ok := prev[key][ATTRIBUTE_OPTIC]; ok {
+			add(CHANGE_OPTIC, key, ATTRIBUTE_OPTIC, optic, "")
+		}
+	}
+
+	for _, key := range sortedKeys(cur) {
+		attrs := cur[key]
+		old, existed := prev[key]
+		if !existed {
+			old = map[string]string{}
+			if isInterface(key, attrs) {
+				add(CHANGE_IF_ADDED, key, "", "", attrs[ATTRIBUTE_DESC])
+			}
+		}
+
+		if key == LEVEL1_KEY {
+			// ISIS SIDs and labels
+			for _, attr := range sortedKeys(attrs) {
+				if strings.HasPrefix(attr, ATTRIBUTE_MPLS_PFX) && attrs[attr] != old[attr] {
+					add(CHANGE_ISIS_SID, key, attr, old[attr], attrs[attr])
+				}
+			}
+			for _, attr := range sortedKeys(old) {
+				if _, ok := attrs[attr]; !ok && strings.HasPrefix(attr, ATTRIBUTE_MPLS_PFX) {
+					add(CHANGE_ISIS_SID, key, attr, old[attr], "")
+				}
+			}
+			continue
+		}
+
+		// Description changes are only relevant for entries already known
+		if existed && isInterface(key, attrs) && isInterface(key, old) && attrs[ATTRIBUTE_DESC] != old[ATTRIBUTE_DESC] {
+			add(CHANGE_DESC, key, ATTRIBUTE_DESC, old[ATTRIBUTE_DESC], attrs[ATTRIBUTE_DESC])
+		}
+		if attrs[ATTRIBUTE_LAG] != old[ATTRIBUTE_LAG] {
+			add(CHANGE_LAG, key, ATTRIBUTE_LAG, old[ATTRIBUTE_LAG], attrs[ATTRIBUTE_LAG])
+		}
+		if attrs[ATTRIBUTE_OPTIC] != old[ATTRIBUTE_OPTIC] {
+			add(CHANGE_OPTIC, key, ATTRIBUTE_OPTIC, old[ATTRIBUTE_OPTIC], attrs[ATTRIBUTE_OPTIC])
+		}
+	}
+	return changes
+}
+
+// Human readable text of a change - used for the annotations
+func changeText(c *sqlite.MetaChange) string {
+	switch c.Kind {
+	case CHANGE_IF_ADDED:
+		return fmt.Sprintf("Interface %s added", c.Key)
+	case CHANGE_IF_REMOVED:
+		return fmt.Sprintf("Interface %s removed", c.Key)
+	default:
+		return fmt.Sprintf("%s %s: '%s' -> '%s'", c.Key, c.Attribute, c.Old, c.New)
+	}
+}
+
+// Compare the metadata of each router rebuilt since the last call against its
+// last stored snapshot, record the changes in the history and optionally
+// annotate InfluxDB.
+func (m *Metadata) TrackChanges(cfg *config.ConfigContainer) {
+	type rtrSnap struct {
+		family string
+		data   string
+	}
+
+	// Take a copy of the current state
+	m.Mu.Lock()
+	snaps := make(map[string]*rtrSnap)
+	for family, routers := range m.Meta {
+		for router, meta := range routers {
+			if !m.refreshed[router] {
+				continue
+			}
+			data, err := json.Marshal(meta)
+			if err != nil {
+				logger.Log.Errorf("Unable to serialize metadata of %s: %v", router, err)
+				continue
+			}
+			snaps[router] = &rtrSnap{family: family, data: string(data)}
+		}
+	}
+	m.refreshed = make(map[string]bool)
+	m.Mu.Unlock()
+
+	now := time.Now()
+	annotations := make([]influx.Annotation, 0)
+	for _, router := range sortedKeys(snaps) {
+		s := snaps[router]
+		prevData, found, err := sqlite.GetMetaSnapshot(router)
+		if err != nil {
+			continue
+		}
+		if found && prevData == s.data {
+			continue
+		}
+
+		changes := make([]*sqlite.MetaChange, 0)
+		if found {
+			prev := make(map[string]map[string]string)
+			cur := make(map[string]map[string]string)
+			if err := json.Unmarshal([]byte(prevData), &prev); err != nil {
+				logger.Log.Errorf("Unable to parse previous metadata snapshot of %s: %v", router, err)
+			} else if err := json.Unmarshal([]byte(s.data), &cur); err == nil {
+				changes = diffMeta(router, prev, cur, now.Unix())
+			}
+		} else {
+			logger.Log.Infof("[%s] First metadata snapshot recorded", router)
+		}
+
+		err = sqlite.SaveMetaSnapshot(router, s.family, s.data, changes, cfg.Enricher.HistoryDays)
+		if err != nil {
+			logger.Log.Errorf("[%s] Unable to save metadata history: %v", router, err)
+			continue
+		}
+		if len(changes) > 0 {
+			logger.Log.Infof("[%s] %d metadata change(s) detected", router, len(changes))
+		}
+		for _, c := range changes {
+			annotations = append(annotations, influx.Annotation{Device: router, Kind: c.Kind, Key: c.Key, Text: changeText(c), Time: now})
+		}
+	}
+
+	if cfg.Enricher.Annotations {
+		if err := influx.WriteAnnotations(annotations); err != nil {
+			logger.Log.Errorf("Unable to annotate metadata changes into influxdb: %v", err)
+		}
+	}
+}
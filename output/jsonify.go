@@ -17,6 +17,10 @@ type Metadata struct {
 	// Level 1 Router as a key
 	// Level 2 - Wellknown key LEVEL1TAGSS or any other L2 key (interface, MPC...)
 	Meta map[string]map[string]map[string]map[string]string
+	// Interface selection rules per family
+	Rules map[string]*IfaceRules
+	// Routers rebuilt since the last change tracking
+	refreshed map[string]bool
 }
 
 var MyMeta *Metadata
@@ -25,8 +29,9 @@ var MyMeta *Metadata
 func init() {
 	// init the metadata
 	MyMeta = &Metadata{
-		Mu:   new(sync.Mutex),
-		Meta: make(map[string]map[string]map[string]map[string]string),
+		Mu:        new(sync.Mutex),
+		Meta:      make(map[string]map[string]map[string]map[string]string),
+		refreshed: make(map[string]bool),
 	}
 }
 
@@ -34,6 +39,7 @@ func init() {
 func (m *Metadata) Clear() {
 	m.Mu.Lock()
 	m.Meta = make(map[string]map[string]map[string]map[string]string)
+	m.refreshed = make(map[string]bool)
 	m.Mu.Unlock()
 }
 
@@ -54,6 +60,13 @@ func (m *Metadata) ClearRtr(p string, r string) {
 	m.Mu.Unlock()
 }
 
+// Return the number of enriched entries of a router
+func (m *Metadata) Count(family string, router string) int {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	return len(m.Meta[family][router])
+}
+
 // Update the map for a given router
 func (m *Metadata) UpdateMeta(rd *xml.RawData) error {
 	m.Mu.Lock()
@@ -66,59 +79,60 @@ func (m *Metadata) UpdateMeta(rd *xml.RawData) error {
 	if !ok {
 		m.Meta[rd.Family] = make(map[string]map[string]map[string]string)
 	}
-	_, ok = m.Meta[rd.Family][rd.RtrName]
-	if !ok {
-		m.Meta[rd.Family][rd.RtrName] = map[string]map[string]string{}
-	}
+	// Rebuild the router entry from scratch so that removed interfaces disappear -
+	// the caller only updates a router whose interfaces were collected
+	m.Meta[rd.Family][rd.RtrName] = map[string]map[string]string{}
+	m.refreshed[rd.RtrName] = true
+
+	// Interface selection rules of the router family
+	rules := m.rulesFor(rd.Family)
 
 	for _, phy := range rd.IfList.Physicals {
 		phy_name := strings.Trim(phy.Name, "\n")
-		// Keep only WAN ports
-		if strings.Contains(phy_name, "et-") || strings.Contains(phy_name, "xe-") || strings.Contains(phy_name, "ge-") || strings.Contains(phy_name, "ae") || strings.Contains(phy_name, "lt-") || strings.Contains(phy_name, "ps-") || strings.Contains(phy_name, "fti-") || strings.Contains(phy_name, "gr-") {
-
-			_, ok := m.Meta[rd.Family][rd.RtrName][phy_name]
-			if !ok {
-				m.Meta[rd.Family][rd.RtrName][phy_name] = make(map[string]string)
-			}
-			//Default description TAG
-			m.Meta[rd.Family][rd.RtrName][phy_name]["DESC"] = "Unknown"
-			if strings.Contains(phy_name, "et-") || strings.Contains(phy_name, "xe-") || strings.Contains(phy_name, "ge-") {
-				m.Meta[rd.Family][rd.RtrName][phy_name]["port_name"] = phy_name[3:] + " - Unknown"
-				if strings.Contains(phy_name, ":") {
-					m.Meta[rd.Family][rd.RtrName][phy_name]["channel"] = "yes"
-				} else {
-					m.Meta[rd.Family][rd.RtrName][phy_name]["channel"] = "no"
-				}
-			}
-
-			m.Meta[rd.Family][rd.RtrName][phy_name]["LINKNAME"] = phy_name + " - " + "Unknown"
+		// Keep only interfaces selected by the family rules
+		if !rules.Keep(phy_name) {
+			continue
+		}
 
-			// Add also the parent LAG name if physical interface is a child link.
-			val, ok := rd.LacpDigest.LacpMap[phy_name]
-			if ok {
-				m.Meta[rd.Family][rd.RtrName][phy_name]["LAG"] = val
+		_, ok := m.Meta[rd.Family][rd.RtrName][phy_name]
+		if !ok {
+			m.Meta[rd.Family][rd.RtrName][phy_name] = make(map[string]string)
+		}
+		//Default description TAG
+		m.Meta[rd.Family][rd.RtrName][phy_name]["DESC"] = "Unknown"
+		port, isPort := rules.PortOf(phy_name)
+		if isPort {
+			m.Meta[rd.Family][rd.RtrName][phy_name]["port_name"] = port + " - Unknown"
+			if strings.Contains(port, ":") {
+				m.Meta[rd.Family][rd.RtrName][phy_name]["channel"] = "yes"
+			} else {
+				m.Meta[rd.Family][rd.RtrName][phy_name]["channel"] = "no"
 			}
+		}
 
-			// check if PHY port has a description
-			// ADD physical description if present
-			for _, phy2 := range rd.IfDesc.Physicals {
-				phy2_name := strings.Trim(phy2.Name, "\n")
-				phy2_desc := strings.Trim(phy2.Desc, "\n")
-
-				if phy2_name == phy_name && phy2_desc != "" {
-
-					m.Meta[rd.Family][rd.RtrName][phy_name]["LINKNAME"] = phy2_name + " - " + strings.ToUpper(strings.Replace(strings.Replace(phy2_desc, " ", "", -1), "-", "_", -1))
-					m.Meta[rd.Family][rd.RtrName][phy_name]["DESC"] = strings.ToUpper(strings.Replace(strings.Replace(phy2_desc, " ", "", -1), "-", "_", -1))
-
-					//add to the map
-					if len(phy_name) > 3 {
+		m.Meta[rd.Family][rd.RtrName][phy_name]["LINKNAME"] = phy_name + " - " + "Unknown"
 
-						if strings.Contains(phy_name, "et-") || strings.Contains(phy_name, "xe-") || strings.Contains(phy_name, "ge-") {
-							m.Meta[rd.Family][rd.RtrName][phy_name]["port_name"] = phy_name[3:] + " - " + strings.ToUpper(strings.Replace(strings.Replace(phy2_desc, " ", "", -1), "-", "_", -1))
-							mapDesc[phy_name[3:]] = strings.ToUpper(strings.Replace(strings.Replace(phy2_desc, " ", "", -1), "-", "_", -1))
-						}
-					}
+		// Add also the parent LAG name if physical interface is a child link.
+		val, ok := rd.LacpDigest.LacpMap[phy_name]
+		if ok {
+			m.Meta[rd.Family][rd.RtrName][phy_name]["LAG"] = val
+		}
 
+		// check if PHY port has a description
+		// ADD physical description if present
+		for _, phy2 := range rd.IfDesc.Physicals {
+			phy2_name := strings.Trim(phy2.Name, "\n")
+			phy2_desc := strings.Trim(phy2.Desc, "\n")
+
+			if phy2_name == phy_name && phy2_desc != "" {
+				desc := strings.ToUpper(strings.Replace(strings.Replace(phy2_desc, " ", "", -1), "-", "_", -1))
+				m.Meta[rd.Family][rd.RtrName][phy_name]["LINKNAME"] = phy2_name + " - " + desc
+				m.Meta[rd.Family][rd.RtrName][phy_name]["DESC"] = desc
+
+				//add to the map
+				if isPort {
+					m.Meta[rd.Family][rd.RtrName][phy_name]["port_name"] = port + " - " + desc
+					mapDesc[port] = desc
 				}
 			}
 		}
@@ -235,70 +249,7 @@ func (m *Metadata) UpdateMeta(rd *xml.RawData) error {
 								sssmSlot := strings.Trim(strings.Replace(sssm.Name, " ", "", 1), "\n")
 								if strings.Contains(strings.ToLower(sssmSlot), "xcvr") {
 									portSlot := strings.Replace(strings.Replace(sssmSlot, "Xcvr", "", 1), "XCVR", "", 1)
-									opticDesc := sssm.Desc
-									key := fpcSlot + "/" + picSlot + "/" + portSlot
-									_, ok := m.Meta[rd.Family][rd.RtrName][key]
-									if !ok {
-										m.Meta[rd.Family][rd.RtrName][key] = make(map[string]string)
-									}
-									m.Meta[rd.Family][rd.RtrName][key]["OPTIC_DESC"] = opticDesc
-									// Try to find channelized port
-									for _, phy := range rd.IfList.Physicals {
-										phy_name := strings.Trim(phy.Name, "\n")
-										// Keep only WAN ports
-										if strings.Contains(phy_name, "et-") || strings.Contains(phy_name, "xe-") || strings.Contains(phy_name, "ge-") {
-											suffix := phy_name[3:]
-											if suffix == key || strings.HasPrefix(suffix, key+":") {
-												if strings.Contains(phy_name, ":") {
-													// Extract the channel after the :
-													parts := strings.Split(phy_name, ":")
-													if len(parts) > 1 {
-														// Update CAGE info
-														m.Meta[rd.Family][rd.RtrName][key]["HAS_CHANNEL"] = "yes"
-														m.Meta[rd.Family][rd.RtrName][key]["OPTIC_DESC"] = opticDesc
-
-														portDesc := "Unknown"
-														if len(parts[0]) > 3 {
-															cageDesc, ok := mapDesc[parts[0][3:]]
-															if ok {
-																portDesc = cageDesc
-															}
-														}
-														m.Meta[rd.Family][rd.RtrName][key]["LINKNAME"] = parts[0] + " - " + portDesc
-
-														// Update also the channelized port with optic and cage info
-														channel := parts[1]
-														channelizedKey := key + ":" + channel
-														_, ok = m.Meta[rd.Family][rd.RtrName][channelizedKey]
-														if !ok {
-															m.Meta[rd.Family][rd.RtrName][channelizedKey] = make(map[string]string)
-														}
-														m.Meta[rd.Family][rd.RtrName][channelizedKey]["OPTIC_DESC"] = opticDesc
-														portDesc = "Unknown"
-														if len(phy_name) > 3 {
-															cageDesc, ok := mapDesc[phy_name[3:]]
-															if ok {
-																portDesc = cageDesc
-															}
-														}
-														m.Meta[rd.Family][rd.RtrName][channelizedKey]["LINKNAME"] = phy_name + " - " + portDesc
-													}
-												} else {
-													// Update the cage info for non channelized port
-													portDesc := "Unknown"
-													if len(phy_name) > 3 {
-														cageDesc, ok := mapDesc[phy_name[3:]]
-														if ok {
-															portDesc = cageDesc
-														}
-													}
-													m.Meta[rd.Family][rd.RtrName][key]["LINKNAME"] = phy_name + " - " + portDesc
-													m.Meta[rd.Family][rd.RtrName][key]["HAS_CHANNEL"] = "no"
-													m.Meta[rd.Family][rd.RtrName][key]["OPTIC_DESC"] = opticDesc
-												}
-											}
-										}
-									}
+									m.updateCage(rd, rules, mapDesc, fpcSlot+"/"+picSlot+"/"+portSlot, sssm.Desc)
 								}
 
 							}
@@ -312,69 +263,7 @@ func (m *Metadata) UpdateMeta(rd *xml.RawData) error {
 						ssmSlot := strings.Trim(strings.Replace(ssm.Name, " ", "", 1), "\n")
 						if strings.Contains(strings.ToLower(ssmSlot), "xcvr") {
 							portSlot := strings.Replace(strings.Replace(ssmSlot, "Xcvr", "", 1), "XCVR", "", 1)
-							opticDesc := ssm.Desc
-							key := fpcSlot + "/" + picSlot + "/" + portSlot
-							_, ok := m.Meta[rd.Family][rd.RtrName][key]
-							if !ok {
-								m.Meta[rd.Family][rd.RtrName][key] = make(map[string]string)
-							}
-							m.Meta[rd.Family][rd.RtrName][key]["OPTIC_DESC"] = opticDesc
-							// Try to find channelized port
-							for _, phy := range rd.IfList.Physicals {
-								phy_name := strings.Trim(phy.Name, "\n")
-								// Keep only WAN ports
-								if strings.Contains(phy_name, "et-") || strings.Contains(phy_name, "xe-") || strings.Contains(phy_name, "ge-") {
-									suffix := phy_name[3:]
-									if suffix == key || strings.HasPrefix(suffix, key+":") {
-										if strings.Contains(phy_name, ":") {
-											// Extract the channel after the :
-											parts := strings.Split(phy_name, ":")
-											if len(parts) > 1 {
-												// Update CAGE info
-												m.Meta[rd.Family][rd.RtrName][key]["HAS_CHANNEL"] = "yes"
-												m.Meta[rd.Family][rd.RtrName][key]["OPTIC_DESC"] = opticDesc
-												portDesc := "Unknown"
-												if len(parts[0]) > 3 {
-													cageDesc, ok := mapDesc[parts[0][3:]]
-													if ok {
-														portDesc = cageDesc
-													}
-												}
-												m.Meta[rd.Family][rd.RtrName][key]["LINKNAME"] = parts[0] + " - " + portDesc
-
-												// Update also the channelized port with optic and cage info
-												channel := parts[1]
-												channelizedKey := key + ":" + channel
-												_, ok = m.Meta[rd.Family][rd.RtrName][channelizedKey]
-												if !ok {
-													m.Meta[rd.Family][rd.RtrName][channelizedKey] = make(map[string]string)
-												}
-												m.Meta[rd.Family][rd.RtrName][channelizedKey]["OPTIC_DESC"] = opticDesc
-												portDesc = "Unknown"
-												if len(phy_name) > 3 {
-													cageDesc, ok := mapDesc[phy_name[3:]]
-													if ok {
-														portDesc = cageDesc
-													}
-												}
-												m.Meta[rd.Family][rd.RtrName][channelizedKey]["LINKNAME"] = phy_name + " - " + portDesc
-											}
-										} else {
-											// Update the cage info for non channelized port
-											portDesc := "Unknown"
-											if len(phy_name) > 3 {
-												cageDesc, ok := mapDesc[phy_name[3:]]
-												if ok {
-													portDesc = cageDesc
-												}
-											}
-											m.Meta[rd.Family][rd.RtrName][key]["LINKNAME"] = phy_name + " - " + portDesc
-											m.Meta[rd.Family][rd.RtrName][key]["HAS_CHANNEL"] = "no"
-											m.Meta[rd.Family][rd.RtrName][key]["OPTIC_DESC"] = opticDesc
-										}
-									}
-								}
-							}
+							m.updateCage(rd, rules, mapDesc, fpcSlot+"/"+picSlot+"/"+portSlot, ssm.Desc)
 						}
 					}
 				}
@@ -386,6 +275,60 @@ func (m *Metadata) UpdateMeta(rd *xml.RawData) error {
 	return nil
 }
 
+// Update the optic cage entry "key" (FPC/PIC/PORT) and its channelized ports.
+// Caller must hold m.Mu.
+func (m *Metadata) updateCage(rd *xml.RawData, rules *IfaceRules, mapDesc map[string]string, key string, opticDesc string) {
+	_, ok := m.Meta[rd.Family][rd.RtrName][key]
+	if !ok {
+		m.Meta[rd.Family][rd.RtrName][key] = make(map[string]string)
+	}
+	m.Meta[rd.Family][rd.RtrName][key]["OPTIC_DESC"] = opticDesc
+
+	// Try to find channelized port
+	for _, phy := range rd.IfList.Physicals {
+		phy_name := strings.Trim(phy.Name, "\n")
+		// Keep only physical ports
+		port, isPort := rules.PortOf(phy_name)
+		if !isPort || (port != key && !strings.HasPrefix(port, key+":")) {
+			continue
+		}
+		if strings.Contains(port, ":") {
+			// Extract the channel after the :
+			parts := strings.SplitN(phy_name, ":", 2)
+			portParts := strings.SplitN(port, ":", 2)
+
+			// Update CAGE info
+			m.Meta[rd.Family][rd.RtrName][key]["HAS_CHANNEL"] = "yes"
+			portDesc := "Unknown"
+			if cageDesc, ok := mapDesc[portParts[0]]; ok {
+				portDesc = cageDesc
+			}
+			m.Meta[rd.Family][rd.RtrName][key]["LINKNAME"] = parts[0] + " - " + portDesc
+
+			// Update also the channelized port with optic and cage info
+			channelizedKey := key + ":" + portParts[1]
+			_, ok = m.Meta[rd.Family][rd.RtrName][channelizedKey]
+			if !ok {
+				m.Meta[rd.Family][rd.RtrName][channelizedKey] = make(map[string]string)
+			}
+			m.Meta[rd.Family][rd.RtrName][channelizedKey]["OPTIC_DESC"] = opticDesc
+			portDesc = "Unknown"
+			if cageDesc, ok := mapDesc[port]; ok {
+				portDesc = cageDesc
+			}
+			m.Meta[rd.Family][rd.RtrName][channelizedKey]["LINKNAME"] = phy_name + " - " + portDesc
+		} else {
+			// Update the cage info for non channelized port
+			portDesc := "Unknown"
+			if cageDesc, ok := mapDesc[port]; ok {
+				portDesc = cageDesc
+			}
+			m.Meta[rd.Family][rd.RtrName][key]["LINKNAME"] = phy_name + " - " + portDesc
+			m.Meta[rd.Family][rd.RtrName][key]["HAS_CHANNEL"] = "no"
+		}
+	}
+}
+
 // Create the Json file
 func (m *Metadata) MarshallMeta(f string) error {
 	m.Mu.Lock()
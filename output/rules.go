@@ -0,0 +1,155 @@
+package output
+
+import (
+	"fmt"
+	"jtso/config"
+	"jtso/logger"
+	"regexp"
+	"strings"
+)
+
+// Compiled interface selection rules for one family
+type IfaceRules struct {
+	Include []*regexp.Regexp
+	Exclude []*regexp.Regexp
+	Port    []*regexp.Regexp
+}
+
+// Default rules used when config.yml does not define any rule for a family
+var defaultIfaceRules = config.InterfaceRules{
+	Include: []string{
+		`^(et|xe|ge|mge)-`,
+		`^ae\d+$`,
+		`^(lt|ps|fti|gr)-`,
+		`^(irb|lo0|vtep)$`,
+		`^(em|fxp)\d+$`,
+	},
+	Exclude: []string{},
+	Port: []string{
+		`^(?:et|xe|ge|mge)-(\d+/\d+/\d+(?::\d+)?)$`,
+	},
+}
+
+func compileList(l []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(l))
+	for _, r := range l {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %s: %v", r, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+func compileRules(r *config.InterfaceRules) (*IfaceRules, error) {
+	var err error
+	rules := new(IfaceRules)
+	if rules.Include, err = compileList(r.Include); err != nil {
+		return nil, err
+	}
+	if rules.Exclude, err = compileList(r.Exclude); err != nil {
+		return nil, err
+	}
+	if rules.Port, err = compileList(r.Port); err != nil {
+		return nil, err
+	}
+	for _, re := range rules.Port {
+		if re.NumSubexp() < 1 {
+			return nil, fmt.Errorf("port regex %s must have one capture group", re.String())
+		}
+	}
+	return rules, nil
+}
+
+// Load and compile the per family interface rules. Families without rules
+// inherit the "default" entry, or the built-in rules if there is none. A
+// family with invalid rules is logged and falls back the same way.
+func (m *Metadata) LoadRules(cfgRules map[string]*config.InterfaceRules) error {
+	rules := make(map[string]*IfaceRules)
+
+	def, err := compileRules(&defaultIfaceRules)
+	if err != nil {
+		return err
+	}
+	rules["default"] = def
+
+	for family, r := range cfgRules {
+		if r == nil {
+			continue
+		}
+		// Inherit missing lists from the built-in rules
+		merged := *r
+		if merged.Include == nil {
+			merged.Include = defaultIfaceRules.Include
+		}
+		if merged.Port == nil {
+			merged.Port = defaultIfaceRules.Port
+		}
+		compiled, err := compileRules(&merged)
+		if err != nil {
+			logger.Log.Errorf("Invalid interface rules for family %s - fallback to the default rules: %v", family, err)
+			continue
+		}
+		rules[strings.ToLower(family)] = compiled
+		logger.Log.Infof("Interface rules loaded for family %s", family)
+	}
+
+	m.Mu.Lock()
+	m.Rules = rules
+	m.Mu.Unlock()
+	return nil
+}
+
+// Return the rules of a family. Caller must hold m.Mu.
+func (m *Metadata) rulesFor(family string) *IfaceRules {
+	if r, ok := m.Rules[family]; ok {
+		return r
+	}
+	if r, ok := m.Rules["default"]; ok {
+		return r
+	}
+	// LoadRules has not been called yet - fallback to built-in rules
+	r, _ := compileRules(&defaultIfaceRules)
+	return r
+}
+
+// Keep reports whether an interface must be enriched
+func (r *IfaceRules) Keep(name string) bool {
+	for _, re := range r.Exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	for _, re := range r.Include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// PortOf returns the FPC/PIC/PORT[:CHANNEL] part of a physical port name.
+// The boolean is false if the interface is not a physical port.
+func (r *IfaceRules) PortOf(name string) (string, bool) {
+	for _, re := range r.Port {
+		if sm := re.FindStringSubmatch(name); sm != nil && sm[1] != "" {
+			return sm[1], true
+		}
+	}
+	return "", false
+}
+
+// Skipped returns the interfaces filtered out by the rules of a family
+func (m *Metadata) Skipped(family string, names []string) []string {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	rules := m.rulesFor(family)
+	skipped := make([]string, 0)
+	for _, n := range names {
+		if !rules.Keep(n) {
+			skipped = append(skipped, n)
+		}
+	}
+	return skipped
+}
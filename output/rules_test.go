@@ -0,0 +1,63 @@
+package output
+
+import (
+	"io"
+	"jtso/config"
+	"jtso/logger"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDefaultRulesKeep(t *testing.T) {
+	rules, err := compileRules(&defaultIfaceRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		keep bool
+	}{
+		{"et-0/0/0", true},
+		{"mge-1/0/3:2", true},
+		{"ae12", true},
+		{"aenet", false},
+		{"ae0.0", false},
+		{"irb", true},
+		{"lo0", true},
+		{"em0", true},
+		{"fxp0", true},
+		{"vtep", true},
+		{"lt-0/0/0", true},
+		{"dsc", false},
+		{"jsrv", false},
+	}
+	for _, tt := range tests {
+		if got := rules.Keep(tt.name); got != tt.keep {
+			t.Errorf("Keep(%s) = %t, want %t", tt.name, got, tt.keep)
+		}
+	}
+}
+
+func TestLoadRulesBadFamily(t *testing.T) {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+
+	m := &Metadata{Mu: new(sync.Mutex)}
+	err := m.LoadRules(map[string]*config.InterfaceRules{
+		"mx":  {Include: []string{`^et-`}},
+		"ptx": {Include: []string{`^(et-`}},
+	})
+	if err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	if m.rulesFor("mx").Keep("irb") {
+		t.Errorf("mx should use its own rules")
+	}
+	if !m.rulesFor("ptx").Keep("irb") {
+		t.Errorf("ptx should fall back to the default rules")
+	}
+}
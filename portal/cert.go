@@ -0,0 +1,42 @@
+package portal
+
+import (
+	"crypto/tls"
+	"jtso/logger"
+	"sync"
+)
+
+// Server certificate of the portal - reloaded when the files change
+var (
+	certMu   sync.RWMutex
+	portCert *tls.Certificate
+)
+
+func loadCertificate() error {
+	cert, err := tls.LoadX509KeyPair(PATH_CERT+collectCfg.cfg.Portal.ServerCrt, PATH_CERT+collectCfg.cfg.Portal.ServerKey)
+	if err != nil {
+		return err
+	}
+	certMu.Lock()
+	portCert = &cert
+	certMu.Unlock()
+	return nil
+}
+
+func getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certMu.RLock()
+	defer certMu.RUnlock()
+	return portCert, nil
+}
+
+// Reload the HTTPS certificate of the portal - the current one is kept on error
+func ReloadCertificate() {
+	if collectCfg == nil || !collectCfg.cfg.Portal.Https {
+		return
+	}
+	if err := loadCertificate(); err != nil {
+		logger.Log.Errorf("Unable to reload the portal certificate - keep the current one: %v", err)
+		return
+	}
+	logger.Log.Info("Portal certificate has been reloaded")
+}
@@ -3,6 +3,7 @@ package portal
 import (
 	"jtso/gnmicollect"
 	"jtso/ondemand"
+	"jtso/sqlite"
 )
 
 type (
@@ -51,6 +52,25 @@ type (
 		Shortname string `json:"shortname"`
 	}
 
+	MetaHistory struct {
+		Shortname string `json:"shortname"`
+		Limit     int    `json:"limit"`
+	}
+
+	ProfilePin struct {
+		Profile string `json:"profile"`
+		Hash    string `json:"hash"`
+	}
+
+	ParamMgt struct {
+		Action  string `json:"action"`
+		Profile string `json:"profile"`
+		Param   string `json:"param"`
+		Scope   string `json:"scope"`
+		Target  string `json:"target"`
+		Value   string `json:"value"`
+	}
+
 	Reply struct {
 		Status string `json:"status"`
 		Msg    string `json:"msg"`
@@ -76,6 +96,9 @@ type (
 		Tele   string `json:"tele"`
 		Graf   string `json:"graf"`
 		Kapa   string `json:"kapa"`
+		Signer string `json:"signer"`
+		Hash   string `json:"hash"`
+		Compo  string `json:"compo"`
 	}
 
 	ReplyOnDemandProfile struct {
@@ -94,24 +117,48 @@ type (
 	}
 
 	Setting struct {
-		NetconfUser       string `json:"netuser"`
-		NetconfPwd        string `json:"netpwd"`
-		GnmiUser          string `json:"gnmiuser"`
-		GnmiPwd           string `json:"gnmipwd"`
-		UseTls            string `json:"usetls"`
-		SkipVerify        string `json:"skipverify"`
-		ClientTls         string `json:"clienttls"`
-		MetricBatchSize   string `json:"metricbatchsize"`
-		MetricBufferLimit string `json:"metricbufferlimit"`
-		FlushInterval     string `json:"flushinterval"`
-		FlushJitter       string `json:"flushjitter"`
-		KafkaEnabled      int    `json:"kafkaenabled"`
-		KafkaBrokers      string `json:"kafkabrokers"`
-		KafkaTopic        string `json:"kafkatopic"`
-		KafkaVersion      string `json:"kafkaversion"`
-		KafkaFormat       string `json:"kafkaformat"`
-		KafkaCompression  int    `json:"kafkacompression"`
-		KafkaMessageSize  int    `json:"kafkamessagesize"`
+		NetconfUser       string               `json:"netuser"`
+		NetconfPwd        string               `json:"netpwd"`
+		GnmiUser          string               `json:"gnmiuser"`
+		GnmiPwd           string               `json:"gnmipwd"`
+		UseTls            string               `json:"usetls"`
+		SkipVerify        string               `json:"skipverify"`
+		ClientTls         string               `json:"clienttls"`
+		MetricBatchSize   string               `json:"metricbatchsize"`
+		MetricBufferLimit string               `json:"metricbufferlimit"`
+		FlushInterval     string               `json:"flushinterval"`
+		FlushJitter       string               `json:"flushjitter"`
+		KafkaEnabled      int                  `json:"kafkaenabled"`
+		KafkaBrokers      string               `json:"kafkabrokers"`
+		KafkaTopic        string               `json:"kafkatopic"`
+		KafkaVersion      string               `json:"kafkaversion"`
+		KafkaFormat       string               `json:"kafkaformat"`
+		KafkaCompression  int                  `json:"kafkacompression"`
+		KafkaMessageSize  int                  `json:"kafkamessagesize"`
+		KafkaSasl         string               `json:"kafkasasl"`
+		KafkaSaslUser     string               `json:"kafkasasluser"`
+		KafkaSaslPwd      string               `json:"kafkasaslpwd"`
+		KafkaTls          string               `json:"kafkatls"`
+		KafkaCA           string               `json:"kafkaca"`
+		KafkaSkipVerify   string               `json:"kafkaskipverify"`
+		KafkaRouting      string               `json:"kafkarouting"`
+		KafkaTopicTag     string               `json:"kafkatopictag"`
+		KafkaRoutes       string               `json:"kafkaroutes"`
+		KafkaExcluded     string               `json:"kafkaexcluded"`
+		Routes            []sqlite.OutputRoute `json:"routes"`
+		PromEnabled       int                  `json:"promenabled"`
+		PromMode          string               `json:"prommode"`
+		PromPort          int                  `json:"promport"`
+		PromEndpoint      string               `json:"promendpoint"`
+		InfluxVersion     string               `json:"influxversion"`
+		InfluxUrl         string               `json:"influxurl"`
+		InfluxDatabase    string               `json:"influxdatabase"`
+		InfluxOrg         string               `json:"influxorg"`
+		InfluxUser        string               `json:"influxuser"`
+		InfluxPwd         string               `json:"influxpwd"`
+		InfluxToken       string               `json:"influxtoken"`
+		InfluxCA          string               `json:"influxca"`
+		InfluxSkipVerify  string               `json:"influxskipverify"`
 	}
 
 	InfluxMgt struct {
@@ -2,6 +2,8 @@ package portal
 
 import (
 	"bufio"
+	"crypto/tls"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,6 +22,7 @@ import (
 	"jtso/worker"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
@@ -120,6 +123,8 @@ func New(cfg *config.ConfigContainer) *WebApp {
 	wapp.GET("/stream", routeStream)
 	wapp.GET("/containerstats", routeContainerStats)
 	wapp.GET("/containerlogs", routeContainerLogs)
+	wapp.GET("/workerstats", routeWorkerStats)
+	wapp.GET("/compatibility", routeCompatibility)
 
 	//  POST API routes
 	wapp.POST("/addrouter", routeAddRouter)
@@ -132,12 +137,19 @@ func New(cfg *config.ConfigContainer) *WebApp {
 	wapp.POST("/influxmgt", routeInfluxMgt)
 	wapp.POST("/searchxpath", routeSearchPath)
 	wapp.POST("/updatedebug", routeUpdateDebug)
+	wapp.POST("/updateagent", routeUpdateAgent)
 	wapp.POST("/uploadrtrcsv", routeUploadRtrCsv)
 	wapp.POST("/uploadprofilecsv", routeUploadProfileCsv)
+	wapp.POST("/uploadprofile", routeUploadProfile)
+	wapp.POST("/profileversions", routeProfileVersions)
+	wapp.POST("/profilepin", routeProfilePin)
+	wapp.POST("/profileparams", routeProfileParams)
 	wapp.POST("/getrawconfig", routeGetRawConfig)
 	wapp.POST("/gettree", routeGetTreeDoc)
 	wapp.POST("/intervalmgmt", routeIntervalMgt)
 	wapp.POST("/ondemandmgt", routeOnDemandMgt)
+	wapp.POST("/metahistory", routeMetaHistory)
+	wapp.POST("/runreports", routeRunReports)
 
 	collectCfg = new(collectInfo)
 	collectCfg.cfg = cfg
@@ -152,7 +164,16 @@ func New(cfg *config.ConfigContainer) *WebApp {
 
 func (w *WebApp) Run() {
 	if collectCfg.cfg.Portal.Https {
-		if err := w.app.StartTLS(w.listen, PATH_CERT+collectCfg.cfg.Portal.ServerCrt, PATH_CERT+collectCfg.cfg.Portal.ServerKey); err != http.ErrServerClosed {
+		if err := loadCertificate(); err != nil {
+			logger.Log.Errorf("Unable to load the portal certificate: %v", err)
+			panic(err)
+		}
+		// the certificate is served through a callback to allow its reload
+		s := &http.Server{
+			Addr:      w.listen,
+			TLSConfig: &tls.Config{GetCertificate: getCertificate, NextProtos: []string{"h2"}},
+		}
+		if err := w.app.StartServer(s); err != http.ErrServerClosed {
 			logger.Log.Errorf("Unable to start HTTPS server: %v", err)
 			panic(err)
 		}
@@ -258,149 +279,20 @@ func findFamily(m string) string {
 	return f
 }
 
-func checkRouterSupport(filenames []association.Config, routerVersion string) bool {
-	result := false
-	for _, c := range filenames {
-		// Save all config if present as a fallback solution if specific version not found
-		if c.Version == "all" {
-			return true
-		} else {
-			result = result || association.CheckVersion(c.Version, routerVersion)
-		}
-	}
-	return result
-}
-
 func checkCompatibility(r *AddProfile, fam string, version string) (bool, string) {
 	// Check if a profile can be attached to a router
 	// Now check for each profile there is a given Telegraf config
 	valid := false
 	errString := ""
+	rtr := &sqlite.RtrEntry{Family: fam, Version: version}
+	association.ProfileLock.Lock()
+	defer association.ProfileLock.Unlock()
 	for _, i := range r.Profiles {
-		allTele := association.ActiveProfiles[i].Definition.TelCfg
-		switch fam {
-		case "mx":
-			if len(allTele.MxCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the MX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.MxCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this MX version.</br>"
-				}
-			}
-		case "ptx":
-			if len(allTele.PtxCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the PTX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.PtxCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this PTX version.</br>"
-				}
-			}
-		case "acx":
-			if len(allTele.AcxCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the ACX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.AcxCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this ACX version.</br>"
-				}
-			}
-		case "ex":
-			if len(allTele.ExCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the EX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.ExCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this EX version.</br>"
-				}
-			}
-		case "qfx":
-			if len(allTele.QfxCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the QFX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.QfxCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this QFX version.</br>"
-				}
-			}
-		case "srx":
-			if len(allTele.SrxCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the SRX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.SrxCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this SRX version.</br>"
-				}
-			}
-		case "crpd":
-			if len(allTele.CrpdCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the CRPD platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.CrpdCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this CRPD version.</br>"
-				}
-			}
-		case "cptx":
-			if len(allTele.CptxCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the CPTX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.CptxCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this CPTX version.</br>"
-				}
-			}
-		case "vmx":
-			if len(allTele.VmxCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the VMX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.VmxCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this VMX version.</br>"
-				}
-			}
-		case "vsrx":
-			if len(allTele.VsrxCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the VSRX platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.VsrxCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this VSRX version.</br>"
-				}
-			}
-		case "vjunos":
-			if len(allTele.VjunosCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the VJunos Router platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.VjunosCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this VJunos Router version.</br>"
-				}
-			}
-		case "vevo":
-			if len(allTele.VevoCfg) == 0 {
-				errString += "There is no Telegraf config for profile " + i + " for the VJunos Evolved platform.</br>"
-			} else {
-				if checkRouterSupport(allTele.VevoCfg, version) {
-					valid = true
-				} else {
-					errString += "There is no Telegraf config for profile " + i + " for this VJunos Evolved version.</br>"
-				}
-			}
-		default:
-			errString += "There is no Telegraf config for profile " + i + " for the unknown platform.</br>"
+		e := association.Compatibility(rtr, i)
+		if e.Compatible {
+			valid = true
+		} else {
+			errString += "Profile " + i + ": " + e.Reason + ".</br>"
 		}
 	}
 	return valid, errString
@@ -684,9 +576,41 @@ func routeSettings(c echo.Context) error {
 		"KafkaTopic": sqlite.ActiveKafkaConfig.Topic, "KafkaVersion": sqlite.ActiveKafkaConfig.Version,
 		"KafkaFormat": sqlite.ActiveKafkaConfig.Format, "KafkaCompression": reverseDictKafkaCodec[sqlite.ActiveKafkaConfig.Compression],
 		"KafkaMessageSize": sqlite.ActiveKafkaConfig.MessageSize,
+		"KafkaSasl":        sqlite.ActiveKafkaOptions.SaslMechanism, "KafkaSaslUser": sqlite.ActiveKafkaOptions.SaslUser,
+		"KafkaSaslPwd": sqlite.ActiveKafkaOptions.SaslPwd, "KafkaTls": sqlite.ActiveKafkaOptions.Tls,
+		"KafkaCA": sqlite.ActiveKafkaOptions.CA, "KafkaSkipVerify": sqlite.ActiveKafkaOptions.SkipVerify,
+		"KafkaRouting": sqlite.ActiveKafkaOptions.Routing, "KafkaTopicTag": sqlite.ActiveKafkaOptions.TopicTag,
+		"KafkaRoutes": sqlite.ActiveKafkaOptions.Routes, "KafkaExcluded": sqlite.ActiveKafkaOptions.ExcludedProfiles,
+		"PromEnable": sqlite.ActivePrometheusConfig.Enabled, "PromMode": sqlite.ActivePrometheusConfig.Mode,
+		"PromPort": sqlite.ActivePrometheusConfig.Port, "PromEndpoint": sqlite.ActivePrometheusConfig.Endpoint,
+		"InfluxVersion": sqlite.ActiveInfluxConfig.Version, "InfluxUrl": sqlite.ActiveInfluxConfig.Url,
+		"InfluxDatabase": sqlite.ActiveInfluxConfig.Database, "InfluxOrg": sqlite.ActiveInfluxConfig.Org,
+		"InfluxUser": sqlite.ActiveInfluxConfig.Username, "InfluxPwd": sqlite.ActiveInfluxConfig.Password,
+		"InfluxToken": sqlite.ActiveInfluxConfig.Token, "InfluxCA": sqlite.ActiveInfluxConfig.CA,
+		"InfluxSkipVerify": sqlite.ActiveInfluxConfig.SkipVerify,
+		"Routes":           outputRoutes(),
+		"Agents":           agentTunings(),
 		"GrafanaPort":      grafanaPort, "ChronografPort": chronografPort})
 }
 
+// Routing policies in display order
+func outputRoutes() []sqlite.OutputRoute {
+	routes := make([]sqlite.OutputRoute, 0, len(sqlite.RoutedOutputs))
+	for _, o := range sqlite.RoutedOutputs {
+		routes = append(routes, sqlite.ActiveRoutes[o])
+	}
+	return routes
+}
+
+// Agent overrides in display order
+func agentTunings() []sqlite.AgentTuning {
+	agents := make([]sqlite.AgentTuning, 0, len(sqlite.AgentFamilies))
+	for _, f := range sqlite.AgentFamilies {
+		agents = append(agents, sqlite.ActiveAgentTuning[f])
+	}
+	return agents
+}
+
 func routeProfiles(c echo.Context) error {
 	grafanaPort := collectCfg.cfg.Grafana.Port
 	chronografPort := collectCfg.cfg.Chronograf.Port
@@ -1048,6 +972,27 @@ func routeUpdateDebug(c echo.Context) error {
 	return c.JSON(http.StatusOK, Reply{Status: "OK", Msg: "debug mode has been changed"})
 }
 
+func routeUpdateAgent(c echo.Context) error {
+	t := new(sqlite.AgentTuning)
+
+	err := c.Bind(t)
+	if err != nil {
+		logger.Log.Errorf("Unable to parse Post request for updating the agent tuning: %v", err)
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to parse the data"})
+	}
+	if err := t.Check(); err != nil {
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: err.Error()})
+	}
+
+	err = association.ChangeAgentTuning(*t)
+	if err != nil {
+		logger.Log.Errorf("Unable to update the agent tuning of the family %s: %v", t.Family, err)
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to update the agent tuning"})
+	}
+
+	return c.JSON(http.StatusOK, Reply{Status: "OK", Msg: "agent tuning has been changed"})
+}
+
 func routeResetRouter(c echo.Context) error {
 	var err error
 
@@ -1263,6 +1208,10 @@ func routeContainerLogs(c echo.Context) error {
 	return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Msg: "Container logs", Data: logs})
 }
 
+func routeWorkerStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Data: worker.GetStats()})
+}
+
 func routeContainerStats(c echo.Context) error {
 	container.Cstats.StMu.Lock()
 	statsMap := container.Cstats.Stats
@@ -1271,6 +1220,26 @@ func routeContainerStats(c echo.Context) error {
 	return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Msg: "Container stats", Data: statsMap})
 }
 
+// Router x profile compatibility matrix - optional family and group filters,
+// format=csv for a CSV export
+func routeCompatibility(c echo.Context) error {
+	matrix := association.CompatibilityMatrix(c.QueryParam("family"), c.QueryParam("group"))
+	if c.QueryParam("format") != "csv" {
+		return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Msg: "Compatibility matrix", Data: matrix})
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename=compatibility.csv")
+	c.Response().WriteHeader(http.StatusOK)
+	w := csv.NewWriter(c.Response())
+	w.Write([]string{"router", "hostname", "family", "version", "group", "profile", "compatible", "config", "rule", "reason"})
+	for _, e := range matrix {
+		w.Write([]string{e.Router, e.Hostname, e.Family, e.Version, e.Group, e.Profile, strconv.FormatBool(e.Compatible), e.Config, e.Rule, e.Reason})
+	}
+	w.Flush()
+	return w.Error()
+}
+
 func routeStream(c echo.Context) error {
 	// Set the response header for Server-Sent Events
 	c.Response().Header().Set(echo.HeaderContentType, "text/event-stream")
@@ -1463,6 +1432,259 @@ func routeShortNameRouter(c echo.Context) error {
 
 }
 
+func routeMetaHistory(c echo.Context) error {
+	var err error
+
+	r := new(MetaHistory)
+
+	err = c.Bind(r)
+	if err != nil {
+		logger.Log.Errorf("Unable to parse Post request for metadata history: %v", err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to parse the request"})
+	}
+	// empty shortname means all routers
+	hostname := ""
+	if r.Shortname != "" {
+		_, hostname, err = sqlite.GetRouterByShort(r.Shortname)
+		if err != nil {
+			return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unknown router " + r.Shortname})
+		}
+	}
+	changes, err := sqlite.GetMetaChanges(hostname, r.Limit)
+	if err != nil {
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to retrieve the metadata history"})
+	}
+	return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Data: changes})
+}
+
+func routeRunReports(c echo.Context) error {
+	var err error
+
+	r := new(MetaHistory)
+
+	err = c.Bind(r)
+	if err != nil {
+		logger.Log.Errorf("Unable to parse Post request for run reports: %v", err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to parse the request"})
+	}
+	_, hostname, err := sqlite.GetRouterByShort(r.Shortname)
+	if err != nil {
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unknown router " + r.Shortname})
+	}
+	reports, err := sqlite.GetRunReports(hostname, r.Limit)
+	if err != nil {
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to retrieve the run reports"})
+	}
+	return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Data: reports})
+}
+
+func routeUploadProfile(c echo.Context) error {
+	file, err := c.FormFile("tgzFile")
+	if err != nil {
+		logger.Log.Errorf("Failed to retrieve the file: %v", err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Failed to retrieve the file"})
+	}
+	name := filepath.Base(file.Filename)
+	if !strings.HasSuffix(name, ".tgz") || name == ".tgz" {
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Invalid file type. Please upload a .tgz profile package."})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		logger.Log.Errorf("Failed to open the file: %v", err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Failed to open the file"})
+	}
+	defer src.Close()
+
+	// Keep the package name for the lint in a temporary directory
+	tmpDir, err := os.MkdirTemp("", "jtso-upload-")
+	if err != nil {
+		logger.Log.Errorf("Unable to create the upload directory: %v", err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to store the package"})
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpPath := filepath.Join(tmpDir, name)
+	if err := copyToFile(src, tmpPath); err != nil {
+		logger.Log.Errorf("Unable to store the uploaded package %s: %v", name, err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to store the package"})
+	}
+
+	// Optional detached signature
+	sigFile, err := c.FormFile("sigFile")
+	if err == nil {
+		sig, err := sigFile.Open()
+		if err == nil {
+			err = copyToFile(sig, tmpPath+association.SIG_EXT)
+			sig.Close()
+		}
+		if err != nil {
+			logger.Log.Errorf("Unable to store the signature of %s: %v", name, err)
+			return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to store the signature"})
+		}
+	}
+
+	report := association.LintPackage(tmpPath)
+	signer, err := association.CheckSignature(tmpPath)
+	if err != nil {
+		report.Valid = false
+		report.Issues = append(report.Issues, association.LintIssue{Level: association.LINT_ERROR, File: name + association.SIG_EXT, Msg: err.Error()})
+	}
+	report.Signer = signer
+	if !report.Valid {
+		logger.Log.Errorf("Uploaded profile %s rejected: %s", report.Profile, report.Error())
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Profile " + report.Profile + " rejected", Data: report})
+	}
+
+	// The signature is installed first so that the periodic check sees both files -
+	// the signature of a previous package never applies to an unsigned upload
+	if sigFile != nil {
+		if err := installFile(tmpPath+association.SIG_EXT, association.PROFILES+name+association.SIG_EXT); err != nil {
+			logger.Log.Errorf("Unable to install the signature of %s: %v", name, err)
+			return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to store the signature"})
+		}
+	} else if err := os.Remove(association.PROFILES + name + association.SIG_EXT); err != nil && !os.IsNotExist(err) {
+		logger.Log.Errorf("Unable to remove the previous signature of %s: %v", name, err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to remove the previous signature"})
+	}
+
+	if err := installFile(tmpPath, association.PROFILES+name); err != nil {
+		logger.Log.Errorf("Unable to install the profile package %s: %v", name, err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to store the package"})
+	}
+	logger.Log.Infof("Profile package %s has been uploaded", name)
+
+	go association.PeriodicCheck(collectCfg.cfg)
+	return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Msg: "Profile " + report.Profile + " has been uploaded", Data: report})
+}
+
+func routeProfileVersions(c echo.Context) error {
+	r := new(ProfilePin)
+	if err := c.Bind(r); err != nil {
+		logger.Log.Errorf("Unable to parse Post request for profile versions: %v", err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to parse the request"})
+	}
+	versions, err := sqlite.GetProfileVersions(r.Profile)
+	if err != nil {
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to retrieve the profile versions"})
+	}
+	pins, err := sqlite.GetProfilePins()
+	if err != nil {
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to retrieve the profile pins"})
+	}
+	association.ProfileLock.Lock()
+	active := association.ActiveProfiles[r.Profile].Hash
+	association.ProfileLock.Unlock()
+
+	return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Data: map[string]interface{}{
+		"versions": versions,
+		"active":   active,
+		"pinned":   pins[r.Profile],
+	}})
+}
+
+func routeProfilePin(c echo.Context) error {
+	r := new(ProfilePin)
+	if err := c.Bind(r); err != nil {
+		logger.Log.Errorf("Unable to parse Post request for profile pin: %v", err)
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to parse the request"})
+	}
+	if err := association.PinProfile(collectCfg.cfg, r.Profile, r.Hash); err != nil {
+		logger.Log.Errorf("Unable to pin profile %s: %v", r.Profile, err)
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: err.Error()})
+	}
+	if r.Hash == "" {
+		return c.JSON(http.StatusOK, Reply{Status: "OK", Msg: "Profile " + r.Profile + " follows the latest package"})
+	}
+	return c.JSON(http.StatusOK, Reply{Status: "OK", Msg: "Profile " + r.Profile + " pinned - the stack will be reconfigured"})
+}
+
+func routeProfileParams(c echo.Context) error {
+	r := new(ParamMgt)
+	if err := c.Bind(r); err != nil {
+		logger.Log.Errorf("Unable to parse Post request for profile parameters: %v", err)
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to parse the request"})
+	}
+
+	switch r.Action {
+	case "get":
+		association.ProfileLock.Lock()
+		p, ok := association.ActiveProfiles[r.Profile]
+		association.ProfileLock.Unlock()
+		if !ok || p.Definition == nil {
+			return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unknown profile " + r.Profile})
+		}
+		values := make([]*sqlite.ParamValue, 0)
+		for _, v := range sqlite.ActiveParams {
+			if v.Profile == r.Profile {
+				values = append(values, v)
+			}
+		}
+		// routers associated to the profile and their group
+		routers := make(map[string]string)
+		for _, a := range sqlite.AssoList {
+			for _, n := range a.Assos {
+				if n == r.Profile {
+					routers[a.Shortname] = sqlite.RouterGroups[a.Shortname]
+				}
+			}
+		}
+		return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Data: map[string]interface{}{
+			"parameters": p.Definition.Parameters,
+			"values":     values,
+			"routers":    routers,
+		}})
+	case "set":
+		v := &sqlite.ParamValue{Profile: r.Profile, Param: r.Param, Scope: r.Scope, Target: strings.TrimSpace(r.Target), Value: r.Value}
+		if err := association.SetParam(v); err != nil {
+			logger.Log.Errorf("Unable to set parameter %s of profile %s: %v", r.Param, r.Profile, err)
+			return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: err.Error()})
+		}
+	case "setgroup":
+		if err := sqlite.SetRouterGroup(r.Target, strings.TrimSpace(r.Value)); err != nil {
+			return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unable to set the group of router " + r.Target})
+		}
+	default:
+		return c.JSON(http.StatusOK, ReplyStats{Status: "NOK", Msg: "Unknown action"})
+	}
+
+	go association.ConfigueStack(collectCfg.cfg, "all")
+	return c.JSON(http.StatusOK, ReplyStats{Status: "OK", Msg: "Parameters updated - the stack will be reconfigured"})
+}
+
+// Copy a file under a name ignored by the periodic check then rename it
+func installFile(src string, dst string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	f, err := os.Open(src)
+	if err == nil {
+		err = copyToFile(f, tmp.Name())
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Copy a reader into a new file
+func copyToFile(src io.Reader, path string) error {
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
 func routeUptSettings(c echo.Context) error {
 	var err error
 	somethingChange := false
@@ -1473,6 +1695,30 @@ func routeUptSettings(c echo.Context) error {
 		logger.Log.Errorf("Unable to parse Post request for updating Settings: %v", err)
 		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to update Settings"})
 	}
+
+	// validate the whole settings before saving any of them
+	switch r.KafkaSasl {
+	case "", "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+	default:
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unknown Kafka SASL mechanism"})
+	}
+	switch r.KafkaRouting {
+	case sqlite.KAFKA_ROUTING_NONE, sqlite.KAFKA_ROUTING_SUFFIX, sqlite.KAFKA_ROUTING_TAG, sqlite.KAFKA_ROUTING_RULES:
+	default:
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unknown Kafka topic routing"})
+	}
+	if r.PromMode != "client" && r.PromMode != "remotewrite" {
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unknown Prometheus mode - expected client or remotewrite"})
+	}
+	for i := range r.Routes {
+		if err := r.Routes[i].Check(); err != nil {
+			return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: err.Error()})
+		}
+	}
+	if r.InfluxVersion != influx.V1 && r.InfluxVersion != influx.V2 && r.InfluxVersion != influx.V3 {
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unknown InfluxDB version - expected v1, v2 or v3"})
+	}
+
 	if r.UseTls != sqlite.ActiveCred.UseTls || r.SkipVerify != sqlite.ActiveCred.SkipVerify || r.ClientTls != sqlite.ActiveCred.ClientTls || r.NetconfUser != sqlite.ActiveCred.NetconfUser || r.NetconfPwd != sqlite.ActiveCred.NetconfPwd || r.GnmiUser != sqlite.ActiveCred.GnmiUser || r.GnmiPwd != sqlite.ActiveCred.GnmiPwd {
 		somethingChange = true
 	}
@@ -1503,6 +1749,52 @@ func routeUptSettings(c echo.Context) error {
 		logger.Log.Errorf("Unable to update Kafka configuration: %v", err)
 		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to update Kafka configuration"})
 	}
+
+	newKafka := sqlite.KafkaOptions{SaslMechanism: r.KafkaSasl, SaslUser: r.KafkaSaslUser, SaslPwd: r.KafkaSaslPwd,
+		Tls: r.KafkaTls, CA: r.KafkaCA, SkipVerify: r.KafkaSkipVerify, Routing: r.KafkaRouting, TopicTag: r.KafkaTopicTag,
+		Routes: r.KafkaRoutes, ExcludedProfiles: r.KafkaExcluded}
+	if newKafka != sqlite.ActiveKafkaOptions {
+		somethingChange = true
+	}
+
+	err = sqlite.UpdateKafkaOptions(newKafka)
+	if err != nil {
+		logger.Log.Errorf("Unable to update Kafka options: %v", err)
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to update Kafka options"})
+	}
+
+	if r.PromEnabled != sqlite.ActivePrometheusConfig.Enabled || r.PromMode != sqlite.ActivePrometheusConfig.Mode || r.PromPort != sqlite.ActivePrometheusConfig.Port || r.PromEndpoint != sqlite.ActivePrometheusConfig.Endpoint {
+		somethingChange = true
+	}
+
+	err = sqlite.UpdatePrometheusConfig(r.PromEnabled, r.PromMode, r.PromPort, r.PromEndpoint)
+	if err != nil {
+		logger.Log.Errorf("Unable to update Prometheus configuration: %v", err)
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to update Prometheus configuration"})
+	}
+
+	for i := range r.Routes {
+		if r.Routes[i] != sqlite.ActiveRoutes[r.Routes[i].Output] {
+			somethingChange = true
+		}
+	}
+	err = sqlite.UpdateOutputRoutes(r.Routes)
+	if err != nil {
+		logger.Log.Errorf("Unable to update the output routing: %v", err)
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to update the output routing"})
+	}
+
+	newInflux := sqlite.InfluxConfig{Version: r.InfluxVersion, Url: r.InfluxUrl, Database: r.InfluxDatabase, Org: r.InfluxOrg,
+		Username: r.InfluxUser, Password: r.InfluxPwd, Token: r.InfluxToken, CA: r.InfluxCA, SkipVerify: r.InfluxSkipVerify}
+	if newInflux != sqlite.ActiveInfluxConfig {
+		somethingChange = true
+	}
+
+	err = sqlite.UpdateInfluxConfig(newInflux)
+	if err != nil {
+		logger.Log.Errorf("Unable to update InfluxDB connection: %v", err)
+		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to update InfluxDB connection"})
+	}
 	logger.Log.Info("Settings have been successfully updated")
 
 	// Check if we need to restart some components
@@ -1665,25 +1957,50 @@ func routeUptDoc(c echo.Context) error {
 	}
 	association.ProfileLock.Lock()
 	p, ok := association.ActiveProfiles[r.Profile]
+	// resolved view - the profile and the profiles it extends or includes
+	components := make([]association.FileTgz, 0)
+	for _, n := range p.Components {
+		if e, found := association.ActiveProfiles[n]; found && e.Definition != nil {
+			components = append(components, e)
+		}
+	}
 	association.ProfileLock.Unlock()
 	if !ok {
 		logger.Log.Errorf("Unable to update documentation: %v", err)
 		return c.JSON(http.StatusOK, Reply{Status: "NOK", Msg: "Unable to update documentation"})
 	}
+	if len(components) == 0 {
+		components = append(components, p)
+	}
 	var tele strings.Builder
+	kapaList := make([]string, 0)
+	grafList := make([]string, 0)
 
-	renderTele(&tele, "MX", "mx", p.Definition.TelCfg.MxCfg, r.Profile)
-	renderTele(&tele, "PTX", "ptx", p.Definition.TelCfg.PtxCfg, r.Profile)
-	renderTele(&tele, "ACX", "acx", p.Definition.TelCfg.AcxCfg, r.Profile)
-	renderTele(&tele, "EX", "ex", p.Definition.TelCfg.ExCfg, r.Profile)
-	renderTele(&tele, "QFX", "qfx", p.Definition.TelCfg.QfxCfg, r.Profile)
-	renderTele(&tele, "SRX", "srx", p.Definition.TelCfg.SrxCfg, r.Profile)
-	renderTele(&tele, "CRPD", "crpd", p.Definition.TelCfg.CrpdCfg, r.Profile)
-	renderTele(&tele, "CPTX", "cptx", p.Definition.TelCfg.CptxCfg, r.Profile)
-	renderTele(&tele, "VMX", "vmx", p.Definition.TelCfg.VmxCfg, r.Profile)
-	renderTele(&tele, "VSRX", "vsrx", p.Definition.TelCfg.VsrxCfg, r.Profile)
-	renderTele(&tele, "VJUNOS", "vjunos", p.Definition.TelCfg.VjunosCfg, r.Profile)
-	renderTele(&tele, "VJUNOS-EVO", "vevo", p.Definition.TelCfg.VevoCfg, r.Profile)
+	for _, e := range components {
+		from := ""
+		if e.Filename != r.Profile {
+			from = " (from " + e.Filename + ")"
+		}
+		renderTele(&tele, "MX", "mx", e.Definition.TelCfg.MxCfg, e.Filename)
+		renderTele(&tele, "PTX", "ptx", e.Definition.TelCfg.PtxCfg, e.Filename)
+		renderTele(&tele, "ACX", "acx", e.Definition.TelCfg.AcxCfg, e.Filename)
+		renderTele(&tele, "EX", "ex", e.Definition.TelCfg.ExCfg, e.Filename)
+		renderTele(&tele, "QFX", "qfx", e.Definition.TelCfg.QfxCfg, e.Filename)
+		renderTele(&tele, "SRX", "srx", e.Definition.TelCfg.SrxCfg, e.Filename)
+		renderTele(&tele, "CRPD", "crpd", e.Definition.TelCfg.CrpdCfg, e.Filename)
+		renderTele(&tele, "CPTX", "cptx", e.Definition.TelCfg.CptxCfg, e.Filename)
+		renderTele(&tele, "VMX", "vmx", e.Definition.TelCfg.VmxCfg, e.Filename)
+		renderTele(&tele, "VSRX", "vsrx", e.Definition.TelCfg.VsrxCfg, e.Filename)
+		renderTele(&tele, "VJUNOS", "vjunos", e.Definition.TelCfg.VjunosCfg, e.Filename)
+		renderTele(&tele, "VJUNOS-EVO", "vevo", e.Definition.TelCfg.VevoCfg, e.Filename)
+
+		for _, v := range e.Definition.KapaCfg {
+			kapaList = append(kapaList, "Script: "+v+from)
+		}
+		for _, v := range e.Definition.GrafaCfg {
+			grafList = append(grafList, "Dashboard: "+v+from)
+		}
+	}
 
 	teleHTML := tele.String()
 
@@ -1692,32 +2009,26 @@ func routeUptDoc(c echo.Context) error {
 		teleHTML = "No Telegraf configuration attached to this profile"
 	}
 
-	kapa := ""
-	for i, v := range p.Definition.KapaCfg {
-		if i == len(p.Definition.KapaCfg)-1 {
-			kapa += "Script: " + v
-		} else {
-			kapa += "Script: " + v + "</br>"
-		}
-
-	}
+	kapa := strings.Join(kapaList, "</br>")
 	if kapa == "" {
 		kapa = "No Kapacitor script attached to this profile"
 	}
 
-	graf := ""
-	for i, v := range p.Definition.GrafaCfg {
-		if i == len(p.Definition.GrafaCfg)-1 {
-			graf += "Dashboard: " + v
-		} else {
-			graf += "Dashboard: " + v + "</br>"
-		}
-
-	}
+	graf := strings.Join(grafList, "</br>")
 	if graf == "" {
 		graf = "No Grafana Dashboards attached to this profile"
 	}
-	return c.JSON(http.StatusOK, ReplyDoc{Status: "OK", Desc: p.Definition.Description, Tele: teleHTML, Graf: graf, Kapa: kapa})
+	signer := "Not signed"
+	if p.Signer != "" {
+		signer = "Signed by " + p.Signer
+	}
+	compo := "Standalone profile"
+	if p.ComposeError != "" {
+		compo = "Unable to resolve extends/includes: " + p.ComposeError
+	} else if len(p.Components) > 1 {
+		compo = "Resolved as " + strings.Join(p.Components, " + ")
+	}
+	return c.JSON(http.StatusOK, ReplyDoc{Status: "OK", Desc: p.Definition.Description, Tele: teleHTML, Graf: graf, Kapa: kapa, Signer: signer, Hash: p.Hash, Compo: compo})
 }
 
 func routeOnDemandMgt(c echo.Context) error {
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"jtso/association"
+	"jtso/maker"
+	"os"
+	"path/filepath"
+)
+
+// Profile tools
+// Usage: jtso profile lint [-json] [-keys dir] <package.tgz>...
+//
+//	jtso profile import [-o file] [-json] <telegraf.conf>...
+func runProfile(args []string) int {
+	if len(args) < 1 {
+		fmt.Println("Usage: jtso profile lint [-json] [-keys dir] <package.tgz>...")
+		fmt.Println("       jtso profile import [-o file] [-json] <telegraf.conf>...")
+		return 1
+	}
+	switch args[0] {
+	case "lint":
+		return runProfileLint(args[1:])
+	case "import":
+		return runProfileImport(args[1:])
+	}
+	fmt.Printf("Unknown profile command %s\n", args[0])
+	return 1
+}
+
+func runProfileLint(args []string) int {
+	fs := flag.NewFlagSet("profile lint", flag.ExitOnError)
+	asJson := fs.Bool("json", false, "Print the reports in JSON")
+	keys := fs.String("keys", "", "Trusted keys directory - check the <package>.tgz.sig signature")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Println("Usage: jtso profile lint [-json] [-keys dir] <package.tgz>...")
+		return 1
+	}
+
+	reports := make([]*association.LintReport, 0)
+	rc := 0
+	for _, pkg := range fs.Args() {
+		r := association.LintPackage(pkg)
+		if *keys != "" {
+			signer, err := association.VerifyPackage(pkg, *keys)
+			if err != nil {
+				r.Valid = false
+				r.Issues = append(r.Issues, association.LintIssue{Level: association.LINT_ERROR, File: filepath.Base(pkg) + association.SIG_EXT, Msg: err.Error()})
+			}
+			r.Signer = signer
+		}
+		if !r.Valid {
+			rc = 1
+		}
+		reports = append(reports, r)
+	}
+
+	if *asJson {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(reports)
+		return rc
+	}
+
+	for _, r := range reports {
+		state := "OK"
+		if !r.Valid {
+			state = "FAILED"
+		}
+		if r.Signer != "" {
+			state += " - signed by " + r.Signer
+		}
+		fmt.Printf("%s: %s\n", r.Profile, state)
+		for _, i := range r.Issues {
+			fmt.Printf("  %-7s %s: %s\n", i.Level, i.File, i.Msg)
+		}
+	}
+	return rc
+}
+
+// Import the Telegraf files of an agent into a maker config - the files are
+// merged as Telegraf does with telegraf.conf and telegraf.d
+func runProfileImport(args []string) int {
+	fs := flag.NewFlagSet("profile import", flag.ExitOnError)
+	out := fs.String("o", "", "Write the config to this file instead of stdout")
+	asJson := fs.Bool("json", false, "Print the report in JSON")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Println("Usage: jtso profile import [-o file] [-json] <telegraf.conf>...")
+		return 1
+	}
+
+	cfg := new(maker.TelegrafConfig)
+	report := maker.ImportReport{Unsupported: make([]string, 0), Ignored: make([]string, 0)}
+	for _, f := range fs.Args() {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read %s: %v\n", f, err)
+			return 1
+		}
+		if err := maker.ImportToml(content, cfg, &report); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to import %s: %v\n", f, err)
+			return 1
+		}
+	}
+
+	payload, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to encode the config: %v\n", err)
+		return 1
+	}
+	if *out != "" {
+		if err := os.WriteFile(*out, append(payload, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write %s: %v\n", *out, err)
+			return 1
+		}
+	} else {
+		fmt.Println(string(payload))
+	}
+
+	// the report goes to stderr - stdout may hold the config
+	if *asJson {
+		enc := json.NewEncoder(os.Stderr)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		return 0
+	}
+	for _, s := range report.Unsupported {
+		fmt.Fprintf(os.Stderr, "  unsupported %s\n", s)
+	}
+	for _, s := range report.Ignored {
+		fmt.Fprintf(os.Stderr, "  ignored     %s\n", s)
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"jtso/config"
+	"jtso/logger"
+	"jtso/netconf"
+	"jtso/output"
+	"os"
+	"path/filepath"
+)
+
+// Replay a NETCONF capture offline and generate the metadata files with the
+// interface rules of the config file and the family recorded in each capture
+// Usage: jtso replay -dir /var/capture/ -config /etc/jtso/config.yml -out ./
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	dir := fs.String("dir", "/var/capture/", "Capture directory - one sub directory per router")
+	cfgFile := fs.String("config", "/etc/jtso/config.yml", "YAML configuration file path - empty means the built-in interface rules")
+	family := fs.String("family", "mx", "Family of the captured routers without a recorded family")
+	out := fs.String("out", ".", "Output folder of the metadata files")
+	router := fs.String("router", "", "Replay only this router")
+	fs.BoolVar(&logger.Verbose, "verbose", false, "Enable verbose in the console")
+	fs.Parse(args)
+
+	logger.StartConsoleLogger()
+
+	if err := netconf.SetMode(netconf.MODE_REPLAY, *dir); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	explicit := false
+	fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "config" })
+	if _, err := os.Stat(*cfgFile); *cfgFile != "" && err != nil && !explicit {
+		logger.Log.Warnf("No config file %s - the built-in interface rules are used", *cfgFile)
+		*cfgFile = ""
+	}
+	var rules map[string]*config.InterfaceRules
+	if *cfgFile != "" {
+		rules = config.NewConfigContainer(*cfgFile).Enricher.Interfaces
+	}
+	if err := output.MyMeta.LoadRules(rules); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	entries, err := os.ReadDir(*dir)
+	if err != nil {
+		fmt.Printf("Unable to read the capture directory %s: %v\n", *dir, err)
+		return 1
+	}
+
+	failed := 0
+	for _, e := range entries {
+		if !e.IsDir() || (*router != "" && e.Name() != *router) {
+			continue
+		}
+		f := netconf.CapturedFamily(*dir, e.Name())
+		if f == "" {
+			f = *family
+		}
+		task := &netconf.RouterTask{
+			Name:    e.Name(),
+			Family:  f,
+			Timeout: 60,
+			Jsonify: output.MyMeta,
+			Reports: -1,
+		}
+		if err := task.Work(context.Background()); err != nil {
+			logger.Log.Errorf("[%s] Replay failed: %v", e.Name(), err)
+			failed++
+		}
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fmt.Printf("Unable to create the output folder %s: %v\n", *out, err)
+		return 1
+	}
+	if err := output.MyMeta.MarshallMeta(*out); err != nil {
+		fmt.Printf("Unable to create the metadata files: %v\n", err)
+		return 1
+	}
+	abs, _ := filepath.Abs(*out)
+	fmt.Printf("Metadata files generated in %s\n", abs)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"jtso/logger"
+	"jtso/simulator"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/viper"
+)
+
+// Run a simulator
+// Usage: jtso simulate netconf|gnmi [options]
+func runSimulate(args []string) int {
+	if len(args) < 1 {
+		fmt.Println("Usage: jtso simulate netconf|gnmi [options]")
+		return 1
+	}
+	switch args[0] {
+	case "netconf":
+		return runSimulateNetconf(args[1:])
+	case "gnmi":
+		return runSimulateGnmi(args[1:])
+	}
+	fmt.Printf("Unknown simulator %s\n", args[0])
+	return 1
+}
+
+// Context cancelled on SIGINT or SIGTERM
+func signalContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-c
+		cancel()
+	}()
+	return ctx
+}
+
+// Increment an IPv4 address
+func nextIP(ip net.IP, n int) net.IP {
+	ip4 := ip.To4()
+	v := uint32(ip4[0])<<24 | uint32(ip4[1])<<16 | uint32(ip4[2])<<8 | uint32(ip4[3])
+	v += uint32(n)
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func runSimulateNetconf(args []string) int {
+	fs := flag.NewFlagSet("simulate netconf", flag.ExitOnError)
+	fleetFile := fs.String("fleet", "", "YAML fleet file - overrides the other device options")
+	count := fs.Int("count", 1, "Number of devices")
+	baseIP := fs.String("ip", "127.0.1.1", "IP of the first device - incremented for each device")
+	port := fs.Int("port", 830, "NETCONF port of each device")
+	prefix := fs.String("prefix", "sim", "Hostname prefix of the devices")
+	model := fs.String("model", "mx960", "Device model")
+	version := fs.String("version", "23.4R1.10", "Junos version")
+	ports := fs.Int("ports", 8, "Number of physical ports per device")
+	lags := fs.Int("lags", 2, "Number of aggregated interfaces per device")
+	latency := fs.Int("latency", 0, "Latency added to each RPC in ms")
+	errorRate := fs.Float64("error-rate", 0, "Probability (0..1) of an rpc-error reply")
+	malformedRate := fs.Float64("malformed-rate", 0, "Probability (0..1) of a malformed reply")
+	user := fs.String("user", "jtso", "NETCONF username")
+	password := fs.String("password", "jtso", "NETCONF password")
+	fs.BoolVar(&logger.Verbose, "verbose", false, "Enable verbose in the console")
+	fs.Parse(args)
+
+	logger.StartConsoleLogger()
+
+	devices := make([]*simulator.Device, 0)
+	if *fleetFile != "" {
+		v := viper.New()
+		v.SetConfigFile(*fleetFile)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			fmt.Printf("Unable to read the fleet file: %v\n", err)
+			return 1
+		}
+		if v.IsSet("user") {
+			*user = v.GetString("user")
+		}
+		if v.IsSet("password") {
+			*password = v.GetString("password")
+		}
+		if err := v.UnmarshalKey("devices", &devices); err != nil {
+			fmt.Printf("Unable to parse the fleet file: %v\n", err)
+			return 1
+		}
+	} else {
+		ip := net.ParseIP(*baseIP)
+		if ip == nil || ip.To4() == nil {
+			fmt.Printf("Invalid IPv4 address %s\n", *baseIP)
+			return 1
+		}
+		for i := 0; i < *count; i++ {
+			devices = append(devices, &simulator.Device{
+				Name:          fmt.Sprintf("%s%d", *prefix, i+1),
+				Listen:        fmt.Sprintf("%s:%d", nextIP(ip, i).String(), *port),
+				Model:         *model,
+				Version:       *version,
+				Ports:         *ports,
+				Lags:          *lags,
+				Latency:       *latency,
+				ErrorRate:     *errorRate,
+				MalformedRate: *malformedRate,
+			})
+		}
+	}
+
+	fleet, err := simulator.NewNetconfFleet(*user, *password, devices)
+	if err != nil {
+		fmt.Printf("Unable to create the fleet: %v\n", err)
+		return 1
+	}
+
+	// Router CSV ready to be imported in the portal
+	fmt.Println("Router CSV file for the portal:")
+	for _, d := range devices {
+		host, _, _ := net.SplitHostPort(d.Listen)
+		fmt.Printf("%s;%s\n", d.Name, host)
+	}
+
+	if err := fleet.Run(signalContext()); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+func runSimulateGnmi(args []string) int {
+	fs := flag.NewFlagSet("simulate gnmi", flag.ExitOnError)
+	fleetFile := fs.String("fleet", "", "YAML fleet file - overrides the other target options")
+	pathsFile := fs.String("paths", "", "YAML file with the simulated leaves of all the targets")
+	count := fs.Int("count", 1, "Number of targets")
+	baseIP := fs.String("ip", "127.0.2.1", "IP of the first target - incremented for each target")
+	port := fs.Int("port", 9339, "gNMI port of each target")
+	prefix := fs.String("prefix", "sim", "Hostname prefix of the targets")
+	ports := fs.Int("ports", 4, "Number of interfaces of the default leaves")
+	useTls := fs.Bool("tls", false, "Enable TLS")
+	cert := fs.String("cert", "/var/cert/server.crt", "Server certificate")
+	key := fs.String("key", "/var/cert/server.key", "Server private key")
+	ca := fs.String("ca", "", "Client CA - enables the client certificate check, e.g. /var/cert/RootCA.crt")
+	user := fs.String("user", "jtso", "gNMI username - empty to disable the check")
+	password := fs.String("password", "jtso", "gNMI password")
+	fs.BoolVar(&logger.Verbose, "verbose", false, "Enable verbose in the console")
+	fs.Parse(args)
+
+	logger.StartConsoleLogger()
+
+	leaves := make([]simulator.LeafConfig, 0)
+	if *pathsFile != "" {
+		v := viper.New()
+		v.SetConfigFile(*pathsFile)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			fmt.Printf("Unable to read the paths file: %v\n", err)
+			return 1
+		}
+		if err := v.UnmarshalKey("leaves", &leaves); err != nil {
+			fmt.Printf("Unable to parse the paths file: %v\n", err)
+			return 1
+		}
+	}
+
+	targets := make([]*simulator.GnmiTarget, 0)
+	if *fleetFile != "" {
+		v := viper.New()
+		v.SetConfigFile(*fleetFile)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			fmt.Printf("Unable to read the fleet file: %v\n", err)
+			return 1
+		}
+		if v.IsSet("user") {
+			*user = v.GetString("user")
+		}
+		if v.IsSet("password") {
+			*password = v.GetString("password")
+		}
+		if err := v.UnmarshalKey("targets", &targets); err != nil {
+			fmt.Printf("Unable to parse the fleet file: %v\n", err)
+			return 1
+		}
+	} else {
+		ip := net.ParseIP(*baseIP)
+		if ip == nil || ip.To4() == nil {
+			fmt.Printf("Invalid IPv4 address %s\n", *baseIP)
+			return 1
+		}
+		for i := 0; i < *count; i++ {
+			targets = append(targets, &simulator.GnmiTarget{
+				Name:   fmt.Sprintf("%s%d", *prefix, i+1),
+				Listen: fmt.Sprintf("%s:%d", nextIP(ip, i).String(), *port),
+				Ports:  *ports,
+			})
+		}
+	}
+	for _, t := range targets {
+		if len(t.Leaves) == 0 {
+			t.Leaves = leaves
+		}
+	}
+
+	fleet := &simulator.GnmiFleet{User: *user, Password: *password, Targets: targets}
+	if *useTls {
+		fleet.Tls = &simulator.GnmiTls{Cert: *cert, Key: *key, ClientCA: *ca}
+	}
+
+	fmt.Println("Router CSV file for the portal:")
+	for _, t := range targets {
+		host, _, _ := net.SplitHostPort(t.Listen)
+		fmt.Printf("%s;%s\n", t.Name, host)
+	}
+
+	if err := fleet.Run(signalContext()); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Description of one simulated device
+type Device struct {
+	// Hostname answered by the device
+	Name string `mapstructure:"name"`
+	// Listen address host:port
+	Listen  string `mapstructure:"listen"`
+	Model   string `mapstructure:"model"`
+	Version string `mapstructure:"version"`
+	// Number of physical ports and aggregated interfaces
+	Ports int `mapstructure:"ports"`
+	Lags  int `mapstructure:"lags"`
+	// Latency in ms added to each RPC
+	Latency int `mapstructure:"latency"`
+	// Probability (0..1) of an rpc-error or a malformed reply
+	ErrorRate     float64 `mapstructure:"error_rate"`
+	MalformedRate float64 `mapstructure:"malformed_rate"`
+	// ISIS segment routing parameters
+	SrgbStart int `mapstructure:"srgb_start"`
+	NodeIndex int `mapstructure:"node_index"`
+
+	Index int `mapstructure:"-"`
+}
+
+type port struct {
+	Name string
+	Desc string
+}
+
+type lag struct {
+	Name    string
+	Desc    string
+	Members []string
+}
+
+type xcvr struct {
+	Slot int
+	Desc string
+}
+
+type pic struct {
+	Slot  int
+	Desc  string
+	Xcvrs []xcvr
+}
+
+type fpc struct {
+	Slot int
+	Desc string
+	Pics []pic
+}
+
+// Data rendered in the reply templates
+type deviceData struct {
+	*Device
+	NodeIndexV6 int
+	Ports       []port
+	Lags        []lag
+	Fpcs        []fpc
+}
+
+var tpl = template.Must(template.New("sim").Parse(""))
+
+func init() {
+	template.Must(tpl.New("hello").Parse(HelloTemplate))
+	template.Must(tpl.New("reply").Parse(ReplyTemplate))
+	template.Must(tpl.New("error").Parse(RpcErrorTemplate))
+	template.Must(tpl.New("version").Parse(VersionTemplate))
+	template.Must(tpl.New("chassis").Parse(ChassisTemplate))
+	template.Must(tpl.New("ifdesc").Parse(IfDescTemplate))
+	template.Must(tpl.New("ifterse").Parse(IfTerseTemplate))
+	template.Must(tpl.New("lacp").Parse(LacpTemplate))
+	template.Must(tpl.New("isis").Parse(IsisTemplate))
+}
+
+// Build the data model of the device: FPC0/PIC x/port y with up to 12 ports per PIC
+func (d *Device) data() *deviceData {
+	dd := &deviceData{Device: d, NodeIndexV6: d.NodeIndex + 1000}
+	const perPic = 12
+
+	f := fpc{Slot: 0, Desc: "MPC10E 3D MRATE-15xQSFPP"}
+	for i := 0; i < d.Ports; i++ {
+		picSlot, portSlot := i/perPic, i%perPic
+		if portSlot == 0 {
+			f.Pics = append(f.Pics, pic{Slot: picSlot, Desc: "MRATE-12xQSFPP-XGE-XLGE-CGE"})
+		}
+		f.Pics[picSlot].Xcvrs = append(f.Pics[picSlot].Xcvrs, xcvr{Slot: portSlot, Desc: "QSFP-100GBASE-LR4"})
+		p := port{Name: fmt.Sprintf("et-0/%d/%d", picSlot, portSlot)}
+		// leave one port out of 4 without description
+		if i%4 != 3 {
+			p.Desc = fmt.Sprintf("TO-%s-PEER-%d", strings.ToUpper(d.Name), i)
+		}
+		dd.Ports = append(dd.Ports, p)
+	}
+	if d.Ports > 0 {
+		dd.Fpcs = append(dd.Fpcs, f)
+	}
+
+	// 2 members per LAG taken from the first ports
+	for l := 0; l < d.Lags; l++ {
+		ae := lag{Name: fmt.Sprintf("ae%d", l), Desc: fmt.Sprintf("LAG-%d-%s", l, strings.ToUpper(d.Name))}
+		for m := 2 * l; m < 2*l+2 && m < len(dd.Ports); m++ {
+			ae.Members = append(ae.Members, dd.Ports[m].Name)
+		}
+		dd.Lags = append(dd.Lags, ae)
+	}
+	return dd
+}
+
+// Render a template for the device
+func (d *Device) render(name string, data interface{}) (string, error) {
+	var b bytes.Buffer
+	if err := tpl.ExecuteTemplate(&b, name, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Return the template of a NETCONF request, empty if not supported
+func rpcTemplate(request string) string {
+	switch {
+	case strings.Contains(request, "get-software-information"):
+		return "version"
+	case strings.Contains(request, "get-chassis-inventory"):
+		return "chassis"
+	case strings.Contains(request, "get-interface-information") && strings.Contains(request, "descriptions"):
+		return "ifdesc"
+	case strings.Contains(request, "get-interface-information") && strings.Contains(request, "terse"):
+		return "ifterse"
+	case strings.Contains(request, "get-lacp-interface-information"):
+		return "lacp"
+	case strings.Contains(request, "get-isis-overview-information"):
+		return "isis"
+	}
+	return ""
+}
@@ -0,0 +1,363 @@
+package simulator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"jtso/logger"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Minimum sample interval accepted by the targets
+const MIN_SAMPLE_INTERVAL time.Duration = 1 * time.Second
+
+// Description of one simulated gNMI target
+type GnmiTarget struct {
+	Name   string `mapstructure:"name"`
+	Listen string `mapstructure:"listen"`
+	// Number of interfaces of the default leaves - ignored if leaves are given
+	Ports  int          `mapstructure:"ports"`
+	Leaves []LeafConfig `mapstructure:"leaves"`
+}
+
+// TLS options of the targets. ClientCA enables the client certificate check.
+type GnmiTls struct {
+	Cert     string
+	Key      string
+	ClientCA string
+}
+
+// Fleet of simulated gNMI targets sharing the same credentials
+type GnmiFleet struct {
+	User     string
+	Password string
+	Targets  []*GnmiTarget
+	Tls      *GnmiTls
+}
+
+// gNMI server of one target
+type gnmiServer struct {
+	gnmi.UnimplementedGNMIServer
+	target *GnmiTarget
+	fleet  *GnmiFleet
+	leaves []*leaf
+}
+
+func newGnmiServer(f *GnmiFleet, t *GnmiTarget) (*gnmiServer, error) {
+	cfgs := t.Leaves
+	if len(cfgs) == 0 {
+		if t.Ports == 0 {
+			t.Ports = 4
+		}
+		cfgs = defaultLeaves(t.Ports)
+	}
+	s := &gnmiServer{target: t, fleet: f}
+	for _, c := range cfgs {
+		l, err := newLeaf(c)
+		if err != nil {
+			return nil, err
+		}
+		s.leaves = append(s.leaves, l)
+	}
+	return s, nil
+}
+
+// Check the username and password sent in the metadata
+func (s *gnmiServer) auth(ctx context.Context) error {
+	if s.fleet.User == "" {
+		return nil
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	u, p := md.Get("username"), md.Get("password")
+	if len(u) == 0 || len(p) == 0 || u[0] != s.fleet.User || p[0] != s.fleet.Password {
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	return nil
+}
+
+func (s *gnmiServer) Capabilities(ctx context.Context, req *gnmi.CapabilityRequest) (*gnmi.CapabilityResponse, error) {
+	if err := s.auth(ctx); err != nil {
+		return nil, err
+	}
+	return &gnmi.CapabilityResponse{
+		SupportedModels: []*gnmi.ModelData{
+			{Name: "openconfig-interfaces", Organization: "OpenConfig working group", Version: "3.0.0"},
+			{Name: "openconfig-platform", Organization: "OpenConfig working group", Version: "0.23.0"},
+			{Name: "openconfig-network-instance", Organization: "OpenConfig working group", Version: "4.0.0"},
+			{Name: "junos-system-linecard", Organization: "Juniper Networks, Inc.", Version: "1.0.0"},
+		},
+		SupportedEncodings: []gnmi.Encoding{gnmi.Encoding_PROTO, gnmi.Encoding_JSON, gnmi.Encoding_JSON_IETF},
+		GNMIVersion:        "0.8.0",
+	}, nil
+}
+
+// Build a notification with the leaves matching the paths - only the changed ones if onlyChanged
+func (s *gnmiServer) notification(prefix *gnmi.Path, paths []*gnmi.Path, onlyChanged map[*leaf]bool) *gnmi.Notification {
+	n := &gnmi.Notification{Timestamp: time.Now().UnixNano(), Prefix: &gnmi.Path{Target: s.target.Name}}
+	for _, l := range s.leaves {
+		if onlyChanged != nil && !onlyChanged[l] {
+			continue
+		}
+		for _, p := range paths {
+			if l.match(prefix, p) {
+				n.Update = append(n.Update, &gnmi.Update{Path: l.path, Val: l.value()})
+				break
+			}
+		}
+	}
+	return n
+}
+
+// Refresh the value of all the leaves
+func (s *gnmiServer) refresh() {
+	now := time.Now()
+	for _, l := range s.leaves {
+		l.update(now)
+	}
+}
+
+func (s *gnmiServer) Get(ctx context.Context, req *gnmi.GetRequest) (*gnmi.GetResponse, error) {
+	if err := s.auth(ctx); err != nil {
+		return nil, err
+	}
+	s.refresh()
+	paths := req.GetPath()
+	if len(paths) == 0 {
+		paths = []*gnmi.Path{{}}
+	}
+	n := s.notification(req.GetPrefix(), paths, nil)
+	if len(n.Update) == 0 {
+		return nil, status.Errorf(codes.NotFound, "no data for the requested paths")
+	}
+	return &gnmi.GetResponse{Notification: []*gnmi.Notification{n}}, nil
+}
+
+func (s *gnmiServer) Subscribe(stream gnmi.GNMI_SubscribeServer) error {
+	if err := s.auth(stream.Context()); err != nil {
+		return err
+	}
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	list := req.GetSubscribe()
+	if list == nil {
+		return status.Error(codes.InvalidArgument, "first message must be a SubscriptionList")
+	}
+	logger.Log.Infof("[%s] New %s subscription with %d path(s)", s.target.Name, list.GetMode(), len(list.GetSubscription()))
+
+	var sendMu sync.Mutex
+	send := func(r *gnmi.SubscribeResponse) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(r)
+	}
+	syncDone := func() error {
+		return send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_SyncResponse{SyncResponse: true}})
+	}
+	update := func(paths []*gnmi.Path, changed map[*leaf]bool) error {
+		n := s.notification(list.GetPrefix(), paths, changed)
+		if len(n.Update) == 0 {
+			return nil
+		}
+		return send(&gnmi.SubscribeResponse{Response: &gnmi.SubscribeResponse_Update{Update: n}})
+	}
+
+	all := make([]*gnmi.Path, 0)
+	for _, sub := range list.GetSubscription() {
+		all = append(all, sub.GetPath())
+		logger.Log.Debugf("[%s] Subscribed to %s (%s)", s.target.Name, pathString(sub.GetPath()), sub.GetMode())
+	}
+
+	// Initial snapshot for all the modes
+	s.refresh()
+	if err := update(all, nil); err != nil {
+		return err
+	}
+	if err := syncDone(); err != nil {
+		return err
+	}
+
+	switch list.GetMode() {
+	case gnmi.SubscriptionList_ONCE:
+		return nil
+	case gnmi.SubscriptionList_POLL:
+		for {
+			r, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if r.GetPoll() == nil {
+				continue
+			}
+			s.refresh()
+			if err := update(all, nil); err != nil {
+				return err
+			}
+			if err := syncDone(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// STREAM mode: one goroutine per sampled subscription and one for on_change
+	ctx := stream.Context()
+	errc := make(chan error, len(list.GetSubscription())+1)
+	onChange := make([]*gnmi.Path, 0)
+	for _, sub := range list.GetSubscription() {
+		if sub.GetMode() == gnmi.SubscriptionMode_ON_CHANGE {
+			onChange = append(onChange, sub.GetPath())
+			continue
+		}
+		interval := time.Duration(sub.GetSampleInterval())
+		if interval == 0 {
+			interval = 10 * time.Second
+		} else if interval < MIN_SAMPLE_INTERVAL {
+			interval = MIN_SAMPLE_INTERVAL
+		}
+		go func(p *gnmi.Path, interval time.Duration) {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					s.refresh()
+					if err := update([]*gnmi.Path{p}, nil); err != nil {
+						errc <- err
+						return
+					}
+				}
+			}
+		}(sub.GetPath(), interval)
+	}
+	if len(onChange) > 0 {
+		// values already sent, the leaves are shared with the other subscriptions
+		sent := make(map[*leaf]string)
+		for _, l := range s.leaves {
+			sent[l] = l.value().String()
+		}
+		go func() {
+			t := time.NewTicker(MIN_SAMPLE_INTERVAL)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					s.refresh()
+					changed := make(map[*leaf]bool)
+					for _, l := range s.leaves {
+						if v := l.value().String(); v != sent[l] {
+							sent[l] = v
+							changed[l] = true
+						}
+					}
+					if len(changed) == 0 {
+						continue
+					}
+					if err := update(onChange, changed); err != nil {
+						errc <- err
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errc:
+		return err
+	}
+}
+
+// Load the server TLS configuration
+func (t *GnmiTls) config() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the server certificate: %v", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if t.ClientCA != "" {
+		ca, err := os.ReadFile(t.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid client CA %s", t.ClientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// Start all the targets and block until the context is cancelled
+func (f *GnmiFleet) Run(ctx context.Context) error {
+	opts := make([]grpc.ServerOption, 0)
+	if f.Tls != nil {
+		cfg, err := f.Tls.config()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+
+	servers := make([]*grpc.Server, 0, len(f.Targets))
+	stopAll := func() {
+		for _, s := range servers {
+			s.Stop()
+		}
+	}
+	var wg sync.WaitGroup
+	for _, t := range f.Targets {
+		srv, err := newGnmiServer(f, t)
+		if err != nil {
+			stopAll()
+			return fmt.Errorf("target %s: %v", t.Name, err)
+		}
+		l, err := net.Listen("tcp", t.Listen)
+		if err != nil {
+			stopAll()
+			return fmt.Errorf("target %s unable to listen on %s: %v", t.Name, t.Listen, err)
+		}
+		g := grpc.NewServer(opts...)
+		gnmi.RegisterGNMIServer(g, srv)
+		servers = append(servers, g)
+		logger.Log.Infof("Simulated gNMI target %s listening on %s with %d leaves (tls: %t)", t.Name, t.Listen, len(srv.leaves), f.Tls != nil)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := g.Serve(l); err != nil {
+				logger.Log.Errorf("gNMI target stopped: %v", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stopAll()
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,55 @@
+package simulator
+
+import (
+	"context"
+	"jtso/gnmicollect"
+	"jtso/sqlite"
+	"strconv"
+	"testing"
+)
+
+func TestGnmiSimulatorOnDemand(t *testing.T) {
+	quietLogger()
+	port := freePort(t)
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	fleet := &GnmiFleet{User: "lab", Password: "lab123", Targets: []*GnmiTarget{{Name: "sim1", Listen: addr, Ports: 2}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		fleet.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitListen(t, addr)
+
+	sqlite.ActiveCred = sqlite.Cred{GnmiUser: "lab", GnmiPwd: "lab123", UseTls: "no", SkipVerify: "yes", ClientTls: "no"}
+	err, reply := gnmicollect.GnmiOnDemand(gnmicollect.OnceRequest{
+		Path:    "/interfaces/interface/state/counters",
+		Router:  "127.0.0.1",
+		Port:    port,
+		Timeout: 2,
+	}, true)
+	if err != nil {
+		t.Fatalf("GnmiOnDemand: %v", err)
+	}
+
+	fields := make(map[string][]string)
+	for _, f := range reply.Fields {
+		fields[f.Name] = f.InheritTags
+	}
+	// fields are relative to the requested path and inherit the interface key
+	for _, name := range []string{"./in-octets", "./out-octets", "./in-pkts", "./out-pkts", "./in-errors"} {
+		tags, ok := fields[name]
+		if !ok {
+			t.Errorf("field %s not collected - got %v", name, reply.Fields)
+			continue
+		}
+		if len(tags) != 1 || tags[0] != "/interfaces/interface/name" {
+			t.Errorf("field %s tags = %v, want [/interfaces/interface/name]", name, tags)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/gnmic/pkg/api/path"
+)
+
+// Kind of generated values
+const (
+	GEN_MONOTONIC string = "monotonic"
+	GEN_RANDOM    string = "random"
+	GEN_STEP      string = "step"
+)
+
+// Description of a simulated telemetry leaf
+type LeafConfig struct {
+	Path string `mapstructure:"path"`
+	// monotonic, random or step
+	Kind string `mapstructure:"kind"`
+	// monotonic: increase per second - random: max delta per update
+	Rate float64 `mapstructure:"rate"`
+	// random: bounds of the value
+	Min float64 `mapstructure:"min"`
+	Max float64 `mapstructure:"max"`
+	// step: values cycled every period seconds
+	Values []string `mapstructure:"values"`
+	Period int      `mapstructure:"period"`
+}
+
+// A leaf and its current value
+type leaf struct {
+	cfg  LeafConfig
+	path *gnmi.Path
+	mu   sync.Mutex
+	num  float64
+	idx  int
+	last time.Time
+}
+
+func newLeaf(cfg LeafConfig) (*leaf, error) {
+	p, err := path.ParsePath(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %v", cfg.Path, err)
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = GEN_MONOTONIC
+	}
+	switch cfg.Kind {
+	case GEN_MONOTONIC:
+		if cfg.Rate == 0 {
+			cfg.Rate = 1000
+		}
+	case GEN_RANDOM:
+		if cfg.Max <= cfg.Min {
+			cfg.Min, cfg.Max = 0, 100
+		}
+		if cfg.Rate == 0 {
+			cfg.Rate = (cfg.Max - cfg.Min) / 20
+		}
+	case GEN_STEP:
+		if len(cfg.Values) == 0 {
+			cfg.Values = []string{"UP", "DOWN"}
+		}
+		if cfg.Period <= 0 {
+			cfg.Period = 60
+		}
+	default:
+		return nil, fmt.Errorf("unknown generator %s for path %s", cfg.Kind, cfg.Path)
+	}
+	now := time.Now()
+	l := &leaf{cfg: cfg, path: p, last: now}
+	if cfg.Kind == GEN_RANDOM {
+		l.num = cfg.Min + (cfg.Max-cfg.Min)/2
+	}
+	return l, nil
+}
+
+// Compute the current value
+func (l *leaf) update(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	dt := now.Sub(l.last).Seconds()
+	l.last = now
+	switch l.cfg.Kind {
+	case GEN_MONOTONIC:
+		// +/- 10% around the rate
+		l.num += l.cfg.Rate * dt * (0.9 + rand.Float64()*0.2)
+	case GEN_RANDOM:
+		l.num += (rand.Float64()*2 - 1) * l.cfg.Rate
+		if l.num < l.cfg.Min {
+			l.num = l.cfg.Min
+		}
+		if l.num > l.cfg.Max {
+			l.num = l.cfg.Max
+		}
+	case GEN_STEP:
+		l.idx = int(now.Unix()/int64(l.cfg.Period)) % len(l.cfg.Values)
+	}
+}
+
+// Current value as a gNMI typed value
+func (l *leaf) value() *gnmi.TypedValue {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch l.cfg.Kind {
+	case GEN_MONOTONIC:
+		return &gnmi.TypedValue{Value: &gnmi.TypedValue_UintVal{UintVal: uint64(l.num)}}
+	case GEN_RANDOM:
+		return &gnmi.TypedValue{Value: &gnmi.TypedValue_IntVal{IntVal: int64(l.num)}}
+	default:
+		return &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: l.cfg.Values[l.idx]}}
+	}
+}
+
+// Check whether the leaf is under the requested path. Missing keys or "*"
+// match any value, "..." matches any number of elements.
+func (l *leaf) match(prefix *gnmi.Path, p *gnmi.Path) bool {
+	req := make([]*gnmi.PathElem, 0)
+	if prefix != nil {
+		req = append(req, prefix.GetElem()...)
+	}
+	if p != nil {
+		req = append(req, p.GetElem()...)
+	}
+	return matchElems(req, l.path.GetElem())
+}
+
+func matchElems(req []*gnmi.PathElem, elems []*gnmi.PathElem) bool {
+	for i, r := range req {
+		if r.GetName() == "..." {
+			for j := i; j <= len(elems); j++ {
+				if matchElems(req[i+1:], elems[j:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if i >= len(elems) {
+			return false
+		}
+		if r.GetName() != "*" && r.GetName() != elems[i].GetName() {
+			return false
+		}
+		for k, v := range r.GetKey() {
+			if v != "*" && elems[i].GetKey()[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Default leaves of a target: OpenConfig interface counters and status,
+// component temperature and a Junos native CPU leaf
+func defaultLeaves(ports int) []LeafConfig {
+	leaves := make([]LeafConfig, 0)
+	for i := 0; i < ports; i++ {
+		ifPath := fmt.Sprintf("/interfaces/interface[name=et-0/0/%d]/state", i)
+		leaves = append(leaves,
+			LeafConfig{Path: ifPath + "/counters/in-octets", Kind: GEN_MONOTONIC, Rate: 1.25e8},
+			LeafConfig{Path: ifPath + "/counters/out-octets", Kind: GEN_MONOTONIC, Rate: 1.1e8},
+			LeafConfig{Path: ifPath + "/counters/in-pkts", Kind: GEN_MONOTONIC, Rate: 1.2e5},
+			LeafConfig{Path: ifPath + "/counters/out-pkts", Kind: GEN_MONOTONIC, Rate: 1e5},
+			LeafConfig{Path: ifPath + "/counters/in-errors", Kind: GEN_MONOTONIC, Rate: 0.1},
+			LeafConfig{Path: ifPath + "/oper-status", Kind: GEN_STEP, Values: []string{"UP", "UP", "UP", "DOWN"}, Period: 300},
+		)
+	}
+	leaves = append(leaves,
+		LeafConfig{Path: "/components/component[name=FPC0]/state/temperature/instant", Kind: GEN_RANDOM, Min: 35, Max: 75, Rate: 2},
+		LeafConfig{Path: "/components/component[name=Routing Engine0]/state/temperature/instant", Kind: GEN_RANDOM, Min: 30, Max: 60, Rate: 1},
+		LeafConfig{Path: "/network-instances/network-instance[name=default]/protocols/protocol[identifier=BGP][name=bgp]/bgp/neighbors/neighbor[neighbor-address=192.0.2.1]/state/session-state",
+			Kind: GEN_STEP, Values: []string{"ESTABLISHED", "ESTABLISHED", "IDLE"}, Period: 600},
+		LeafConfig{Path: "/junos/system/linecard/cpu/memory/cpu-util-memory[name=FPC0]/utilization", Kind: GEN_RANDOM, Min: 5, Max: 95, Rate: 5},
+	)
+	return leaves
+}
+
+// Join the path elements for logs
+func pathString(p *gnmi.Path) string {
+	if p == nil {
+		return "/"
+	}
+	return "/" + strings.TrimPrefix(path.GnmiPathToXPath(p, false), "/")
+}
@@ -0,0 +1,218 @@
+package simulator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"jtso/logger"
+	mrand "math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Fleet of simulated NETCONF devices sharing the same credentials
+type NetconfFleet struct {
+	User     string
+	Password string
+	Devices  []*Device
+
+	signer    ssh.Signer
+	sessionId int64
+	wg        sync.WaitGroup
+}
+
+var reMessageId = regexp.MustCompile(`message-id="([^"]*)"`)
+
+// Create a new fleet - the SSH host key is generated on the fly
+func NewNetconfFleet(user string, password string, devices []*Device) (*NetconfFleet, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	for i, d := range devices {
+		d.Index = i
+		if d.Model == "" {
+			d.Model = "mx960"
+		}
+		if d.Version == "" {
+			d.Version = "23.4R1.10"
+		}
+		if d.SrgbStart == 0 {
+			d.SrgbStart = 16000
+		}
+		if d.NodeIndex == 0 {
+			d.NodeIndex = i + 1
+		}
+	}
+	return &NetconfFleet{User: user, Password: password, Devices: devices, signer: signer}, nil
+}
+
+// Start all the devices and block until the context is cancelled
+func (f *NetconfFleet) Run(ctx context.Context) error {
+	listeners := make([]net.Listener, 0, len(f.Devices))
+	for _, d := range f.Devices {
+		l, err := net.Listen("tcp", d.Listen)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("device %s unable to listen on %s: %v", d.Name, d.Listen, err)
+		}
+		listeners = append(listeners, l)
+		logger.Log.Infof("Simulated device %s (%s %s) listening on %s", d.Name, d.Model, d.Version, d.Listen)
+		f.wg.Add(1)
+		go f.serve(l, d)
+	}
+
+	<-ctx.Done()
+	for _, l := range listeners {
+		l.Close()
+	}
+	f.wg.Wait()
+	return nil
+}
+
+func (f *NetconfFleet) serve(l net.Listener, d *Device) {
+	defer f.wg.Done()
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == f.User && string(pass) == f.Password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", c.User())
+		},
+	}
+	config.AddHostKey(f.signer)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go f.handleConn(conn, config, d)
+	}
+}
+
+func (f *NetconfFleet) handleConn(conn net.Conn, config *ssh.ServerConfig, d *Device) {
+	defer logger.HandlePanic()
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		logger.Log.Warnf("[%s] SSH handshake failed: %v", d.Name, err)
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, requests, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				// payload is a SSH string: 4 bytes length + name
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "netconf"
+				req.Reply(ok, nil)
+				if ok {
+					go f.netconfSession(ch, d)
+				}
+			}
+		}()
+	}
+}
+
+// Read one NETCONF 1.0 message
+func readMessage(r *bufio.Reader) (string, error) {
+	var b bytes.Buffer
+	for {
+		line, err := r.ReadString('>')
+		b.WriteString(line)
+		if idx := strings.Index(b.String(), EOM); idx >= 0 {
+			return b.String()[:idx], nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func (f *NetconfFleet) netconfSession(ch ssh.Channel, d *Device) {
+	defer logger.HandlePanic()
+	defer ch.Close()
+
+	id := atomic.AddInt64(&f.sessionId, 1)
+	hello, _ := d.render("hello", id)
+	if _, err := io.WriteString(ch, hello+EOM); err != nil {
+		return
+	}
+
+	data := d.data()
+	r := bufio.NewReader(ch)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		if !strings.Contains(msg, "<rpc") {
+			// client hello
+			continue
+		}
+		messageId := ""
+		if m := reMessageId.FindStringSubmatch(msg); m != nil {
+			messageId = m[1]
+		}
+
+		if strings.Contains(msg, "close-session") {
+			io.WriteString(ch, d.reply(messageId, "<ok/>")+EOM)
+			return
+		}
+
+		if d.Latency > 0 {
+			time.Sleep(time.Duration(d.Latency) * time.Millisecond)
+		}
+
+		body := ""
+		name := rpcTemplate(msg)
+		switch {
+		case name == "":
+			body, _ = d.render("error", "syntax error, expecting <rpc> content")
+		case d.ErrorRate > 0 && mrand.Float64() < d.ErrorRate:
+			body, _ = d.render("error", "simulated error")
+		default:
+			body, err = d.render(name, data)
+			if err != nil {
+				body, _ = d.render("error", err.Error())
+			} else if d.MalformedRate > 0 && mrand.Float64() < d.MalformedRate {
+				// cut the reply in the middle of an element
+				body = body[:len(body)/2]
+			}
+		}
+		logger.Log.Debugf("[%s] RPC %s answered", d.Name, name)
+		if _, err := io.WriteString(ch, d.reply(messageId, body)+EOM); err != nil {
+			return
+		}
+	}
+}
+
+func (d *Device) reply(messageId string, body string) string {
+	s, _ := d.render("reply", map[string]string{"Version": d.Version, "MessageId": messageId, "Body": body})
+	return s
+}
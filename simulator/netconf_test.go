@@ -0,0 +1,107 @@
+package simulator
+
+import (
+	"context"
+	"io"
+	"jtso/logger"
+	"jtso/netconf"
+	"jtso/output"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reserve a free local port
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// Wait until the simulator accepts connections
+func waitListen(t *testing.T, addr string) {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("simulator not listening on %s", addr)
+}
+
+func quietLogger() {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+}
+
+func TestNetconfSimulatorEnrichment(t *testing.T) {
+	quietLogger()
+	port := freePort(t)
+	addr := "127.0.0.1:" + strconv.Itoa(port)
+
+	fleet, err := NewNetconfFleet("lab", "lab123", []*Device{{Name: "sim1", Listen: addr, Ports: 4, Lags: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		fleet.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitListen(t, addr)
+
+	if err := netconf.SetMode(netconf.MODE_LIVE, ""); err != nil {
+		t.Fatal(err)
+	}
+	output.MyMeta.Clear()
+	if err := output.MyMeta.LoadRules(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	task := &netconf.RouterTask{
+		Name:    "127.0.0.1",
+		User:    "lab",
+		Pwd:     "lab123",
+		Family:  "mx",
+		Port:    port,
+		Timeout: 10,
+		Jsonify: output.MyMeta,
+		Reports: -1,
+	}
+	if err := task.Work(context.Background()); err != nil {
+		t.Fatalf("Work: %v", err)
+	}
+
+	meta := output.MyMeta.Meta["mx"]["127.0.0.1"]
+	tests := []struct {
+		entry, key, want string
+	}{
+		{"et-0/0/0", "DESC", "TO_SIM1_PEER_0"},
+		{"et-0/0/0", "LAG", "ae0"},
+		{"et-0/0/3", "DESC", "Unknown"},
+		{"ae0.0", "DESC", "LAG_0_SIM1_UNIT0"},
+		{"FPC0", "HW_TYPE", "MPC10E 3D MRATE-15xQSFPP"},
+		{"LEVEL1TAGS", "MODEL", "mx960"},
+		{"LEVEL1TAGS", "MPLS_V4_LABEL", "16001"},
+		{"LEVEL1TAGS", "MPLS_V6_LABEL", "17001"},
+	}
+	for _, tt := range tests {
+		if got := meta[tt.entry][tt.key]; got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.entry, tt.key, got, tt.want)
+		}
+	}
+}
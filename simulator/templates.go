@@ -0,0 +1,145 @@
+package simulator
+
+// NETCONF 1.0 end of message delimiter
+const EOM string = "]]>]]>"
+
+const HelloTemplate string = `<?xml version="1.0" encoding="UTF-8"?>
+<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+  <capabilities>
+    <capability>urn:ietf:params:netconf:base:1.0</capability>
+    <capability>http://xml.juniper.net/netconf/junos/1.0</capability>
+  </capabilities>
+  <session-id>{{.}}</session-id>
+</hello>`
+
+const ReplyTemplate string = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:junos="http://xml.juniper.net/junos/{{.Version}}/junos" message-id="{{.MessageId}}">
+{{.Body}}
+</rpc-reply>`
+
+const RpcErrorTemplate string = `<rpc-error>
+<error-type>application</error-type>
+<error-tag>operation-failed</error-tag>
+<error-severity>error</error-severity>
+<error-message>{{.}}</error-message>
+</rpc-error>`
+
+const VersionTemplate string = `<software-information>
+<host-name>{{.Name}}</host-name>
+<product-model>{{.Model}}</product-model>
+<product-name>{{.Model}}</product-name>
+<junos-version>{{.Version}}</junos-version>
+</software-information>`
+
+const ChassisTemplate string = `<chassis-inventory xmlns="http://xml.juniper.net/junos/{{.Version}}/junos-chassis">
+<chassis junos:style="inventory">
+<name>Chassis</name>
+<serial-number>SIM{{.Index}}</serial-number>
+<description>{{.Model}}</description>
+{{- range .Fpcs}}
+<chassis-module>
+<name>FPC {{.Slot}}</name>
+<description>{{.Desc}}</description>
+{{- range .Pics}}
+<chassis-sub-module>
+<name>PIC {{.Slot}}</name>
+<description>{{.Desc}}</description>
+{{- range .Xcvrs}}
+<chassis-sub-sub-module>
+<name>Xcvr {{.Slot}}</name>
+<description>{{.Desc}}</description>
+</chassis-sub-sub-module>
+{{- end}}
+</chassis-sub-module>
+{{- end}}
+</chassis-module>
+{{- end}}
+</chassis>
+</chassis-inventory>`
+
+const IfDescTemplate string = `<interface-information xmlns="http://xml.juniper.net/junos/{{.Version}}/junos-interface" junos:style="description">
+{{- range .Ports}}{{if .Desc}}
+<physical-interface>
+<name>{{.Name}}</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+<description>{{.Desc}}</description>
+</physical-interface>
+{{- end}}{{end}}
+{{- range .Lags}}
+<physical-interface>
+<name>{{.Name}}</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+<description>{{.Desc}}</description>
+</physical-interface>
+<logical-interface>
+<name>{{.Name}}.0</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+<description>{{.Desc}}-UNIT0</description>
+</logical-interface>
+{{- end}}
+</interface-information>`
+
+const IfTerseTemplate string = `<interface-information xmlns="http://xml.juniper.net/junos/{{.Version}}/junos-interface" junos:style="terse">
+{{- range .Ports}}
+<physical-interface>
+<name>{{.Name}}</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+<logical-interface>
+<name>{{.Name}}.0</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+</logical-interface>
+</physical-interface>
+{{- end}}
+{{- range .Lags}}
+<physical-interface>
+<name>{{.Name}}</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+<logical-interface>
+<name>{{.Name}}.0</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+</logical-interface>
+</physical-interface>
+{{- end}}
+<physical-interface>
+<name>lo0</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+</physical-interface>
+</interface-information>`
+
+const LacpTemplate string = `<lacp-interface-information-list xmlns="http://xml.juniper.net/junos/{{.Version}}/junos-lacpd">
+{{- range .Lags}}
+<lacp-interface-information>
+<lag-lacp-header>
+<aggregate-name>{{.Name}}</aggregate-name>
+</lag-lacp-header>
+{{- range .Members}}
+<lag-lacp-protocol>
+<name>{{.}}</name>
+<lacp-mux-state>Collecting distributing</lacp-mux-state>
+</lag-lacp-protocol>
+{{- end}}
+</lacp-interface-information>
+{{- end}}
+</lacp-interface-information-list>`
+
+const IsisTemplate string = `<isis-overview-information xmlns="http://xml.juniper.net/junos/{{.Version}}/junos-routing">
+<isis-overview>
+<instance-name>master</instance-name>
+<isis-spring>
+<isis-srgb-block>
+<isis-srgb-first-label>{{.SrgbStart}}</isis-srgb-first-label>
+</isis-srgb-block>
+<isis-node-segment>
+<isis-node-segment-ipv4-index>{{.NodeIndex}}</isis-node-segment-ipv4-index>
+<isis-node-segment-ipv6-index>{{.NodeIndexV6}}</isis-node-segment-ipv6-index>
+</isis-node-segment>
+</isis-spring>
+</isis-overview>
+</isis-overview-information>`
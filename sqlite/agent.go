@@ -0,0 +1,145 @@
+package sqlite
+
+import (
+	"fmt"
+	"jtso/logger"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Families running their own Telegraf agent
+var AgentFamilies = []string{"mx", "ptx", "acx", "ex", "qfx", "srx", "crpd", "cptx", "vmx", "vsrx", "vjunos", "vevo", "ondemand"}
+
+// Telegraf agent overrides of a family - an empty value inherits the
+// collector parameters. LogTarget is stderr or the path of a log file.
+type AgentTuning struct {
+	Family            string `json:"family"`
+	MetricBatchSize   string `json:"metricbatchsize"`
+	MetricBufferLimit string `json:"metricbufferlimit"`
+	FlushInterval     string `json:"flushinterval"`
+	FlushJitter       string `json:"flushjitter"`
+	CollectionJitter  string `json:"collectionjitter"`
+	LogTarget         string `json:"logtarget"`
+}
+
+// Family -> agent overrides
+var ActiveAgentTuning map[string]AgentTuning
+
+// Check the values of the overrides
+func (t *AgentTuning) Check() error {
+	known := false
+	for _, f := range AgentFamilies {
+		known = known || f == t.Family
+	}
+	if !known {
+		return fmt.Errorf("unknown family %s", t.Family)
+	}
+	for name, v := range map[string]string{"metric batch size": t.MetricBatchSize, "metric buffer limit": t.MetricBufferLimit} {
+		if v == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			return fmt.Errorf("invalid %s %q of the %s family", name, v, t.Family)
+		}
+	}
+	for name, v := range map[string]string{"flush interval": t.FlushInterval, "flush jitter": t.FlushJitter, "collection jitter": t.CollectionJitter} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(v); err != nil {
+			return fmt.Errorf("invalid %s %q of the %s family", name, v, t.Family)
+		}
+	}
+	if t.LogTarget != "" && t.LogTarget != "stderr" && !filepath.IsAbs(t.LogTarget) {
+		return fmt.Errorf("invalid log target %q of the %s family - expected stderr or an absolute path", t.LogTarget, t.Family)
+	}
+	if strings.ContainsFunc(t.LogTarget, func(c rune) bool { return c == '"' || c == '\\' || unicode.IsControl(c) }) {
+		return fmt.Errorf("invalid log target %q of the %s family - quotes, backslashes and control characters are not allowed", t.LogTarget, t.Family)
+	}
+	return nil
+}
+
+// Debug state of the agent of a family
+func DebugMode(family string) (int, error) {
+	switch family {
+	case "mx":
+		return ActiveAdmin.MXDebug, nil
+	case "ptx":
+		return ActiveAdmin.PTXDebug, nil
+	case "acx":
+		return ActiveAdmin.ACXDebug, nil
+	case "ex":
+		return ActiveAdmin.EXDebug, nil
+	case "qfx":
+		return ActiveAdmin.QFXDebug, nil
+	case "srx":
+		return ActiveAdmin.SRXDebug, nil
+	case "crpd":
+		return ActiveAdmin.CRPDDebug, nil
+	case "cptx":
+		return ActiveAdmin.CPTXDebug, nil
+	case "vmx":
+		return ActiveAdmin.VMXDebug, nil
+	case "vsrx":
+		return ActiveAdmin.VSRXDebug, nil
+	case "vjunos":
+		return ActiveAdmin.VJUNOSDebug, nil
+	case "vevo":
+		return ActiveAdmin.VEVODebug, nil
+	case "ondemand":
+		return ActiveAdmin.ONDEMANDDebug, nil
+	}
+	return 0, fmt.Errorf("unsupported instance %s", family)
+}
+
+// Reload the agent overrides - dbMu must be held
+func loadAgentTuningInternal() error {
+	ActiveAgentTuning = make(map[string]AgentTuning)
+	for _, f := range AgentFamilies {
+		ActiveAgentTuning[f] = AgentTuning{Family: f}
+	}
+	rows, err := db.Query("SELECT family, metric_batch_size, metric_buffer_limit, flush_interval, flush_jitter, collection_jitter, logtarget FROM agent_tuning;")
+	if err != nil {
+		logger.Log.Errorf("Error while selecting agent_tuning - err: %v", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t := AgentTuning{}
+		if err := rows.Scan(&t.Family, &t.MetricBatchSize, &t.MetricBufferLimit, &t.FlushInterval, &t.FlushJitter, &t.CollectionJitter, &t.LogTarget); err != nil {
+			logger.Log.Errorf("Error while parsing agent_tuning rows - err: %v", err)
+			return err
+		}
+		ActiveAgentTuning[t.Family] = t
+	}
+	return nil
+}
+
+func UpdateAgentTuning(t AgentTuning) error {
+	if err := t.Check(); err != nil {
+		return err
+	}
+
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	_, err := db.Exec(`
+		INSERT INTO agent_tuning (family, metric_batch_size, metric_buffer_limit, flush_interval, flush_jitter, collection_jitter, logtarget)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(family) DO UPDATE SET
+			metric_batch_size = excluded.metric_batch_size,
+			metric_buffer_limit = excluded.metric_buffer_limit,
+			flush_interval = excluded.flush_interval,
+			flush_jitter = excluded.flush_jitter,
+			collection_jitter = excluded.collection_jitter,
+			logtarget = excluded.logtarget;
+	`, t.Family, t.MetricBatchSize, t.MetricBufferLimit, t.FlushInterval, t.FlushJitter, t.CollectionJitter, t.LogTarget)
+	if err != nil {
+		logger.Log.Errorf("Error while upserting the agent tuning of the %s family: %v", t.Family, err)
+		return err
+	}
+	return loadAllInternal(false)
+}
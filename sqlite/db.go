@@ -26,7 +26,9 @@ type RtrEntry struct {
 type Collection struct {
 	ProfilesName []string
 	ProfilesConf []string
-	Routers      []*RtrEntry
+	// Values of the parameters of each profile
+	ProfilesParams []map[string]string
+	Routers        []*RtrEntry
 }
 
 type AssoEntry struct {
@@ -98,6 +100,16 @@ type KafkaConfig struct {
 	MessageSize int
 }
 
+// Prometheus export - client mode exposes a scrape endpoint per family,
+// remote write mode pushes the metrics to the endpoint
+type PrometheusConfig struct {
+	Id       int
+	Enabled  int
+	Mode     string
+	Port     int
+	Endpoint string
+}
+
 var (
 	db                        *sql.DB
 	dbMu                      *sync.Mutex
@@ -107,6 +119,7 @@ var (
 	ActiveCred                Cred
 	ActiveAdmin               Admin
 	ActiveKafkaConfig         KafkaConfig
+	ActivePrometheusConfig    PrometheusConfig
 	ActiveCollectorParameters CollectorParameters
 	SM                        *security.SecretManager
 )
@@ -221,6 +234,38 @@ func Init(f string) error {
 		messagesize INTEGER
 		);`
 
+	const createKafkaOptions string = `
+		CREATE TABLE IF NOT EXISTS kafka_options (
+		id INTEGER NOT NULL PRIMARY KEY,
+		sasl_mechanism TEXT,
+		sasl_user TEXT,
+		sasl_pwd TEXT,
+		tls TEXT,
+		ca TEXT,
+		skipverify TEXT,
+		routing TEXT,
+		topic_tag TEXT,
+		routes TEXT,
+		excluded_profiles TEXT
+		);`
+
+	const createOutputRoutes string = `
+		CREATE TABLE IF NOT EXISTS output_routes (
+		output TEXT NOT NULL PRIMARY KEY,
+		namepass TEXT,
+		fieldpass TEXT,
+		tagpass TEXT
+		);`
+
+	const createPrometheus string = `
+		CREATE TABLE IF NOT EXISTS prometheus_config (
+		id INTEGER NOT NULL PRIMARY KEY,
+		enabled INTEGER,
+		mode TEXT,
+		port INTEGER,
+		endpoint TEXT
+		);`
+
 	const createCollector string = `
 		CREATE TABLE IF NOT EXISTS collector_parameters (
 		id INTEGER NOT NULL PRIMARY KEY,
@@ -230,6 +275,95 @@ func Init(f string) error {
 		flush_jitter TEXT
 		);`
 
+	const createAgentTuning string = `
+		CREATE TABLE IF NOT EXISTS agent_tuning (
+		family TEXT NOT NULL PRIMARY KEY,
+		metric_batch_size TEXT,
+		metric_buffer_limit TEXT,
+		flush_interval TEXT,
+		flush_jitter TEXT,
+		collection_jitter TEXT,
+		logtarget TEXT
+		);`
+
+	const createMetaSnapshot string = `
+		CREATE TABLE IF NOT EXISTS meta_snapshot (
+		router TEXT NOT NULL PRIMARY KEY,
+		family TEXT,
+		ts INTEGER,
+		data TEXT
+		);`
+
+	const createMetaHistory string = `
+		CREATE TABLE IF NOT EXISTS meta_history (
+		id INTEGER NOT NULL PRIMARY KEY,
+		router TEXT NOT NULL,
+		ts INTEGER,
+		kind TEXT,
+		key TEXT,
+		attribute TEXT,
+		old TEXT,
+		new TEXT
+		);`
+
+	const createRunReport string = `
+		CREATE TABLE IF NOT EXISTS run_reports (
+		id INTEGER NOT NULL PRIMARY KEY,
+		router TEXT NOT NULL,
+		ts INTEGER,
+		duration INTEGER,
+		success INTEGER,
+		error TEXT,
+		data TEXT
+		);`
+
+	const createProfileVersions string = `
+		CREATE TABLE IF NOT EXISTS profile_versions (
+		id INTEGER NOT NULL PRIMARY KEY,
+		profile TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		version INTEGER,
+		signer TEXT,
+		ts INTEGER,
+		UNIQUE(profile, hash)
+		);`
+
+	const createProfilePins string = `
+		CREATE TABLE IF NOT EXISTS profile_pins (
+		profile TEXT NOT NULL PRIMARY KEY,
+		hash TEXT NOT NULL
+		);`
+
+	const createProfileParams string = `
+		CREATE TABLE IF NOT EXISTS profile_params (
+		profile TEXT NOT NULL,
+		param TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		target TEXT NOT NULL,
+		value TEXT,
+		UNIQUE(profile, param, scope, target)
+		);`
+
+	const createRouterGroups string = `
+		CREATE TABLE IF NOT EXISTS router_groups (
+		short TEXT NOT NULL PRIMARY KEY,
+		grp TEXT NOT NULL
+		);`
+
+	const createInflux string = `
+		CREATE TABLE IF NOT EXISTS influx_config (
+		id INTEGER NOT NULL PRIMARY KEY,
+		version TEXT,
+		url TEXT,
+		database TEXT,
+		org TEXT,
+		username TEXT,
+		password TEXT,
+		token TEXT,
+		ca TEXT,
+		skipverify TEXT
+		);`
+
 	if _, err := db.Exec(createRtr); err != nil {
 		logger.Log.Infof("Error while init DB %s Table routers - err: %v", f, err)
 		return err
@@ -254,10 +388,65 @@ func Init(f string) error {
 		logger.Log.Infof("Error while init DB %s Table kafka_config - err: %v", f, err)
 		return err
 	}
+	if _, err := db.Exec(createKafkaOptions); err != nil {
+		logger.Log.Infof("Error while init DB %s Table kafka_options - err: %v", f, err)
+		return err
+	}
+	if _, err := db.Exec(createOutputRoutes); err != nil {
+		logger.Log.Infof("Error while init DB %s Table output_routes - err: %v", f, err)
+		return err
+	}
+	if _, err := db.Exec(createPrometheus); err != nil {
+		logger.Log.Infof("Error while init DB %s Table prometheus_config - err: %v", f, err)
+		return err
+	}
 	if _, err := db.Exec(createCollector); err != nil {
 		logger.Log.Infof("Error while init DB %s Table collector_parameters - err: %v", f, err)
 		return err
 	}
+	if _, err := db.Exec(createAgentTuning); err != nil {
+		logger.Log.Infof("Error while init DB %s Table agent_tuning - err: %v", f, err)
+		return err
+	}
+
+	if _, err := db.Exec(createMetaSnapshot); err != nil {
+		logger.Log.Infof("Error while init DB %s Table meta_snapshot - err: %v", f, err)
+		return err
+	}
+	if _, err := db.Exec(createMetaHistory); err != nil {
+		logger.Log.Infof("Error while init DB %s Table meta_history - err: %v", f, err)
+		return err
+	}
+
+	if _, err := db.Exec(createRunReport); err != nil {
+		logger.Log.Infof("Error while init DB %s Table run_reports - err: %v", f, err)
+		return err
+	}
+
+	if _, err := db.Exec(createProfileVersions); err != nil {
+		logger.Log.Infof("Error while init DB %s Table profile_versions - err: %v", f, err)
+		return err
+	}
+
+	if _, err := db.Exec(createProfilePins); err != nil {
+		logger.Log.Infof("Error while init DB %s Table profile_pins - err: %v", f, err)
+		return err
+	}
+
+	if _, err := db.Exec(createProfileParams); err != nil {
+		logger.Log.Infof("Error while init DB %s Table profile_params - err: %v", f, err)
+		return err
+	}
+
+	if _, err := db.Exec(createRouterGroups); err != nil {
+		logger.Log.Infof("Error while init DB %s Table router_groups - err: %v", f, err)
+		return err
+	}
+
+	if _, err := db.Exec(createInflux); err != nil {
+		logger.Log.Infof("Error while init DB %s Table influx_config - err: %v", f, err)
+		return err
+	}
 
 	err = LoadAll(secretChange)
 	return err
@@ -367,6 +556,27 @@ func UpdateKafkaConfig(enabled int, brokers, topic, format, version string, comp
 	return loadAllInternal(false)
 }
 
+func UpdatePrometheusConfig(enabled int, mode string, port int, endpoint string) error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	_, err := db.Exec(`
+		INSERT INTO prometheus_config (id, enabled, mode, port, endpoint)
+		VALUES (0, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			enabled = excluded.enabled,
+			mode = excluded.mode,
+			port = excluded.port,
+			endpoint = excluded.endpoint;
+	`, enabled, mode, port, endpoint)
+
+	if err != nil {
+		logger.Log.Errorf("Error while upserting Prometheus config: %v", err)
+		return err
+	}
+	return loadAllInternal(false)
+}
+
 func UpdateCollectorParameters(metricBatchSize, metricBufferLimit, flushInterval, flushJitter string) error {
 	dbMu.Lock()
 	defer dbMu.Unlock()
@@ -482,6 +692,27 @@ func AddAsso(n string, a []string) error {
 func DelRouter(n string) error {
 	dbMu.Lock()
 	defer dbMu.Unlock()
+	// Remove also the metadata history of the router
+	if _, err := db.Exec("DELETE FROM meta_snapshot WHERE router IN (SELECT name FROM routers WHERE short=?);", n); err != nil {
+		logger.Log.Errorf("Error while removing metadata snapshot of router %s - err: %v", n, err)
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM meta_history WHERE router IN (SELECT name FROM routers WHERE short=?);", n); err != nil {
+		logger.Log.Errorf("Error while removing metadata history of router %s - err: %v", n, err)
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM run_reports WHERE router IN (SELECT name FROM routers WHERE short=?);", n); err != nil {
+		logger.Log.Errorf("Error while removing run reports of router %s - err: %v", n, err)
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM router_groups WHERE short=?;", n); err != nil {
+		logger.Log.Errorf("Error while removing router %s from its group - err: %v", n, err)
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM profile_params WHERE scope=? AND target=?;", PARAM_SCOPE_ROUTER, n); err != nil {
+		logger.Log.Errorf("Error while removing parameter values of router %s - err: %v", n, err)
+		return err
+	}
 	if _, err := db.Exec("DELETE FROM routers WHERE short=?;", n); err != nil {
 		logger.Log.Errorf("Error while adding router %s - err: %v", n, err)
 		return err
@@ -574,6 +805,14 @@ func LoadAll(secretRotation bool) error {
 // loadAllInternal performs the actual data reload without locking.
 // Caller must hold dbMu.Lock().
 func loadAllInternal(secretRotation bool) error {
+	// before the credentials - they finalize the secret rotation
+	if err := loadInfluxInternal(secretRotation); err != nil {
+		return err
+	}
+	if err := loadKafkaOptionsInternal(secretRotation); err != nil {
+		return err
+	}
+
 	RtrList = make([]*RtrEntry, 0)
 	rows, err := db.Query("SELECT * FROM routers;")
 	if err != nil {
@@ -922,6 +1161,35 @@ func loadAllInternal(secretRotation bool) error {
 		ActiveKafkaConfig = KafkaConfig{Id: 0, Enabled: 0, Brokers: "localhost:9092", Topic: "jtso_topic", Format: "json", Version: "2.7.0", Compression: 0, MessageSize: 1000000}
 	}
 
+	ActivePrometheusConfig = PrometheusConfig{}
+	rows, err = db.Query("SELECT * FROM prometheus_config;")
+	if err != nil {
+		logger.Log.Errorf("Error while selecting prometheus_config - err: %v", err)
+		return err
+	}
+	defer rows.Close()
+	i = rows.Next()
+	if i {
+		err = rows.Scan(
+			&ActivePrometheusConfig.Id,
+			&ActivePrometheusConfig.Enabled,
+			&ActivePrometheusConfig.Mode,
+			&ActivePrometheusConfig.Port,
+			&ActivePrometheusConfig.Endpoint,
+		)
+		if err != nil {
+			logger.Log.Errorf("Error while parsing prometheus_config rows - err: %v", err)
+			return err
+		}
+	} else {
+		// nothing in the DB regarding prometheus config - add default one
+		if _, err := db.Exec("INSERT INTO prometheus_config VALUES(?,?,?,?,?);", 0, 0, "client", 9273, "http://prometheus:9090/api/v1/write"); err != nil {
+			logger.Log.Errorf("Error while adding default prometheus config - err: %v", err)
+			return err
+		}
+		ActivePrometheusConfig = PrometheusConfig{Id: 0, Enabled: 0, Mode: "client", Port: 9273, Endpoint: "http://prometheus:9090/api/v1/write"}
+	}
+
 	ActiveCollectorParameters = CollectorParameters{}
 	rows, err = db.Query("SELECT * FROM collector_parameters;")
 	if err != nil {
@@ -951,7 +1219,14 @@ func loadAllInternal(secretRotation bool) error {
 		ActiveCollectorParameters = CollectorParameters{Id: 0, MetricBatchSize: "5000", MetricBufferLimit: "100000", FlushInterval: "5s", FlushJitter: "0s"}
 	}
 
-	return nil
+	if err := loadRoutesInternal(); err != nil {
+		return err
+	}
+	if err := loadAgentTuningInternal(); err != nil {
+		return err
+	}
+
+	return loadParamsInternal()
 }
 
 func CloseDb() error {
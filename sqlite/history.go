@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"database/sql"
+	"jtso/logger"
+	"time"
+)
+
+// One change of the enrichment metadata of a router
+type MetaChange struct {
+	Id        int    `json:"id"`
+	Router    string `json:"router"`
+	Timestamp int64  `json:"ts"`
+	Kind      string `json:"kind"`
+	Key       string `json:"key"`
+	Attribute string `json:"attribute"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+}
+
+// Return the last metadata snapshot (JSON) of a router. found is false if
+// the router has never been enriched.
+func GetMetaSnapshot(router string) (data string, found bool, err error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	row := db.QueryRow("SELECT data FROM meta_snapshot WHERE router=?;", router)
+	err = row.Scan(&data)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		logger.Log.Errorf("Error while querying metadata snapshot of %s - err: %v", router, err)
+		return "", false, err
+	}
+	return data, true, nil
+}
+
+// Store the new snapshot of a router and its changes in one transaction.
+// Changes older than retention days are purged.
+func SaveMetaSnapshot(router string, family string, data string, changes []*MetaChange, retention int) error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	now := time.Now().Unix()
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Log.Errorf("Error while starting metadata transaction for %s - err: %v", router, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO meta_snapshot (router, family, ts, data)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(router) DO UPDATE SET
+			family = excluded.family,
+			ts = excluded.ts,
+			data = excluded.data;
+	`, router, family, now, data)
+	if err != nil {
+		logger.Log.Errorf("Error while upserting metadata snapshot of %s - err: %v", router, err)
+		return err
+	}
+
+	for _, c := range changes {
+		if _, err := tx.Exec("INSERT INTO meta_history VALUES(NULL,?,?,?,?,?,?,?);",
+			router, c.Timestamp, c.Kind, c.Key, c.Attribute, c.Old, c.New); err != nil {
+			logger.Log.Errorf("Error while adding metadata change of %s - err: %v", router, err)
+			return err
+		}
+	}
+
+	if retention > 0 {
+		limit := now - int64(retention)*24*3600
+		if _, err := tx.Exec("DELETE FROM meta_history WHERE ts < ?;", limit); err != nil {
+			logger.Log.Errorf("Error while purging metadata history - err: %v", err)
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Return the metadata changes of a router (all routers if empty), newest first.
+func GetMetaChanges(router string, limit int) ([]*MetaChange, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if limit <= 0 {
+		limit = 1000
+	}
+	var rows *sql.Rows
+	var err error
+	if router == "" {
+		rows, err = db.Query("SELECT id, router, ts, kind, key, attribute, old, new FROM meta_history ORDER BY ts DESC, id DESC LIMIT ?;", limit)
+	} else {
+		rows, err = db.Query("SELECT id, router, ts, kind, key, attribute, old, new FROM meta_history WHERE router=? ORDER BY ts DESC, id DESC LIMIT ?;", router, limit)
+	}
+	if err != nil {
+		logger.Log.Errorf("Error while selecting metadata history - err: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]*MetaChange, 0)
+	for rows.Next() {
+		c := new(MetaChange)
+		if err := rows.Scan(&c.Id, &c.Router, &c.Timestamp, &c.Kind, &c.Key, &c.Attribute, &c.Old, &c.New); err != nil {
+			logger.Log.Errorf("Error while parsing metadata history - err: %v", err)
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"database/sql"
+	"jtso/influx"
+	"jtso/logger"
+	"jtso/security"
+)
+
+// Connection to InfluxDB - Password and Token are decrypted
+type InfluxConfig struct {
+	Id         int
+	Version    string
+	Url        string
+	Database   string
+	Org        string
+	Username   string
+	Password   string
+	Token      string
+	CA         string
+	SkipVerify string
+}
+
+var ActiveInfluxConfig InfluxConfig
+
+// Decrypt a secret of the influx config - with the previous secret during a
+// rotation. An empty secret stays empty.
+func decryptSecret(enc string, secretRotation bool) (string, error) {
+	if enc == "" {
+		return "", nil
+	}
+	clear, err := security.Decrypt(SM.Current, enc)
+	if err != nil && secretRotation {
+		clear, err = security.Decrypt(SM.Previous, enc)
+	}
+	return clear, err
+}
+
+func encryptSecret(clear string) (string, error) {
+	if clear == "" {
+		return "", nil
+	}
+	return security.Encrypt(SM.Current, clear)
+}
+
+// Reload the influx connection and hand it to the influx package - dbMu must
+// be held
+func loadInfluxInternal(secretRotation bool) error {
+	c := InfluxConfig{}
+	row := db.QueryRow("SELECT * FROM influx_config WHERE id=0;")
+	err := row.Scan(&c.Id, &c.Version, &c.Url, &c.Database, &c.Org, &c.Username, &c.Password, &c.Token, &c.CA, &c.SkipVerify)
+	if err == sql.ErrNoRows {
+		// nothing in the DB regarding influx config - add default one
+		c = InfluxConfig{Id: 0, Version: influx.V1, Url: "http://influxdb:8086", Database: "jtsdb", SkipVerify: "no"}
+		if _, err := db.Exec("INSERT INTO influx_config VALUES(?,?,?,?,?,?,?,?,?,?);", c.Id, c.Version, c.Url, c.Database, "", "", "", "", "", c.SkipVerify); err != nil {
+			logger.Log.Errorf("Error while adding default influx config - err: %v", err)
+			return err
+		}
+	} else if err != nil {
+		logger.Log.Errorf("Error while selecting influx_config - err: %v", err)
+		return err
+	} else {
+		encPwd, encToken := c.Password, c.Token
+		if c.Password, err = decryptSecret(encPwd, secretRotation); err != nil {
+			logger.Log.Errorf("Error decrypting influx password - err: %v", err)
+			return err
+		}
+		if c.Token, err = decryptSecret(encToken, secretRotation); err != nil {
+			logger.Log.Errorf("Error decrypting influx token - err: %v", err)
+			return err
+		}
+		// reencrypt with the new secret
+		if secretRotation {
+			encPwd, _ = encryptSecret(c.Password)
+			encToken, _ = encryptSecret(c.Token)
+			if _, err := db.Exec("UPDATE influx_config SET password=?, token=? WHERE id=0;", encPwd, encToken); err != nil {
+				logger.Log.Errorf("Error re-encrypting influx secrets with the new secret - err: %v", err)
+				return err
+			}
+		}
+	}
+
+	ActiveInfluxConfig = c
+	influx.SetConnection(influx.Connection{
+		Version:    c.Version,
+		Url:        c.Url,
+		Database:   c.Database,
+		Org:        c.Org,
+		Username:   c.Username,
+		Password:   c.Password,
+		Token:      c.Token,
+		CA:         c.CA,
+		SkipVerify: c.SkipVerify == "yes",
+	})
+	return nil
+}
+
+func UpdateInfluxConfig(c InfluxConfig) error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	encPwd, err := encryptSecret(c.Password)
+	if err != nil {
+		logger.Log.Errorf("Error while encrypting influx password - err: %v", err)
+		return err
+	}
+	encToken, err := encryptSecret(c.Token)
+	if err != nil {
+		logger.Log.Errorf("Error while encrypting influx token - err: %v", err)
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO influx_config (id, version, url, database, org, username, password, token, ca, skipverify)
+		VALUES (0, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			version = excluded.version,
+			url = excluded.url,
+			database = excluded.database,
+			org = excluded.org,
+			username = excluded.username,
+			password = excluded.password,
+			token = excluded.token,
+			ca = excluded.ca,
+			skipverify = excluded.skipverify;
+	`, c.Version, c.Url, c.Database, c.Org, c.Username, encPwd, encToken, c.CA, c.SkipVerify)
+	if err != nil {
+		logger.Log.Errorf("Error while upserting influx config: %v", err)
+		return err
+	}
+	return loadAllInternal(false)
+}
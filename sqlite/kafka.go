@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"database/sql"
+	"jtso/logger"
+	"strings"
+)
+
+// Topic routing of the Kafka output
+const (
+	KAFKA_ROUTING_NONE   string = "none"
+	KAFKA_ROUTING_SUFFIX string = "suffix"
+	KAFKA_ROUTING_TAG    string = "tag"
+	KAFKA_ROUTING_RULES  string = "rules"
+)
+
+// Security and routing options of the Kafka output - SaslPwd is decrypted.
+// Routes is a comma separated list of measurement=topic, ExcludedProfiles a
+// comma separated list of profiles not exported to Kafka.
+type KafkaOptions struct {
+	Id               int
+	SaslMechanism    string
+	SaslUser         string
+	SaslPwd          string
+	Tls              string
+	CA               string
+	SkipVerify       string
+	Routing          string
+	TopicTag         string
+	Routes           string
+	ExcludedProfiles string
+}
+
+var ActiveKafkaOptions KafkaOptions
+
+// Parsed measurement=topic routes
+func (o *KafkaOptions) RouteMap() map[string]string {
+	routes := make(map[string]string)
+	for _, r := range strings.Split(o.Routes, ",") {
+		m, t, ok := strings.Cut(r, "=")
+		if ok && strings.TrimSpace(m) != "" && strings.TrimSpace(t) != "" {
+			routes[strings.TrimSpace(m)] = strings.TrimSpace(t)
+		}
+	}
+	return routes
+}
+
+// True if the profile is exported to Kafka
+func (o *KafkaOptions) Exported(profile string) bool {
+	for _, p := range strings.Split(o.ExcludedProfiles, ",") {
+		if strings.TrimSpace(p) == profile {
+			return false
+		}
+	}
+	return true
+}
+
+// Reload the Kafka options - dbMu must be held
+func loadKafkaOptionsInternal(secretRotation bool) error {
+	o := KafkaOptions{}
+	row := db.QueryRow("SELECT * FROM kafka_options WHERE id=0;")
+	err := row.Scan(&o.Id, &o.SaslMechanism, &o.SaslUser, &o.SaslPwd, &o.Tls, &o.CA, &o.SkipVerify, &o.Routing, &o.TopicTag, &o.Routes, &o.ExcludedProfiles)
+	if err == sql.ErrNoRows {
+		// nothing in the DB regarding kafka options - add default one
+		o = KafkaOptions{Id: 0, Tls: "no", SkipVerify: "no", Routing: KAFKA_ROUTING_NONE}
+		if _, err := db.Exec("INSERT INTO kafka_options VALUES(?,?,?,?,?,?,?,?,?,?,?);", o.Id, "", "", "", o.Tls, "", o.SkipVerify, o.Routing, "", "", ""); err != nil {
+			logger.Log.Errorf("Error while adding default kafka options - err: %v", err)
+			return err
+		}
+	} else if err != nil {
+		logger.Log.Errorf("Error while selecting kafka_options - err: %v", err)
+		return err
+	} else {
+		enc := o.SaslPwd
+		if o.SaslPwd, err = decryptSecret(enc, secretRotation); err != nil {
+			logger.Log.Errorf("Error decrypting kafka SASL password - err: %v", err)
+			return err
+		}
+		// reencrypt with the new secret
+		if secretRotation {
+			enc, _ = encryptSecret(o.SaslPwd)
+			if _, err := db.Exec("UPDATE kafka_options SET sasl_pwd=? WHERE id=0;", enc); err != nil {
+				logger.Log.Errorf("Error re-encrypting kafka SASL password with the new secret - err: %v", err)
+				return err
+			}
+		}
+	}
+	ActiveKafkaOptions = o
+	return nil
+}
+
+func UpdateKafkaOptions(o KafkaOptions) error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	enc, err := encryptSecret(o.SaslPwd)
+	if err != nil {
+		logger.Log.Errorf("Error while encrypting kafka SASL password - err: %v", err)
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO kafka_options (id, sasl_mechanism, sasl_user, sasl_pwd, tls, ca, skipverify, routing, topic_tag, routes, excluded_profiles)
+		VALUES (0, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			sasl_mechanism = excluded.sasl_mechanism,
+			sasl_user = excluded.sasl_user,
+			sasl_pwd = excluded.sasl_pwd,
+			tls = excluded.tls,
+			ca = excluded.ca,
+			skipverify = excluded.skipverify,
+			routing = excluded.routing,
+			topic_tag = excluded.topic_tag,
+			routes = excluded.routes,
+			excluded_profiles = excluded.excluded_profiles;
+	`, o.SaslMechanism, o.SaslUser, enc, o.Tls, o.CA, o.SkipVerify, o.Routing, o.TopicTag, o.Routes, o.ExcludedProfiles)
+	if err != nil {
+		logger.Log.Errorf("Error while upserting Kafka options: %v", err)
+		return err
+	}
+	return loadAllInternal(false)
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"jtso/logger"
+)
+
+// Scopes of a profile parameter value - a router value wins over a group value
+const (
+	PARAM_SCOPE_GROUP  string = "group"
+	PARAM_SCOPE_ROUTER string = "router"
+)
+
+// Value of a profile parameter for a router group or for a router (short name)
+type ParamValue struct {
+	Profile string `json:"profile"`
+	Param   string `json:"param"`
+	Scope   string `json:"scope"`
+	Target  string `json:"target"`
+	Value   string `json:"value"`
+}
+
+var (
+	ActiveParams []*ParamValue
+	// Router short name -> group
+	RouterGroups map[string]string
+)
+
+// Reload the parameter values and the router groups - dbMu must be held
+func loadParamsInternal() error {
+	ActiveParams = make([]*ParamValue, 0)
+	rows, err := db.Query("SELECT profile, param, scope, target, value FROM profile_params;")
+	if err != nil {
+		logger.Log.Errorf("Error while selecting profile_params - err: %v", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		v := new(ParamValue)
+		if err := rows.Scan(&v.Profile, &v.Param, &v.Scope, &v.Target, &v.Value); err != nil {
+			logger.Log.Errorf("Error while parsing profile_params rows - err: %v", err)
+			return err
+		}
+		ActiveParams = append(ActiveParams, v)
+	}
+
+	RouterGroups = make(map[string]string)
+	grows, err := db.Query("SELECT short, grp FROM router_groups;")
+	if err != nil {
+		logger.Log.Errorf("Error while selecting router_groups - err: %v", err)
+		return err
+	}
+	defer grows.Close()
+	for grows.Next() {
+		var s, g string
+		if err := grows.Scan(&s, &g); err != nil {
+			logger.Log.Errorf("Error while parsing router_groups rows - err: %v", err)
+			return err
+		}
+		RouterGroups[s] = g
+	}
+	return nil
+}
+
+// Set the value of a parameter - an empty value removes it
+func SetParamValue(v *ParamValue) error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	var err error
+	if v.Value == "" {
+		_, err = db.Exec("DELETE FROM profile_params WHERE profile=? AND param=? AND scope=? AND target=?;", v.Profile, v.Param, v.Scope, v.Target)
+	} else {
+		_, err = db.Exec(`
+			INSERT INTO profile_params (profile, param, scope, target, value)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(profile, param, scope, target)
+			DO UPDATE SET value = excluded.value;
+		`, v.Profile, v.Param, v.Scope, v.Target, v.Value)
+	}
+	if err != nil {
+		logger.Log.Errorf("Error while setting parameter %s of profile %s for %s %s - err: %v", v.Param, v.Profile, v.Scope, v.Target, err)
+		return err
+	}
+	return loadParamsInternal()
+}
+
+// Assign a router to a group - an empty group removes the router from its group
+func SetRouterGroup(short string, group string) error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	var err error
+	if group == "" {
+		_, err = db.Exec("DELETE FROM router_groups WHERE short=?;", short)
+	} else {
+		_, err = db.Exec("INSERT OR REPLACE INTO router_groups VALUES(?,?);", short, group)
+	}
+	if err != nil {
+		logger.Log.Errorf("Error while setting the group of router %s - err: %v", short, err)
+		return err
+	}
+	return loadParamsInternal()
+}
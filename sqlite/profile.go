@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"jtso/logger"
+)
+
+// One archived version of a profile package
+type ProfileVersion struct {
+	Profile   string `json:"profile"`
+	Hash      string `json:"hash"`
+	Version   int    `json:"version"`
+	Signer    string `json:"signer"`
+	Timestamp int64  `json:"ts"`
+}
+
+// Record a version of a profile package and return the hashes of the
+// versions beyond the "keep" last ones - they are removed from the table
+func AddProfileVersion(v *ProfileVersion, keep int) ([]string, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if _, err := db.Exec("INSERT OR IGNORE INTO profile_versions VALUES(NULL,?,?,?,?,?);", v.Profile, v.Hash, v.Version, v.Signer, v.Timestamp); err != nil {
+		logger.Log.Errorf("Error while adding version %s of profile %s - err: %v", v.Hash, v.Profile, err)
+		return nil, err
+	}
+	purged := make([]string, 0)
+	if keep <= 0 {
+		return purged, nil
+	}
+
+	// never purge the pinned version
+	rows, err := db.Query(`
+		SELECT hash FROM profile_versions
+		WHERE profile = ? AND hash NOT IN (SELECT hash FROM profile_pins WHERE profile = ?)
+		ORDER BY id DESC LIMIT -1 OFFSET ?;
+	`, v.Profile, v.Profile, keep)
+	if err != nil {
+		logger.Log.Errorf("Error while listing old versions of profile %s - err: %v", v.Profile, err)
+		return nil, err
+	}
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		purged = append(purged, h)
+	}
+	rows.Close()
+
+	for _, h := range purged {
+		if _, err := db.Exec("DELETE FROM profile_versions WHERE profile=? AND hash=?;", v.Profile, h); err != nil {
+			logger.Log.Errorf("Error while purging version %s of profile %s - err: %v", h, v.Profile, err)
+			return nil, err
+		}
+	}
+	return purged, nil
+}
+
+// Return the archived versions of a profile, newest first
+func GetProfileVersions(profile string) ([]*ProfileVersion, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	rows, err := db.Query("SELECT profile, hash, version, signer, ts FROM profile_versions WHERE profile=? ORDER BY id DESC;", profile)
+	if err != nil {
+		logger.Log.Errorf("Error while retrieving versions of profile %s - err: %v", profile, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]*ProfileVersion, 0)
+	for rows.Next() {
+		v := new(ProfileVersion)
+		if err := rows.Scan(&v.Profile, &v.Hash, &v.Version, &v.Signer, &v.Timestamp); err != nil {
+			logger.Log.Errorf("Error while parsing versions of profile %s - err: %v", profile, err)
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// Return the pinned version of every pinned profile
+func GetProfilePins() (map[string]string, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	rows, err := db.Query("SELECT profile, hash FROM profile_pins;")
+	if err != nil {
+		logger.Log.Errorf("Error while retrieving profile pins - err: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := make(map[string]string)
+	for rows.Next() {
+		var p, h string
+		if err := rows.Scan(&p, &h); err != nil {
+			logger.Log.Errorf("Error while parsing profile pins - err: %v", err)
+			return nil, err
+		}
+		pins[p] = h
+	}
+	return pins, nil
+}
+
+// Pin a profile to a version - an empty hash removes the pin
+func SetProfilePin(profile string, hash string) error {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	var err error
+	if hash == "" {
+		_, err = db.Exec("DELETE FROM profile_pins WHERE profile=?;", profile)
+	} else {
+		_, err = db.Exec("INSERT OR REPLACE INTO profile_pins VALUES(?,?);", profile, hash)
+	}
+	if err != nil {
+		logger.Log.Errorf("Error while pinning profile %s - err: %v", profile, err)
+	}
+	return err
+}
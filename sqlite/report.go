@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"jtso/logger"
+)
+
+// Max number of run reports kept per router
+const MAX_RUN_REPORTS = 1000
+
+// Diagnostic of one RPC issued during an enrichment run
+type RpcReport struct {
+	Rpc        string `json:"rpc"`
+	DurationMs int64  `json:"duration"`
+	Size       int    `json:"size"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	ParseError string `json:"parse_error,omitempty"`
+}
+
+// Report of one enrichment run for a router
+type RunReport struct {
+	Id         int            `json:"id"`
+	Router     string         `json:"router"`
+	Timestamp  int64          `json:"ts"`
+	DurationMs int64          `json:"duration"`
+	Success    bool           `json:"success"`
+	Error      string         `json:"error,omitempty"`
+	Rpcs       []*RpcReport   `json:"rpcs"`
+	Entities   map[string]int `json:"entities"`
+	Skipped    []string       `json:"skipped"`
+}
+
+// Detail of a report stored as JSON
+type runReportData struct {
+	Rpcs     []*RpcReport   `json:"rpcs"`
+	Entities map[string]int `json:"entities"`
+	Skipped  []string       `json:"skipped"`
+}
+
+// Store a run report and keep only the last "keep" reports of the router -
+// 0 or less stores nothing, keep is bounded by MAX_RUN_REPORTS
+func AddRunReport(r *RunReport, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	if keep > MAX_RUN_REPORTS {
+		keep = MAX_RUN_REPORTS
+	}
+
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	data, err := json.Marshal(runReportData{Rpcs: r.Rpcs, Entities: r.Entities, Skipped: r.Skipped})
+	if err != nil {
+		logger.Log.Errorf("Error while serializing run report of %s - err: %v", r.Router, err)
+		return err
+	}
+	success := 0
+	if r.Success {
+		success = 1
+	}
+	if _, err := db.Exec("INSERT INTO run_reports VALUES(NULL,?,?,?,?,?,?);", r.Router, r.Timestamp, r.DurationMs, success, r.Error, string(data)); err != nil {
+		logger.Log.Errorf("Error while adding run report of %s - err: %v", r.Router, err)
+		return err
+	}
+	_, err = db.Exec(`
+		DELETE FROM run_reports
+		WHERE router = ? AND id NOT IN (
+			SELECT id FROM run_reports WHERE router = ? ORDER BY id DESC LIMIT ?
+		);
+	`, r.Router, r.Router, keep)
+	if err != nil {
+		logger.Log.Errorf("Error while purging run reports of %s - err: %v", r.Router, err)
+		return err
+	}
+	return nil
+}
+
+// Return the last run reports of a router, newest first
+func GetRunReports(router string, limit int) ([]*RunReport, error) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := db.Query("SELECT id, router, ts, duration, success, error, data FROM run_reports WHERE router=? ORDER BY id DESC LIMIT ?;", router, limit)
+	if err != nil {
+		logger.Log.Errorf("Error while selecting run reports - err: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]*RunReport, 0)
+	for rows.Next() {
+		var success int
+		var data string
+		r := new(RunReport)
+		if err := rows.Scan(&r.Id, &r.Router, &r.Timestamp, &r.DurationMs, &success, &r.Error, &data); err != nil {
+			logger.Log.Errorf("Error while parsing run reports - err: %v", err)
+			return nil, err
+		}
+		r.Success = success == 1
+		var d runReportData
+		if err := json.Unmarshal([]byte(data), &d); err != nil {
+			logger.Log.Errorf("Error while parsing run report detail of %s - err: %v", r.Router, err)
+		}
+		r.Rpcs, r.Entities, r.Skipped = d.Rpcs, d.Entities, d.Skipped
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"fmt"
+	"jtso/logger"
+	"strings"
+	"unicode"
+)
+
+// Outputs with a routing policy
+var RoutedOutputs = []string{"influx", "kafka", "prometheus", "file"}
+
+// Routing policy of an output. Namepass and Fieldpass are comma separated
+// globs - empty means the output receives what the profiles declare. Tagpass
+// is a list of tag=value1|value2 separated by commas.
+type OutputRoute struct {
+	Output    string `json:"output"`
+	Namepass  string `json:"namepass"`
+	Fieldpass string `json:"fieldpass"`
+	Tagpass   string `json:"tagpass"`
+}
+
+// Output -> routing policy
+var ActiveRoutes map[string]OutputRoute
+
+func splitList(s string, sep string) []string {
+	out := make([]string, 0)
+	for _, v := range strings.Split(s, sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (r *OutputRoute) NamepassList() []string {
+	return splitList(r.Namepass, ",")
+}
+
+func (r *OutputRoute) FieldpassList() []string {
+	return splitList(r.Fieldpass, ",")
+}
+
+// Tag -> accepted values
+func (r *OutputRoute) TagpassMap() map[string][]string {
+	tags := make(map[string][]string)
+	for _, t := range splitList(r.Tagpass, ",") {
+		k, v, _ := strings.Cut(t, "=")
+		if k = strings.TrimSpace(k); k != "" {
+			tags[k] = append(tags[k], splitList(v, "|")...)
+		}
+	}
+	return tags
+}
+
+// Check the syntax of the tagpass - quotes, backslashes and control
+// characters are rejected in all the lists
+func (r *OutputRoute) Check() error {
+	known := false
+	for _, o := range RoutedOutputs {
+		known = known || o == r.Output
+	}
+	if !known {
+		return fmt.Errorf("unknown output %s", r.Output)
+	}
+	for name, v := range map[string]string{"namepass": r.Namepass, "fieldpass": r.Fieldpass, "tagpass": r.Tagpass} {
+		if i := strings.IndexFunc(v, func(c rune) bool { return c == '"' || c == '\\' || unicode.IsControl(c) }); i >= 0 {
+			return fmt.Errorf("invalid character %q in the %s of the %s output", v[i:i+1], name, r.Output)
+		}
+	}
+	for _, t := range splitList(r.Tagpass, ",") {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok || strings.TrimSpace(k) == "" || len(splitList(v, "|")) == 0 {
+			return fmt.Errorf("invalid tagpass %q of the %s output - expected tag=value1|value2", t, r.Output)
+		}
+	}
+	return nil
+}
+
+// Reload the routing policies - dbMu must be held
+func loadRoutesInternal() error {
+	ActiveRoutes = make(map[string]OutputRoute)
+	for _, o := range RoutedOutputs {
+		ActiveRoutes[o] = OutputRoute{Output: o}
+	}
+	rows, err := db.Query("SELECT output, namepass, fieldpass, tagpass FROM output_routes;")
+	if err != nil {
+		logger.Log.Errorf("Error while selecting output_routes - err: %v", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r := OutputRoute{}
+		if err := rows.Scan(&r.Output, &r.Namepass, &r.Fieldpass, &r.Tagpass); err != nil {
+			logger.Log.Errorf("Error while parsing output_routes rows - err: %v", err)
+			return err
+		}
+		ActiveRoutes[r.Output] = r
+	}
+	return nil
+}
+
+func UpdateOutputRoutes(routes []OutputRoute) error {
+	for i := range routes {
+		if err := routes[i].Check(); err != nil {
+			return err
+		}
+	}
+
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	for _, r := range routes {
+		_, err := db.Exec(`
+			INSERT INTO output_routes (output, namepass, fieldpass, tagpass)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(output) DO UPDATE SET
+				namepass = excluded.namepass,
+				fieldpass = excluded.fieldpass,
+				tagpass = excluded.tagpass;
+		`, r.Output, r.Namepass, r.Fieldpass, r.Tagpass)
+		if err != nil {
+			logger.Log.Errorf("Error while upserting the routing of the %s output: %v", r.Output, err)
+			return err
+		}
+	}
+	return loadAllInternal(false)
+}
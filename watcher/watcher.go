@@ -0,0 +1,127 @@
+package watcher
+
+import (
+	"context"
+	"jtso/logger"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// A watched directory and the function called once its content settles
+type watched struct {
+	dir     string
+	name    string
+	handler func()
+	trigger chan struct{}
+}
+
+// Watch directories with inotify. Handlers are called after a quiet period
+// (debounce) so that files still being written are not processed, and
+// periodically as a safety net.
+type Watcher struct {
+	debounce time.Duration
+	dirs     map[string]*watched
+}
+
+func New(debounce time.Duration) *Watcher {
+	if debounce <= 0 {
+		debounce = 2 * time.Second
+	}
+	return &Watcher{debounce: debounce, dirs: make(map[string]*watched)}
+}
+
+// Register a handler for a directory - must be called before Run
+func (w *Watcher) Add(name string, dir string, handler func()) {
+	dir = filepath.Clean(dir)
+	w.dirs[dir] = &watched{dir: dir, name: name, handler: handler, trigger: make(chan struct{}, 1)}
+}
+
+// Hidden files are temporary files of the uploads and of the extractions
+func ignored(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
+
+func (d *watched) notify() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+		// a run is already pending
+	}
+}
+
+// Debounce the events of a directory and run its handler - one run at a time
+func (w *Watcher) loop(ctx context.Context, d *watched) {
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-d.trigger:
+			timer.Reset(w.debounce)
+		case <-timer.C:
+			logger.Log.Debugf("Change detected in %s - refresh %s", d.dir, d.name)
+			func() {
+				defer logger.HandlePanic()
+				d.handler()
+			}()
+		}
+	}
+}
+
+// Watch the directories until the context is cancelled. Every rescan
+// interval all the handlers are triggered even without event.
+func (w *Watcher) Run(ctx context.Context, rescan time.Duration) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Log.Errorf("Unable to create the file watcher: %v", err)
+		return err
+	}
+	defer fw.Close()
+
+	for dir, d := range w.dirs {
+		if err := fw.Add(dir); err != nil {
+			logger.Log.Errorf("Unable to watch %s - rely on the periodic rescan: %v", dir, err)
+		} else {
+			logger.Log.Infof("Watching %s for %s changes", dir, d.name)
+		}
+		go w.loop(ctx, d)
+	}
+
+	if rescan <= 0 {
+		rescan = 10 * time.Minute
+	}
+	ticker := time.NewTicker(rescan)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op == fsnotify.Chmod || ignored(ev.Name) {
+				continue
+			}
+			if d, ok := w.dirs[filepath.Dir(ev.Name)]; ok {
+				d.notify()
+			}
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Log.Errorf("File watcher error: %v", err)
+		case <-ticker.C:
+			logger.Log.Debug("Periodic rescan of the watched directories")
+			for _, d := range w.dirs {
+				d.notify()
+			}
+		}
+	}
+}
@@ -8,15 +8,24 @@ import (
 	"jtso/output"
 	"jtso/sqlite"
 	"strings"
-	"sync"
+	"time"
 )
 
-func Collect(cfg *config.ConfigContainer) {
+// Process wide context - cancelled on shutdown
+var rootCtx context.Context = context.Background()
 
-	ctx := context.Background()
+// Init the worker package with the process wide context
+func Init(ctx context.Context) {
+	rootCtx = ctx
+}
+
+func Collect(cfg *config.ConfigContainer) {
 
 	// create the pooler
-	p, err := NewSimplePool(cfg.Enricher.Workers, 0, ctx)
+	p, err := NewSimplePool(cfg.Enricher.Workers, 0, rootCtx, PoolOptions{
+		TaskTimeout: time.Duration(cfg.Enricher.TaskTimeout) * time.Second,
+		Retries:     cfg.Enricher.Retries,
+	})
 	if err != nil {
 		logger.Log.Errorf("Unable to create worker pool... panic...: %v", err)
 		panic(err)
@@ -33,11 +42,9 @@ func Collect(cfg *config.ConfigContainer) {
 		}
 	}
 	if numTasks > 0 {
-		// Allocate the number of task to WG. = to number of routers
-		wg := &sync.WaitGroup{}
 		logger.Log.Infof("Number of routers to collect: %d", numTasks)
-		wg.Add(numTasks)
 		logger.Log.Info("Start dispatching Jobs")
+		before := GetStats()
 		// Push tasks to worker pool
 		// iter on all the intances
 		for _, rtr := range sqlite.RtrList {
@@ -50,12 +57,21 @@ func Collect(cfg *config.ConfigContainer) {
 					Family:  rtr.Family,
 					Port:    cfg.Netconf.Port,
 					Timeout: cfg.Netconf.RpcTimeout,
-					Wg:      wg,
 					Jsonify: output.MyMeta,
+					Reports: cfg.Enricher.Reports,
 				})
 			}
 		}
-		wg.Wait()
+		p.Wait()
+		if rootCtx.Err() != nil {
+			logger.Log.Info("Collection cancelled")
+			return
+		}
+		st := GetStats().since(before)
+		logger.Log.Infof("Worker pool stats of this run - done: %d, failed: %d, retries: %d, avg latency: %d ms",
+			st.TasksDone, st.TasksFailed, st.Retries, st.AvgLatencyMs)
+		// Record the metadata changes since the last run
+		output.MyMeta.TrackChanges(cfg)
 		err := output.MyMeta.MarshallMeta(cfg.Enricher.Folder)
 		if err != nil {
 			logger.Log.Error("Unexpected error while creating the Json files: ", err)
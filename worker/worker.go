@@ -2,20 +2,57 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"jtso/logger"
-	"jtso/netconf"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Pool interface {
 	Start()
 	Stop()
 	AddWork(Task)
+	Wait()
 }
 
+// A task executed by the pool. Work must honor the context: it is cancelled
+// on shutdown or when the task deadline expires.
 type Task interface {
-	Work() error
+	Id() string
+	Work(ctx context.Context) error
+}
+
+// An error of a task the pool may retry - e.g. a failure to open the session
+type Retryable interface {
+	Retryable() bool
+}
+
+// Pool options
+type PoolOptions struct {
+	// Overall deadline of one task attempt - 0 means no deadline
+	TaskTimeout time.Duration
+	// Number of retries of the Retryable failures
+	Retries int
+	// Base delay of the exponential backoff
+	Backoff time.Duration
+	// Max delay of the exponential backoff
+	MaxBackoff time.Duration
+}
+
+// Pool metrics shared by all the pools - cumulative since the start
+type PoolStats struct {
+	QueueDepth    int64 `json:"queue_depth"`
+	BusyWorkers   int64 `json:"busy_workers"`
+	TasksDone     int64 `json:"tasks_done"`
+	TasksFailed   int64 `json:"tasks_failed"`
+	Retries       int64 `json:"retries"`
+	LastLatencyMs int64 `json:"last_latency_ms"`
+	MaxLatencyMs  int64 `json:"max_latency_ms"`
+	AvgLatencyMs  int64 `json:"avg_latency_ms"`
+	totalLatency  int64
 }
 
 type SimplePool struct {
@@ -25,6 +62,8 @@ type SimplePool struct {
 	stop       sync.Once
 	quit       chan struct{}
 	ctx        context.Context
+	opts       PoolOptions
+	wg         sync.WaitGroup
 }
 
 var _ Pool = (*SimplePool)(nil)
@@ -32,7 +71,41 @@ var _ Pool = (*SimplePool)(nil)
 var ErrNoWorkers = fmt.Errorf("Attempting to create worker pool with less than 1 worker")
 var ErrNegativeChannelSize = fmt.Errorf("Attempting to create worker pool with a negative channel size")
 
-func NewSimplePool(numWorkers int, channelSize int, ctx context.Context) (Pool, error) {
+var stats PoolStats
+
+// Return a snapshot of the pool metrics
+func GetStats() PoolStats {
+	s := PoolStats{
+		QueueDepth:    atomic.LoadInt64(&stats.QueueDepth),
+		BusyWorkers:   atomic.LoadInt64(&stats.BusyWorkers),
+		TasksDone:     atomic.LoadInt64(&stats.TasksDone),
+		TasksFailed:   atomic.LoadInt64(&stats.TasksFailed),
+		Retries:       atomic.LoadInt64(&stats.Retries),
+		LastLatencyMs: atomic.LoadInt64(&stats.LastLatencyMs),
+		MaxLatencyMs:  atomic.LoadInt64(&stats.MaxLatencyMs),
+		totalLatency:  atomic.LoadInt64(&stats.totalLatency),
+	}
+	if total := s.TasksDone + s.TasksFailed; total > 0 {
+		s.AvgLatencyMs = atomic.LoadInt64(&stats.totalLatency) / total
+	}
+	return s
+}
+
+// Counters accumulated since a previous snapshot
+func (s PoolStats) since(prev PoolStats) PoolStats {
+	d := PoolStats{
+		TasksDone:    s.TasksDone - prev.TasksDone,
+		TasksFailed:  s.TasksFailed - prev.TasksFailed,
+		Retries:      s.Retries - prev.Retries,
+		totalLatency: s.totalLatency - prev.totalLatency,
+	}
+	if total := d.TasksDone + d.TasksFailed; total > 0 {
+		d.AvgLatencyMs = d.totalLatency / total
+	}
+	return d
+}
+
+func NewSimplePool(numWorkers int, channelSize int, ctx context.Context, opts PoolOptions) (Pool, error) {
 	defer logger.HandlePanic()
 	if numWorkers <= 0 {
 		return nil, ErrNoWorkers
@@ -40,6 +113,12 @@ func NewSimplePool(numWorkers int, channelSize int, ctx context.Context) (Pool,
 	if channelSize < 0 {
 		return nil, ErrNegativeChannelSize
 	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 2 * time.Second
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = 30 * time.Second
+	}
 
 	tasks := make(chan Task, channelSize)
 
@@ -50,6 +129,7 @@ func NewSimplePool(numWorkers int, channelSize int, ctx context.Context) (Pool,
 		stop:       sync.Once{},
 		quit:       make(chan struct{}),
 		ctx:        ctx,
+		opts:       opts,
 	}, nil
 }
 
@@ -66,14 +146,64 @@ func (p *SimplePool) Stop() {
 	})
 }
 
+// Wait until all the tasks pushed to the pool are done
+func (p *SimplePool) Wait() {
+	p.wg.Wait()
+}
+
 func (p *SimplePool) AddWork(t Task) {
 	defer logger.HandlePanic()
+	p.wg.Add(1)
+	atomic.AddInt64(&stats.QueueDepth, 1)
 	select {
 	case p.tasks <- t:
 	case <-p.ctx.Done():
 		logger.Log.Infof("End Signal Received... Stop working")
+		atomic.AddInt64(&stats.QueueDepth, -1)
+		p.wg.Done()
 	case <-p.quit:
+		atomic.AddInt64(&stats.QueueDepth, -1)
+		p.wg.Done()
+	}
+}
+
+// Compute the jittered exponential backoff of a given attempt
+func (p *SimplePool) backoff(attempt int) time.Duration {
+	d := p.opts.Backoff << uint(attempt)
+	if d <= 0 || d > p.opts.MaxBackoff {
+		d = p.opts.MaxBackoff
+	}
+	// full jitter between d/2 and d
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
 
+// Run one task with its deadline and retries
+func (p *SimplePool) run(i int, task Task) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := p.ctx, context.CancelFunc(func() {})
+		if p.opts.TaskTimeout > 0 {
+			ctx, cancel = context.WithTimeout(p.ctx, p.opts.TaskTimeout)
+		}
+		err = task.Work(ctx)
+		cancel()
+
+		// only the Retryable failures are retried - never a cancelled or expired task
+		var retryable Retryable
+		if err == nil || attempt >= p.opts.Retries || !errors.As(err, &retryable) || !retryable.Retryable() || ctx.Err() != nil || p.ctx.Err() != nil {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		atomic.AddInt64(&stats.Retries, 1)
+		logger.Log.Warnf("Worker %d - task %s failed (attempt %d/%d): %v - retry in %v", i, task.Id(), attempt+1, p.opts.Retries+1, err, delay)
+		select {
+		case <-p.ctx.Done():
+			return err
+		case <-p.quit:
+			return err
+		case <-time.After(delay):
+		}
 	}
 }
 
@@ -87,6 +217,7 @@ func (p *SimplePool) startWorkers() {
 				select {
 				case <-p.ctx.Done():
 					logger.Log.Infof("End Signal Received... Stop Worker %d", i)
+					p.drain()
 					return
 				case <-p.quit:
 					logger.Log.Infof("Stop Worker %d", i)
@@ -96,18 +227,46 @@ func (p *SimplePool) startWorkers() {
 						logger.Log.Errorf("Worker %d experienced an issue when receiving task", i)
 						return
 					}
-					t, isOk := task.(*netconf.RouterTask)
-					if !isOk {
-						logger.Log.Errorf("Worker %d experienced an issue when casting task", i)
-						return
+					atomic.AddInt64(&stats.QueueDepth, -1)
+					atomic.AddInt64(&stats.BusyWorkers, 1)
+					logger.Log.Debugf("Worker %d receives a JOB for %s", i, task.Id())
+
+					start := time.Now()
+					err := p.run(i, task)
+					latency := time.Since(start).Milliseconds()
+
+					atomic.StoreInt64(&stats.LastLatencyMs, latency)
+					atomic.AddInt64(&stats.totalLatency, latency)
+					for {
+						max := atomic.LoadInt64(&stats.MaxLatencyMs)
+						if latency <= max || atomic.CompareAndSwapInt64(&stats.MaxLatencyMs, max, latency) {
+							break
+						}
 					}
-					logger.Log.Debugf("Worker %d receives a JOB for router %s", i, t.Name)
-					if err := task.Work(); err != nil {
+					if err != nil {
+						atomic.AddInt64(&stats.TasksFailed, 1)
 						logger.Log.Errorf("Worker %d experienced an issue after executing its task: %v", i, err)
+					} else {
+						atomic.AddInt64(&stats.TasksDone, 1)
 					}
+					atomic.AddInt64(&stats.BusyWorkers, -1)
 					logger.Log.Debugf("Worker %d Job done", i)
+					p.wg.Done()
 				}
 			}
 		}(i)
 	}
 }
+
+// Release the tasks still queued after a cancellation
+func (p *SimplePool) drain() {
+	for {
+		select {
+		case <-p.tasks:
+			atomic.AddInt64(&stats.QueueDepth, -1)
+			p.wg.Done()
+		default:
+			return
+		}
+	}
+}
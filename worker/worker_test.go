@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"jtso/logger"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type retryErr struct{ retry bool }
+
+func (e *retryErr) Error() string   { return "dial failure" }
+func (e *retryErr) Retryable() bool { return e.retry }
+
+// Fails with err on every attempt
+type failingTask struct {
+	err      error
+	attempts int64
+}
+
+func (t *failingTask) Id() string { return "failing" }
+
+func (t *failingTask) Work(ctx context.Context) error {
+	atomic.AddInt64(&t.attempts, 1)
+	return t.err
+}
+
+func TestRunRetries(t *testing.T) {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+
+	tests := []struct {
+		name     string
+		err      error
+		attempts int64
+	}{
+		{"success", nil, 1},
+		{"plain error", errors.New("rpc failure"), 1},
+		{"retryable", &retryErr{retry: true}, 3},
+		{"wrapped retryable", errors.Join(errors.New("session"), &retryErr{retry: true}), 3},
+		{"not retryable", &retryErr{retry: false}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewSimplePool(1, 1, context.Background(), PoolOptions{Retries: 2, Backoff: time.Millisecond, MaxBackoff: time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			task := &failingTask{err: tt.err}
+			p.Start()
+			p.AddWork(task)
+			p.Wait()
+			p.Stop()
+			if got := atomic.LoadInt64(&task.attempts); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}